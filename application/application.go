@@ -2,6 +2,7 @@ package application
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -9,8 +10,10 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -22,11 +25,12 @@ import (
 	"github.com/vishen/go-chromecast/cast"
 	pb "github.com/vishen/go-chromecast/cast/proto"
 	"github.com/vishen/go-chromecast/storage"
+	"github.com/vishen/go-chromecast/tags"
 )
 
 var (
-	// Global request id
-	requestID int
+	// Global request id, only ever updated atomically.
+	requestID int64
 )
 
 const (
@@ -35,39 +39,53 @@ const (
 	// https://github.com/thibauts/node-castv2
 	defaultChromecastAppId = "CC1AD845"
 
+	// Backoff between attempts to reconnect to a chromecast that has
+	// dropped off the network.
+	reconnectInitialBackoff = time.Second
+	reconnectMaxBackoff     = time.Second * 30
+
+	// How long to wait for a response from the chromecast when the
+	// context has no deadline.
+	defaultResponseTimeout = time.Second * 5
+
 	defaultSender = "sender-0"
 	defaultRecv   = "receiver-0"
 
-	namespaceConn  = "urn:x-cast:com.google.cast.tp.connection"
-	namespaceRecv  = "urn:x-cast:com.google.cast.receiver"
-	namespaceMedia = "urn:x-cast:com.google.cast.media"
+	namespaceConn  = cast.NamespaceConnection
+	namespaceRecv  = cast.NamespaceReceiver
+	namespaceMedia = cast.NamespaceMedia
 )
 
 type PlayedItem struct {
 	ContentID string `json:"content_id"`
 	Started   int64  `json:"started"`
 	Finished  int64  `json:"finished"`
+	// How far, in seconds, the media had played when it was last seen,
+	// and how long it is.
+	Position float32 `json:"position"`
+	Duration float32 `json:"duration"`
 }
 
-type CastMessageFunc func(*pb.CastMessage)
-
 type Application struct {
 	conn  *cast.Connection
 	debug bool
 
 	// 'cast.Connection' will send receieved messages back on this channel.
 	recvMsgChan chan *pb.CastMessage
+	// 'cast.Connection' will send the reason the connection was dropped on
+	// this channel.
+	connErrChan chan error
 	// Internal mapping of request id to result channel
+	resultMu      sync.Mutex
 	resultChanMap map[int]chan *pb.CastMessage
 
-	messageMu sync.Mutex
-	// Relay messages receieved so users can add custom logic to
-	// events.
-	messageChan chan *pb.CastMessage
-	// Functions that will receieve messages from 'messageChan'
-	messageFuncs []CastMessageFunc
+	// Everyone that has subscribed to the events from the chromecast.
+	subscribersMu sync.Mutex
+	subscribers   map[*Subscription]struct{}
 
-	// Current values from the chromecast.
+	// Current values from the chromecast, guarded by 'mu'. The values
+	// themselves are never modified, only replaced.
+	mu          sync.RWMutex
 	application *cast.Application // It is possible that there is no current application, can happen for google home.
 	media       *cast.Media
 	// There seems to be two different volumes returned from the chromecast,
@@ -77,22 +95,52 @@ type Application struct {
 	volumeMedia    *cast.Volume
 	volumeReceiver *cast.Volume
 
+	// Guards the streaming server, which serves the files of this
+	// application only, and is shut down when it is closed.
+	serverMu   sync.Mutex
 	httpServer *http.Server
 	serverPort int
 	localIP    string
 	iface      *net.Interface
+	// The played positions are polled from when the server first starts.
+	pollOnce sync.Once
+
+	// Guards the files we are allowed to serve, and the record of what
+	// has been played, which are touched by the streaming server.
+	servedMu      sync.Mutex
+	servedFiles   map[string]servedFile // by token
+	coverArt      map[string]*coverArt  // by media filename
+	playedItems   map[string]PlayedItem
+	positionSaved time.Time
+	// Bumped every time media is loaded, see 'newServedGeneration'.
+	servedGeneration int
 
-	// NOTE: Currently only playing one media file at a time is handled
-	mediaFinished  chan bool
-	mediaFilenames []string
+	serveToDeviceOnly bool
+	hls               hlsStreams
+	deviceProfile     *DeviceProfile
+	// Asks urls what they are, see 'probeURL'.
+	httpClient *http.Client
 
-	playedItems   map[string]PlayedItem
 	cacheDisabled bool
 	cache         *storage.Storage
 
 	// Number of connection retries to try before returning
 	// and error.
 	connectionRetries int
+
+	// Address of the chromecast, kept around so we can reconnect to it.
+	addr string
+	port int
+	// Number of times to try to re-establish a dropped connection to the
+	// chromecast, 0 disables reconnecting.
+	reconnectAttempts int
+
+	reconnectMu  sync.Mutex
+	reconnecting bool
+	// The connection was dropped and isn't going to be re-established.
+	disconnected bool
+	closed       chan struct{}
+	closeOnce    sync.Once
 }
 
 type ApplicationOption func(*Application)
@@ -122,145 +170,303 @@ func WithConnectionRetries(connectionRetries int) ApplicationOption {
 	}
 }
 
+// WithReconnect will have the application redial the chromecast, up to
+// 'attempts' times, when the connection to it is lost. Once reconnected
+// it re-joins whatever application and media session are running.
+func WithReconnect(attempts int) ApplicationOption {
+	return func(a *Application) {
+		a.reconnectAttempts = attempts
+	}
+}
+
+// WithTrustStore has the chromecast authenticate itself as a genuine cast
+// device, with a device certificate issued by one of 'roots', whenever it
+// is connected to. Nothing is sent to a chromecast that fails.
+func WithTrustStore(roots *x509.CertPool) ApplicationOption {
+	return func(a *Application) {
+		a.conn.SetTrustStore(roots)
+	}
+}
+
 func NewApplication(opts ...ApplicationOption) *Application {
 	recvMsgChan := make(chan *pb.CastMessage, 5)
+	connErrChan := make(chan error, 1)
 	a := &Application{
 		recvMsgChan:       recvMsgChan,
+		connErrChan:       connErrChan,
 		resultChanMap:     map[int]chan *pb.CastMessage{},
-		messageChan:       make(chan *pb.CastMessage),
-		conn:              cast.NewConnection(recvMsgChan),
+		subscribers:       map[*Subscription]struct{}{},
+		conn:              cast.NewConnection(recvMsgChan, connErrChan),
 		playedItems:       map[string]PlayedItem{},
+		servedFiles:       map[string]servedFile{},
+		hls:               hlsStreams{streams: map[hlsKey]*hlsStream{}},
+		deviceProfile:     defaultDeviceProfile,
+		httpClient:        http.DefaultClient,
+		coverArt:          map[string]*coverArt{},
 		cache:             storage.NewStorage(),
 		connectionRetries: 5,
+		closed:            make(chan struct{}),
 	}
 
 	// Apply options
 	for _, o := range opts {
 		o(a)
 	}
+	a.conn.SetDisconnectHook(a.connectionDropped)
 
 	// Kick off the listener for asynchronous messages received from the
 	// cast connection.
 	go a.recvMessages()
-	// Kick off the message channel listener.
-	go a.messageChanHandler()
+	// Kick off the listener for the cast connection being dropped.
+	go a.connErrors()
 	return a
 }
 
-func (a *Application) Application() *cast.Application { return a.application }
-func (a *Application) Media() *cast.Media             { return a.media }
-func (a *Application) Volume() *cast.Volume           { return a.volumeReceiver }
+func (a *Application) Application() *cast.Application {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.application
+}
+
+func (a *Application) Media() *cast.Media {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.media
+}
 
-func (a *Application) AddMessageFunc(f CastMessageFunc) {
-	a.messageMu.Lock()
-	defer a.messageMu.Unlock()
+func (a *Application) Volume() *cast.Volume {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.volumeReceiver
+}
 
-	a.messageFuncs = append(a.messageFuncs, f)
+// Connected reports whether the connection to the chromecast is still up.
+// Once the connection has been dropped, because the chromecast stopped
+// responding or the socket was closed, the application is no longer usable.
+func (a *Application) Connected() bool {
+	return a.conn.IsConnected()
 }
 
-func (a *Application) messageChanHandler() {
-	for msg := range a.messageChan {
-		a.messageMu.Lock()
-		for _, f := range a.messageFuncs {
-			f(msg)
-		}
-		a.messageMu.Unlock()
+// Reconnecting reports whether the application is currently trying to
+// re-establish a dropped connection to the chromecast.
+func (a *Application) Reconnecting() bool {
+	a.reconnectMu.Lock()
+	defer a.reconnectMu.Unlock()
+	return a.reconnecting
+}
+
+// Disconnected reports whether the connection to the chromecast was
+// dropped for good, it isn't up and isn't being re-established. Unlike
+// checking 'Connected' and 'Reconnecting' one after the other, there is no
+// moment while it reconnects that it looks disconnected.
+func (a *Application) Disconnected() bool {
+	a.reconnectMu.Lock()
+	defer a.reconnectMu.Unlock()
+	return a.disconnected
+}
+
+// connectionDropped is called by the connection as it is dropped, before
+// it reports itself as not connected, so the application is already
+// reconnecting by the time anyone can tell.
+func (a *Application) connectionDropped(err error) {
+	a.reconnectMu.Lock()
+	defer a.reconnectMu.Unlock()
+	if a.reconnectAttempts > 0 {
+		a.reconnecting = true
+	} else {
+		a.disconnected = true
 	}
 }
 
-// TODO: Clean-up: these Media* methods are a hack around trying
-// to use the mediaFinished channel when it hasn't been properly
-// set up. This is happening because there is some instances where we don't
-// have any running media ('watch' command) but yet the 'mediaFinished'
-// is littered throughout the codebase. This needs a redesign now that
-// we do things other than just loading and playing media files.
-func (a *Application) MediaStart() {
-	a.mediaFinished = make(chan bool, 1)
+// reconnected records whether reconnecting worked, in one step so the
+// application is never neither reconnecting nor connected or disconnected.
+func (a *Application) reconnected(ok bool) {
+	a.reconnectMu.Lock()
+	defer a.reconnectMu.Unlock()
+	a.reconnecting = false
+	a.disconnected = !ok
 }
 
-func (a *Application) MediaWait() {
-	<-a.mediaFinished
-	a.mediaFinished = nil
+func (a *Application) connErrors() {
+	for err := range a.connErrChan {
+		log.WithField("package", "application").WithError(err).Error("lost connection to chromecast")
+		if a.reconnectAttempts > 0 {
+			reconnectErr := a.reconnect()
+			if reconnectErr == nil {
+				continue
+			}
+			log.WithField("package", "application").WithError(reconnectErr).Error("unable to reconnect to chromecast")
+		}
+		a.publish(Disconnected{Err: err})
+	}
 }
 
-func (a *Application) MediaFinished() {
-	if a.mediaFinished == nil {
-		return
+// reconnect redials the chromecast with an exponential backoff, and once
+// connected re-joins the running application and media session.
+func (a *Application) reconnect() (err error) {
+	a.reconnectMu.Lock()
+	a.reconnecting = true
+	a.reconnectMu.Unlock()
+	defer func() { a.reconnected(err == nil) }()
+
+	// Give up as soon as the application is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-a.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	backoff := reconnectInitialBackoff
+	for i := 0; i < a.reconnectAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return errors.New("application closed while reconnecting")
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+
+		a.log("reconnecting to chromecast at %s:%d; attempt %d/%d", a.addr, a.port, i+1, a.reconnectAttempts)
+		if err = a.conn.Start(ctx, a.addr, a.port); err != nil {
+			continue
+		}
+		if err = a.sendDefaultConn(ctx, &cast.ConnectHeader); err != nil {
+			a.conn.Close()
+			continue
+		}
+		// The chromecast may have restarted, in which case the application
+		// and media session we knew about no longer exist. 'Update' will
+		// find whatever is running now and connect to it.
+		a.mu.Lock()
+		a.application = nil
+		a.media = nil
+		a.mu.Unlock()
+		if err = a.Update(ctx); err != nil {
+			a.conn.Close()
+			continue
+		}
+		a.log("reconnected to chromecast at %s:%d", a.addr, a.port)
+		return nil
 	}
-	a.mediaFinished <- true
+	return err
 }
 
 func (a *Application) recvMessages() {
 	for msg := range a.recvMsgChan {
+		var resultChan chan *pb.CastMessage
 		requestID, err := jsonparser.GetInt([]byte(*msg.PayloadUtf8), "requestId")
 		if err == nil {
-			if resultChan, ok := a.resultChanMap[int(requestID)]; ok {
-				resultChan <- msg
-				// Relay the event to any user specified message funcs.
-				a.messageChan <- msg
-				continue
-			}
+			// Only the first reply is handed over, the chromecast
+			// sometimes answers twice.
+			a.resultMu.Lock()
+			resultChan = a.resultChanMap[int(requestID)]
+			delete(a.resultChanMap, int(requestID))
+			a.resultMu.Unlock()
 		}
 
-		messageBytes := []byte(*msg.PayloadUtf8)
-		// This already gets checked in the cast.Connection.handleMessage function.
-		messageType, _ := jsonparser.GetString(messageBytes, "type")
-		switch messageType {
-		case "LOAD_FAILED":
-			a.MediaFinished()
-		case "MEDIA_STATUS":
-			resp := cast.MediaStatusResponse{}
-			if err := json.Unmarshal(messageBytes, &resp); err == nil {
-				for _, status := range resp.Status {
-					// The LoadingItemId is only set when there is a playlist and there
-					// is an item being loaded to play next.
-					if status.IdleReason == "FINISHED" && status.LoadingItemId == 0 {
-						a.MediaFinished()
-					} else if status.IdleReason == "INTERRUPTED" && status.Media.ContentId == "" {
-						// This can happen when we go "next" in a playlist when it
-						// is playing the last track.
-						a.MediaFinished()
-					}
-				}
+		// Update our view of the chromecast before handing the reply
+		// over, so whoever is waiting on it sees the new state.
+		events := a.handleMessage(msg, resultChan != nil)
+		if resultChan != nil {
+			select {
+			case resultChan <- msg:
+			default:
+				// Whoever was waiting has already had a reply.
 			}
-		case "RECEIVER_STATUS":
-			// We don't care about this when the application isn't set.
-			if a.application == nil {
-				break
+		}
+		a.publish(append(events, MessageReceived{Message: msg})...)
+	}
+}
+
+// handleMessage updates the current state from 'msg' and returns the events
+// it resulted in. 'reply' is whether the message is the reply to a request
+// that is being waited on.
+func (a *Application) handleMessage(msg *pb.CastMessage, reply bool) []Event {
+	decoded, err := cast.Decode(msg)
+	if err != nil {
+		a.log("unable to decode message: %v", err)
+		return nil
+	}
+	switch resp := decoded.(type) {
+	case *cast.LoadFailedResponse:
+		return []Event{LoadFailed{RequestID: resp.RequestId}}
+	case *cast.MediaStatusResponse:
+		a.mu.Lock()
+		previous := a.media
+		for _, media := range resp.Status {
+			media := media
+			// The chromecast only sends the media information, like
+			// the tracks, when it changes.
+			if media.Media.ContentId == "" && media.PlayerState != "IDLE" && a.media != nil && a.media.MediaSessionId == media.MediaSessionId {
+				media.Media = a.media.Media
 			}
-			resp := cast.ReceiverStatusResponse{}
-			if err := json.Unmarshal(messageBytes, &resp); err == nil {
-				// Check to see if the application on the device has changed,
-				// if it has it is likely not this running instance that changed
-				// it because that currently isn't possible.
-				for _, app := range resp.Status.Applications {
-					if app.AppId != a.application.AppId {
-						a.MediaFinished()
-					}
-					a.application = &app
-				}
-				a.volumeReceiver = &resp.Status.Volume
+			// Media being transcoded from partway through starts from 0.
+			if start, duration, ok := transcodedPosition(media.Media.ContentId); ok {
+				media.CurrentTime += start
+				media.Media.Duration = duration
 			}
+			a.media = &media
+			a.volumeMedia = &media.Volume
+		}
+		current := a.media
+		a.mu.Unlock()
+		if current != nil && current != previous {
+			a.recordPosition(previous, current)
+		}
+		return []Event{MediaStatusChanged{RequestID: resp.RequestId, Status: resp.Status, reply: reply}}
+	case *cast.ReceiverStatusResponse:
+		events := []Event{ReceiverStatusChanged{
+			RequestID:    resp.RequestId,
+			Applications: resp.Status.Applications,
+			Volume:       resp.Status.Volume,
+		}}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		// We don't care about broadcasts when the application isn't set.
+		if !reply && a.application == nil {
+			return events
+		}
+		previous := a.application
+		// TODO(vishen): Why could there be more than one application, how to handle this?
+		// For now just take the last one.
+		for _, app := range resp.Status.Applications {
+			app := app
+			a.application = &app
 		}
-		// Relay the event to any user specified message funcs.
-		a.messageChan <- msg
+		if a.application != nil && (previous == nil || previous.AppId != a.application.AppId || previous.SessionId != a.application.SessionId) {
+			events = append(events, ApplicationChanged{Previous: previous, Current: a.application})
+		}
+		if a.volumeReceiver == nil || *a.volumeReceiver != resp.Status.Volume {
+			events = append(events, VolumeChanged{Volume: resp.Status.Volume})
+		}
+		a.volumeReceiver = &resp.Status.Volume
+		return events
 	}
+	return nil
 }
 
 func (a *Application) SetDebug(debug bool) { a.debug = debug; a.conn.SetDebug(debug) }
 
-func (a *Application) Start(addr string, port int) error {
+func (a *Application) Start(ctx context.Context, addr string, port int) error {
+	a.addr = addr
+	a.port = port
 	if err := a.loadPlayedItems(); err != nil {
 		a.log("unable to load played items: %v", err)
 	}
 
-	if err := a.conn.Start(addr, port); err != nil {
+	if err := a.conn.Start(ctx, addr, port); err != nil {
 		return err
 	}
-	if err := a.sendDefaultConn(&cast.ConnectHeader); err != nil {
+	if err := a.sendDefaultConn(ctx, &cast.ConnectHeader); err != nil {
 		return errors.Wrap(err, "unable to connect to chromecast")
 	}
-	return errors.Wrap(a.Update(), "unable to update application")
+	return errors.Wrap(a.Update(ctx), "unable to update application")
 }
 
 func (a *Application) loadPlayedItems() error {
@@ -272,9 +478,12 @@ func (a *Application) loadPlayedItems() error {
 	if err != nil || len(b) == 0 {
 		return nil
 	}
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
 	return json.Unmarshal(b, &a.playedItems)
 }
 
+// writePlayedItems persists the played items, 'servedMu' must be held.
 func (a *Application) writePlayedItems() error {
 	if a.cacheDisabled {
 		return nil
@@ -284,7 +493,7 @@ func (a *Application) writePlayedItems() error {
 	return a.cache.Save("application", playedItemsJson)
 }
 
-func (a *Application) Update() error {
+func (a *Application) Update(ctx context.Context) error {
 	var recvStatus *cast.ReceiverStatusResponse
 	var err error
 	// Simple retry. We need this for when the device isn't currently
@@ -293,13 +502,17 @@ func (a *Application) Update() error {
 	// not sure how to fix but there might be some way of knowing from the
 	// payload?
 	for i := 0; i < a.connectionRetries; i++ {
-		recvStatus, err = a.getReceiverStatus()
+		recvStatus, err = a.getReceiverStatus(ctx)
 		if err == nil {
 			break
 		}
 		a.log("error getting receiever status: %v", err)
 		a.log("unable to get status from device; attempt %d/5, retrying...", i+1)
-		time.Sleep(time.Second * 2)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second * 2):
+		}
 	}
 	if err != nil {
 		return err
@@ -309,112 +522,123 @@ func (a *Application) Update() error {
 		a.log("more than 1 connected application on the chromecast: (%d)%#v", len(recvStatus.Status.Applications), recvStatus.Status.Applications)
 	}
 
-	// TODO(vishen): Why could there be more than one application, how to handle this?
-	// For now just take the last one.
-	for _, app := range recvStatus.Status.Applications {
-		a.application = &app
-	}
-	a.volumeReceiver = &recvStatus.Status.Volume
-
-	if a.application == nil || a.application.IsIdleScreen {
+	// The application and volume have already been updated from the
+	// reply, see 'handleMessage'.
+	if application := a.Application(); application == nil || application.IsIdleScreen {
 		return nil
 	}
 
-	a.updateMediaStatus()
+	a.updateMediaStatus(ctx)
 
 	return nil
 
 }
 
-func (a *Application) updateMediaStatus() error {
-	a.sendMediaConn(&cast.ConnectHeader)
+// updateMediaStatus requests the status of the media session, the media is
+// updated from the reply in 'handleMessage'.
+func (a *Application) updateMediaStatus(ctx context.Context) error {
+	a.sendMediaConn(ctx, &cast.ConnectHeader)
 
-	mediaStatus, err := a.getMediaStatus()
-	if err != nil {
-		return err
-	}
-	for _, media := range mediaStatus.Status {
-		a.media = &media
-		a.volumeMedia = &media.Volume
-	}
-	return nil
+	_, err := a.getMediaStatus(ctx)
+	return err
 }
 
 func (a *Application) Close(stopMedia bool) error {
+	// Stop any reconnection attempts.
+	a.closeOnce.Do(func() { close(a.closed) })
+	defer a.unsubscribeAll()
 	if stopMedia {
-		a.sendMediaConn(&cast.CloseHeader)
-		a.sendDefaultConn(&cast.CloseHeader)
+		ctx := context.Background()
+		a.sendMediaConn(ctx, &cast.CloseHeader)
+		a.sendDefaultConn(ctx, &cast.CloseHeader)
+	}
+	a.serverMu.Lock()
+	if a.httpServer != nil {
+		// Closing the connections also stops any transcoding.
+		if err := a.httpServer.Close(); err != nil {
+			a.log("unable to close streaming server: %v", err)
+		}
 	}
+	a.serverMu.Unlock()
+	a.forgetServedFiles()
+	a.closeHLS()
 	return a.conn.Close()
 }
 
 func (a *Application) Status() (*cast.Application, *cast.Media, *cast.Volume) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.application, a.media, a.volumeReceiver
 }
 
-func (a *Application) Pause() error {
-	if a.media == nil {
+func (a *Application) Pause(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
 		return ErrNoMediaPause
 	}
-	return a.sendMediaRecv(&cast.MediaHeader{
+	return a.sendMediaRecv(ctx, &cast.MediaHeader{
 		PayloadHeader:  cast.PauseHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 	})
 }
 
-func (a *Application) Unpause() error {
-	if a.media == nil {
+func (a *Application) Unpause(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
 		return ErrNoMediaUnpause
 	}
-	return a.sendMediaRecv(&cast.MediaHeader{
+	return a.sendMediaRecv(ctx, &cast.MediaHeader{
 		PayloadHeader:  cast.PlayHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 	})
 }
 
-func (a *Application) StopMedia() error {
-	if a.media == nil {
+func (a *Application) StopMedia(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
 		return ErrNoMediaStop
 	}
-	return a.sendMediaRecv(&cast.MediaHeader{
+	return a.sendMediaRecv(ctx, &cast.MediaHeader{
 		PayloadHeader:  cast.StopHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 	})
 }
 
-func (a *Application) Stop() error {
-	return a.sendDefaultRecv(&cast.StopHeader)
+func (a *Application) Stop(ctx context.Context) error {
+	return a.sendDefaultRecv(ctx, &cast.StopHeader)
 }
 
-func (a *Application) Next() error {
-	if a.media == nil {
+func (a *Application) Next(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
 		return ErrNoMediaNext
 	}
 
 	// TODO(vishen): Get the number of queue items, if none, possibly just skip to the end?
-	return a.sendMediaRecv(&cast.QueueUpdate{
+	return a.sendMediaRecv(ctx, &cast.QueueUpdate{
 		PayloadHeader:  cast.QueueUpdateHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 		Jump:           1,
 	})
 }
 
-func (a *Application) Previous() error {
-	if a.media == nil {
+func (a *Application) Previous(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
 		return ErrNoMediaPrevious
 	}
 
 	// TODO(vishen): Get the number of queue items, if none, possibly just jump to beginning?
-	return a.sendMediaRecv(&cast.QueueUpdate{
+	return a.sendMediaRecv(ctx, &cast.QueueUpdate{
 		PayloadHeader:  cast.QueueUpdateHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 		Jump:           -1,
 	})
 }
 
-func (a *Application) Skip() error {
+func (a *Application) Skip(ctx context.Context) error {
 
-	if a.media == nil {
+	if a.Media() == nil {
 		return ErrNoMediaSkip
 	}
 
@@ -422,20 +646,28 @@ func (a *Application) Skip() error {
 	// TODO(vishen): can we unroll this, so it doesn't update the current state?
 	// but just returns it?
 	// that might also make a.media == nil checks pointless?
-	a.updateMediaStatus()
+	a.updateMediaStatus(ctx)
 
-	v := a.media.CurrentTime - 10
-	if a.media.Media.Duration > 0 {
-		v = a.media.Media.Duration - 10
+	media := a.Media()
+	v := media.CurrentTime - 10
+	if media.Media.Duration > 0 {
+		v = media.Media.Duration - 10
 	}
 
-	return a.Seek(int(v))
+	return a.Seek(ctx, int(v))
 }
 
-func (a *Application) Seek(value int) error {
-	if a.media == nil {
+func (a *Application) Seek(ctx context.Context, value int) error {
+	app, media := a.Application(), a.Media()
+	if media == nil {
 		return ErrMediaNotYetInitialised
 	}
+	if isLive(media) {
+		return ErrSeekLiveMedia
+	}
+	if _, _, ok := transcodedPosition(media.Media.ContentId); ok {
+		return a.seekTranscoded(ctx, media, media.CurrentTime+float32(value))
+	}
 
 	// TODO: find a better way to handle when chromecast
 	// apps don't handle certain commands.
@@ -444,23 +676,23 @@ func (a *Application) Seek(value int) error {
 		"CC1AD845", // Default media
 	}
 
-	for _, app := range appsSeekTo {
-		if app == a.application.AppId {
-			absolute := a.media.CurrentTime + float32(value)
-			return a.SeekToTime(absolute)
+	for _, appID := range appsSeekTo {
+		if appID == app.AppId {
+			absolute := media.CurrentTime + float32(value)
+			return a.SeekToTime(ctx, absolute)
 		}
 	}
 
-	return a.sendMediaRecv(&cast.MediaHeader{
+	return a.sendMediaRecv(ctx, &cast.MediaHeader{
 		PayloadHeader:  cast.SeekHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 		RelativeTime:   float32(value),
 		ResumeState:    "PLAYBACK_START",
 	})
 }
 
-func (a *Application) SeekFromStart(value int) error {
-	if a.media == nil {
+func (a *Application) SeekFromStart(ctx context.Context, value int) error {
+	if a.Media() == nil {
 		return ErrMediaNotYetInitialised
 	}
 
@@ -468,38 +700,55 @@ func (a *Application) SeekFromStart(value int) error {
 	// TODO(vishen): can we unroll this, so it doesn't update the current state?
 	// but just returns it?
 	// that might also make a.media == nil checks pointless?
-	a.updateMediaStatus()
+	a.updateMediaStatus(ctx)
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	if isLive(media) {
+		return ErrSeekLiveMedia
+	}
+	if _, _, ok := transcodedPosition(media.Media.ContentId); ok {
+		return a.seekTranscoded(ctx, media, float32(value))
+	}
 
 	// TODO(vishen): maybe there is another ResumeState that lets us
-	// seek from the end? Although not sure how this works for live media?
+	// seek from the end?
 
-	return a.sendMediaRecv(&cast.MediaHeader{
+	return a.sendMediaRecv(ctx, &cast.MediaHeader{
 		PayloadHeader:  cast.SeekHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 		CurrentTime:    float32(value),
 		ResumeState:    "PLAYBACK_START",
 	})
 }
 
-func (a *Application) SeekToTime(value float32) error {
-	if a.media == nil {
+func (a *Application) SeekToTime(ctx context.Context, value float32) error {
+	media := a.Media()
+	if media == nil {
 		return ErrMediaNotYetInitialised
 	}
+	if isLive(media) {
+		return ErrSeekLiveMedia
+	}
+	if _, _, ok := transcodedPosition(media.Media.ContentId); ok {
+		return a.seekTranscoded(ctx, media, value)
+	}
 
-	return a.sendMediaRecv(&cast.MediaHeader{
+	return a.sendMediaRecv(ctx, &cast.MediaHeader{
 		PayloadHeader:  cast.SeekHeader,
-		MediaSessionId: a.media.MediaSessionId,
+		MediaSessionId: media.MediaSessionId,
 		CurrentTime:    value,
 		ResumeState:    "PLAYBACK_START",
 	})
 }
 
-func (a *Application) SetVolume(value float32) error {
+func (a *Application) SetVolume(ctx context.Context, value float32) error {
 	if value > 1 || value < 0 {
 		return ErrVolumeOutOfRange
 	}
 
-	return a.sendDefaultRecv(&cast.SetVolume{
+	return a.sendDefaultRecv(ctx, &cast.SetVolume{
 		PayloadHeader: cast.VolumeHeader,
 		Volume: cast.Volume{
 			Level: value,
@@ -507,8 +756,8 @@ func (a *Application) SetVolume(value float32) error {
 	})
 }
 
-func (a *Application) SetMuted(value bool) error {
-	return a.sendDefaultRecv(&cast.SetVolume{
+func (a *Application) SetMuted(ctx context.Context, value bool) error {
+	return a.sendDefaultRecv(ctx, &cast.SetVolume{
 		PayloadHeader: cast.VolumeHeader,
 		Volume: cast.Volume{
 			Muted: value,
@@ -516,29 +765,90 @@ func (a *Application) SetMuted(value bool) error {
 	})
 }
 
-func (a *Application) getMediaStatus() (*cast.MediaStatusResponse, error) {
-	apiMessage, err := a.sendAndWaitMediaRecv(&cast.GetStatusHeader)
+// SetPlaybackRate changes how fast the media plays, from half to twice
+// normal speed.
+func (a *Application) SetPlaybackRate(ctx context.Context, rate float32) error {
+	if rate < 0.5 || rate > 2 {
+		return ErrPlaybackRateOutOfRange
+	}
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+
+	// Wait for the new rate to be reported, so the next change starts from
+	// it.
+	apiMessage, err := a.sendAndWaitMediaRecv(ctx, &cast.SetPlaybackRate{
+		PayloadHeader:  cast.PlaybackRateHeader,
+		MediaSessionId: media.MediaSessionId,
+		PlaybackRate:   rate,
+	})
+	if err != nil {
+		return err
+	}
+	decoded, err := cast.Decode(apiMessage)
+	if err != nil {
+		return err
+	}
+	if _, ok := decoded.(*cast.MediaStatusResponse); !ok {
+		return unexpectedResponse(decoded)
+	}
+	return nil
+}
+
+// PlaybackRate is how fast the media is playing, 1 when nothing is.
+func (a *Application) PlaybackRate() float32 {
+	// Receivers that don't support changing the rate don't report it.
+	if media := a.Media(); media != nil && media.PlaybackRate != 0 {
+		return media.PlaybackRate
+	}
+	return 1
+}
+
+func (a *Application) getMediaStatus(ctx context.Context) (*cast.MediaStatusResponse, error) {
+	apiMessage, err := a.sendAndWaitMediaRecv(ctx, &cast.GetStatusHeader)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := cast.Decode(apiMessage)
 	if err != nil {
 		return nil, err
 	}
-	var response cast.MediaStatusResponse
-	if err := json.Unmarshal([]byte(*apiMessage.PayloadUtf8), &response); err != nil {
-		return nil, errors.Wrap(err, "error unmarshaling json")
+	response, ok := decoded.(*cast.MediaStatusResponse)
+	if !ok {
+		return nil, unexpectedResponse(decoded)
 	}
-	return &response, nil
+	return response, nil
 }
 
-func (a *Application) getReceiverStatus() (*cast.ReceiverStatusResponse, error) {
-	apiMessage, err := a.sendAndWaitDefaultRecv(&cast.GetStatusHeader)
+func (a *Application) getReceiverStatus(ctx context.Context) (*cast.ReceiverStatusResponse, error) {
+	apiMessage, err := a.sendAndWaitDefaultRecv(ctx, &cast.GetStatusHeader)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := cast.Decode(apiMessage)
 	if err != nil {
 		return nil, err
 	}
-	var response cast.ReceiverStatusResponse
-	if err := json.Unmarshal([]byte(*apiMessage.PayloadUtf8), &response); err != nil {
-		return nil, errors.Wrap(err, "error unmarshaling json")
+	response, ok := decoded.(*cast.ReceiverStatusResponse)
+	if !ok {
+		return nil, unexpectedResponse(decoded)
 	}
-	return &response, nil
+	return response, nil
+}
 
+// unexpectedResponse is the error for when the chromecast replied with
+// something other than what was asked for.
+func unexpectedResponse(decoded interface{}) error {
+	switch resp := decoded.(type) {
+	case *cast.InvalidRequestResponse:
+		return fmt.Errorf("invalid request: %s", resp.Reason)
+	case *cast.LaunchErrorResponse:
+		return fmt.Errorf("unable to launch: %s", resp.Reason)
+	case *cast.RawMessage:
+		return fmt.Errorf("unexpected response %q", resp.Type)
+	}
+	return fmt.Errorf("unexpected response %T", decoded)
 }
 
 func (a *Application) PlayableMediaType(filename string) bool {
@@ -570,6 +880,11 @@ func (a *Application) possibleContentType(filename string) (string, error) {
 		filename = parts[0]
 	}
 
+	// Smooth streaming manifests are at "<name>.ism/Manifest".
+	if strings.HasSuffix(strings.ToLower(filename), "/manifest") {
+		filename = path.Dir(filename)
+	}
+
 	// https://developers.google.com/cast/docs/media
 	switch ext := strings.ToLower(path.Ext(filename)); ext {
 	case ".jpg", ".jpeg":
@@ -594,6 +909,10 @@ func (a *Application) possibleContentType(filename string) (string, error) {
 		return "audio/wav", nil
 	case ".m3u8":
 		return "application/x-mpegURL", nil
+	case ".mpd":
+		return "application/dash+xml", nil
+	case ".ism", ".isml":
+		return "application/vnd.ms-sstr+xml", nil
 	default:
 		return "", fmt.Errorf("unknown file extension %q", ext)
 	}
@@ -607,27 +926,28 @@ func (a *Application) knownFileType(filename string) bool {
 }
 
 func (a *Application) PlayedItems() map[string]PlayedItem {
-	return a.playedItems
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	playedItems := make(map[string]PlayedItem, len(a.playedItems))
+	for k, v := range a.playedItems {
+		playedItems[k] = v
+	}
+	return playedItems
 }
 
-func (a *Application) Load(filenameOrUrl, contentType string, transcode, detach, forceDetach bool) error {
+func (a *Application) Load(ctx context.Context, filenameOrUrl, contentType string, transcode, detach, forceDetach bool, opts ...LoadOption) error {
+	options := newLoadOptions(opts)
+	generation := a.newServedGeneration()
 	var mi mediaItem
 	isExternalMedia := false
 	if strings.HasPrefix(filenameOrUrl, "http://") || strings.HasPrefix(filenameOrUrl, "https://") {
 		isExternalMedia = true
-		if contentType == "" {
-			var err error
-			contentType, err = a.possibleContentType(filenameOrUrl)
-			if err != nil {
-				return err
-			}
-		}
-		mi = mediaItem{
-			contentURL:  filenameOrUrl,
-			contentType: contentType,
+		var err error
+		if mi, err = a.urlMediaItem(ctx, filenameOrUrl, contentType, options); err != nil {
+			return err
 		}
 	} else {
-		mediaItems, err := a.loadAndServeFiles([]string{filenameOrUrl}, contentType, transcode)
+		mediaItems, err := a.loadAndServeFiles([]string{filenameOrUrl}, contentType, transcode, options)
 		if err != nil {
 			return errors.Wrap(err, "unable to load and serve files")
 		}
@@ -642,24 +962,45 @@ func (a *Application) Load(filenameOrUrl, contentType string, transcode, detach,
 		return fmt.Errorf("unable to detach from locally playing media content")
 	}
 
-	if err := a.ensureIsDefaultMediaReceiver(); err != nil {
+	subtitles, err := options.subtitlesFor(mi, true)
+	if err != nil {
+		return err
+	}
+	tracks, err := a.subtitleTracks(subtitles)
+	if err != nil {
+		return errors.Wrap(err, "unable to serve subtitles")
+	}
+
+	if err := a.ensureReceiver(ctx, options.appID); err != nil {
 		return err
 	}
 
-	// NOTE: This isn't concurrent safe, but it doesn't need to be at the moment!
-	a.MediaStart()
+	// Subscribe before sending the command, so we can't miss the media
+	// finishing.
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+
+	mi, currentTime := mi.startAt(a.startPosition(mi, options))
 
 	// Send the command to the chromecast
-	a.sendMediaRecv(&cast.LoadMediaCommand{
+	loadRequestID, err := a.sendMediaRecvRequest(ctx, &cast.LoadMediaCommand{
 		PayloadHeader: cast.LoadHeader,
-		CurrentTime:   0,
+		CurrentTime:   int(currentTime),
 		Autoplay:      true,
 		Media: cast.MediaItem{
 			ContentId:   mi.contentURL,
-			StreamType:  "BUFFERED",
+			StreamType:  mi.streamType(),
 			ContentType: mi.contentType,
+			Duration:    mi.duration,
+			Metadata:    options.metadataFor(mi, true),
+			Tracks:      tracks,
 		},
+		ActiveTrackIds: activeTrackIds(tracks),
 	})
+	if err != nil {
+		return errors.Wrap(err, "unable to load media")
+	}
+	a.expireServedFiles(generation)
 
 	// If we should detach from waiting for media to finish playing
 	// and this is a url loaded external media, then we can exit early.
@@ -668,75 +1009,121 @@ func (a *Application) Load(filenameOrUrl, contentType string, transcode, detach,
 	}
 
 	// Wait until we have been notified that the media has finished playing
-	a.MediaWait()
-	return nil
+	return a.waitForMedia(ctx, sub, loadRequestID)
 }
 
-func (a *Application) QueueLoad(filenames []string, contentType string, transcode bool) error {
+// WaitForMedia blocks until the media playing on the chromecast has
+// finished, or 'ctx' is done. Local files added with 'QueueInsert' are only
+// served while the application is open.
+func (a *Application) WaitForMedia(ctx context.Context) error {
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+	return a.waitForMedia(ctx, sub, 0)
+}
+
+// waitForMedia blocks until the media loaded after subscribing to 'sub' is
+// no longer playing, or 'ctx' is done. It fails if the request
+// 'loadRequestID' that loaded it does, 0 when it wasn't loaded here.
+func (a *Application) waitForMedia(ctx context.Context, sub *Subscription, loadRequestID int) error {
+	var interrupted <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-interrupted:
+			interrupted = nil
+			// Seeking in media being transcoded loads it again, which
+			// interrupts what was playing.
+			if media := a.Media(); media != nil && media.PlayerState != "IDLE" && a.servedFilename(media.Media.ContentId) != "" {
+				continue
+			}
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return ErrApplicationClosed
+			}
+			finished, err := mediaFinished(event, loadRequestID)
+			if !finished {
+				continue
+			}
+			if err == nil && interruptedMedia(event) && a.transcoding() {
+				interrupted = time.After(interruptedTimeout)
+				continue
+			}
+			return err
+		}
+	}
+}
 
-	mediaItems, err := a.loadAndServeFiles(filenames, contentType, transcode)
+func (a *Application) QueueLoad(ctx context.Context, filenames []string, contentType string, transcode bool, opts ...LoadOption) error {
+	options := newLoadOptions(opts)
+	generation := a.newServedGeneration()
+	mediaItems, err := a.loadAndServeFiles(filenames, contentType, transcode, options)
 	if err != nil {
 		return errors.Wrap(err, "unable to load and serve files")
 	}
+	if len(mediaItems) == 0 {
+		return fmt.Errorf("no media to play")
+	}
 
-	if err := a.ensureIsDefaultMediaReceiver(); err != nil {
+	if err := a.ensureReceiver(ctx, options.appID); err != nil {
 		return err
 	}
 
-	items := make([]cast.QueueLoadItem, len(mediaItems))
-	for i, mi := range mediaItems {
-		items[i] = cast.QueueLoadItem{
-			Autoplay:         true,
-			PlaybackDuration: 60,
-			Media: cast.MediaItem{
-				ContentId:   mi.contentURL,
-				StreamType:  "BUFFERED",
-				ContentType: mi.contentType,
-			},
-		}
+	startIndex := options.startIndex
+	if startIndex < 0 || startIndex >= len(mediaItems) {
+		startIndex = 0
+	}
+	var currentTime float32
+	mediaItems[startIndex], currentTime = mediaItems[startIndex].startAt(a.startPosition(mediaItems[startIndex], options))
+	items, err := a.queueItems(mediaItems, options)
+	if err != nil {
+		return err
 	}
 
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+
 	// Send the command to the chromecast
-	a.sendMediaRecv(&cast.QueueLoad{
+	loadRequestID, err := a.sendMediaRecvRequest(ctx, &cast.QueueLoad{
 		PayloadHeader: cast.QueueLoadHeader,
-		CurrentTime:   0,
-		StartIndex:    0,
-		RepeatMode:    "REPEAT_OFF",
+		CurrentTime:   currentTime,
+		StartIndex:    startIndex,
+		RepeatMode:    options.repeatMode,
 		Items:         items,
 	})
+	if err != nil {
+		return errors.Wrap(err, "unable to load media")
+	}
+	a.expireServedFiles(generation)
 
 	// Wait until we have been notified that the media has finished playing
-	// TODO: This does nothing. This hasn't been initialised and just blocks
-	// forever.
-	a.MediaWait()
-	return nil
+	return a.waitForMedia(ctx, sub, loadRequestID)
 }
 
-func (a *Application) ensureIsDefaultMediaReceiver() error {
-	// If the current chromecast application isn't the Default Media Receiver
-	// we need to change it.
-	if a.application == nil || a.application.AppId != defaultChromecastAppId {
-		_, err := a.sendAndWaitDefaultRecv(&cast.LaunchRequest{
-			PayloadHeader: cast.LaunchHeader,
-			AppId:         defaultChromecastAppId,
-		})
-
-		if err != nil {
-			return errors.Wrap(err, "unable to change to default media receiver")
+// ensureReceiver launches the receiver app 'appID', unless it is already
+// running.
+func (a *Application) ensureReceiver(ctx context.Context, appID string) error {
+	// If the current chromecast application isn't the receiver we need to
+	// change it.
+	if app := a.Application(); app == nil || app.AppId != appID {
+		if err := a.LaunchApp(ctx, appID); err != nil {
+			return errors.Wrapf(err, "unable to change to receiver %s", appID)
 		}
 		// Update the 'application' and 'media' field on the 'CastApplication'
-		return a.Update()
+		return a.Update(ctx)
 	}
 	return nil
 }
 
-func (a *Application) Slideshow(filenames []string, duration int, repeat bool) error {
-	mediaItems, err := a.loadAndServeFiles(filenames, "", false)
+func (a *Application) Slideshow(ctx context.Context, filenames []string, duration int, repeat bool) error {
+	generation := a.newServedGeneration()
+	mediaItems, err := a.loadAndServeFiles(filenames, "", false, loadOptions{})
 	if err != nil {
 		return errors.Wrap(err, "unable to load and serve files")
 	}
 
-	if err := a.ensureIsDefaultMediaReceiver(); err != nil {
+	if err := a.ensureReceiver(ctx, defaultChromecastAppId); err != nil {
 		return err
 	}
 
@@ -747,8 +1134,9 @@ func (a *Application) Slideshow(filenames []string, duration int, repeat bool) e
 			PlaybackDuration: duration,
 			Media: cast.MediaItem{
 				ContentId:   mi.contentURL,
-				StreamType:  "BUFFERED",
+				StreamType:  cast.StreamTypeBuffered,
 				ContentType: mi.contentType,
+				Metadata:    loadOptions{}.metadataFor(mi, false),
 			},
 		}
 	}
@@ -760,29 +1148,33 @@ func (a *Application) Slideshow(filenames []string, duration int, repeat bool) e
 		repeatMode = "REPEAT_OFF"
 	}
 
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+
 	// Send the command to the chromecast
-	a.sendMediaRecv(&cast.QueueLoad{
+	loadRequestID, err := a.sendMediaRecvRequest(ctx, &cast.QueueLoad{
 		PayloadHeader: cast.QueueLoadHeader,
 		CurrentTime:   0,
 		StartIndex:    0,
 		RepeatMode:    repeatMode,
 		Items:         items,
 	})
+	if err != nil {
+		return errors.Wrap(err, "unable to load media")
+	}
+	a.expireServedFiles(generation)
 
 	// Timer for when to call the next image
 	t := time.NewTicker(time.Second * time.Duration(duration))
-	i := len(filenames)
-	for {
-		//  If we are not repeating, we need to stop after we have show the last image.
-		if !repeat {
-			if i == 0 {
-				break
-			}
-			i--
-		}
+	defer t.Stop()
+	//  If we are not repeating, we need to stop after we have show the last image.
+	for i := len(filenames); repeat || i > 0; {
 		select {
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-t.C:
-			if err := a.Update(); err != nil {
+			i--
+			if err := a.Update(ctx); err != nil {
 				return err
 			}
 			// This is a hack because I can't work out how to
@@ -790,14 +1182,17 @@ func (a *Application) Slideshow(filenames []string, duration int, repeat bool) e
 			// in a slideshow. There is some documentation that
 			// implies it should be possible but I was not able to make it work.
 			// https://developers.google.com/cast/docs/reference/caf_receiver/cast.framework.messages.QueueItem.html#playbackDuration
-			if err := a.Next(); err != nil {
+			if err := a.Next(ctx); err != nil {
+				return err
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				return ErrApplicationClosed
+			}
+			// Media has finished playing.
+			if finished, err := mediaFinished(event, loadRequestID); finished {
 				return err
 			}
-		// Media has finished playing.
-		// TODO: Hack, how to ensure this is always possible
-		// to wait on.
-		case <-a.mediaFinished:
-			return nil
 		}
 	}
 	return nil
@@ -808,9 +1203,19 @@ type mediaItem struct {
 	contentType string
 	contentURL  string
 	transcode   bool
+	tags        *tags.Tags
+	coverArtURL string
+	// Live media, ie: internet radio or media being transcoded, has no
+	// duration and can't be seeked in.
+	live bool
+	// The duration, in seconds, of media being transcoded when it is
+	// known. It can be seeked in by transcoding from there.
+	duration float32
+	// Media transcoded to HLS, which can be seeked in as it is.
+	hls bool
 }
 
-func (a *Application) loadAndServeFiles(filenames []string, contentType string, transcode bool) ([]mediaItem, error) {
+func (a *Application) loadAndServeFiles(filenames []string, contentType string, transcode bool, options loadOptions) ([]mediaItem, error) {
 	mediaItems := make([]mediaItem, len(filenames))
 	for i, filename := range filenames {
 		transcodeFile := transcode
@@ -848,8 +1253,22 @@ func (a *Application) loadAndServeFiles(filenames []string, contentType string,
 			contentType: contentTypeToUse,
 			transcode:   transcodeFile,
 		}
-		// Add the filename to the list of filenames that go-chromecast will serve.
-		a.mediaFilenames = append(a.mediaFilenames, filename)
+		if transcodeFile {
+			// It is streamed as it is transcoded, which can only be seeked
+			// in if it is known how long it is.
+			duration, err := probeDuration(filename)
+			if err != nil {
+				a.log("unable to find the duration of %s: %v", filename, err)
+			}
+			mediaItems[i].duration = duration
+			mediaItems[i].live = duration <= 0
+			if options.hls {
+				mediaItems[i].contentType = hlsContentType
+				mediaItems[i].hls = true
+				mediaItems[i].live = false
+			}
+		}
+		a.readTags(&mediaItems[i])
 	}
 
 	localIP, err := a.getLocalIP()
@@ -868,7 +1287,13 @@ func (a *Application) loadAndServeFiles(filenames []string, contentType string,
 	// We can only set the content url after the server has started, otherwise we have
 	// no way to know the port used.
 	for i, m := range mediaItems {
-		mediaItems[i].contentURL = fmt.Sprintf("http://%s:%d?media_file=%s&live_streaming=%t", localIP, a.serverPort, m.filename, m.transcode)
+		mediaItems[i].contentURL = a.servedURL(localIP, servedMedia, m.filename, m.transcode)
+		if m.hls {
+			mediaItems[i].contentURL = a.servedURL(localIP, servedHLS, m.filename, true)
+		} else if m.transcode && m.duration > 0 {
+			mediaItems[i].contentURL = transcodedURL(mediaItems[i].contentURL, 0, m.duration)
+		}
+		mediaItems[i].coverArtURL = a.coverArtURL(m, localIP)
 	}
 
 	return mediaItems, nil
@@ -905,6 +1330,8 @@ func (a *Application) getLocalIP() (string, error) {
 }
 
 func (a *Application) startStreamingServer() error {
+	a.serverMu.Lock()
+	defer a.serverMu.Unlock()
 	if a.httpServer != nil {
 		return nil
 	}
@@ -918,72 +1345,51 @@ func (a *Application) startStreamingServer() error {
 	a.serverPort = listener.Addr().(*net.TCPAddr).Port
 	a.log("found available port :%d", a.serverPort)
 
-	a.httpServer = &http.Server{}
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Check to see if we have a 'filename' and if it is one of the ones that have
-		// already been validated and is useable.
-		filename := r.URL.Query().Get("media_file")
-		canServe := false
-		for _, fn := range a.mediaFilenames {
-			if fn == filename {
-				canServe = true
-			}
-		}
-
-		a.playedItems[filename] = PlayedItem{ContentID: filename, Started: time.Now().Unix()}
-		a.writePlayedItems()
-
-		// Check to see if this is a live streaming video and we need to use an
-		// infinite range request / response. This comes from media that is either
-		// live or currently being transcoded to a different media format.
-		liveStreaming := false
-		if ls := r.URL.Query().Get("live_streaming"); ls == "true" {
-			liveStreaming = true
-		}
-
-		a.log("canServe=%t, liveStreaming=%t, filename=%s", canServe, liveStreaming, filename)
-		if canServe {
-			if !liveStreaming {
-				http.ServeFile(w, r, filename)
+	mux := http.NewServeMux()
+	server := &http.Server{Handler: mux}
+	a.httpServer = server
+
+	mux.HandleFunc(servedPrefix, func(w http.ResponseWriter, r *http.Request) {
+		a.serveServed(w, r, func(w http.ResponseWriter, r *http.Request, f servedFile) {
+			// Media being transcoded to a different media format is
+			// streamed as an infinite response, rather than with range
+			// requests.
+			if f.transcode {
+				a.serveLiveStreaming(w, r, f.filename)
 			} else {
-				a.serveLiveStreaming(w, r, filename)
+				http.ServeFile(w, r, f.filename)
 			}
-		} else {
-			http.Error(w, "Invalid file", 400)
-		}
-		a.log("method=%s, headers=%v, reponse_headers=%v", r.Method, r.Header, w.Header())
-		pi := a.playedItems[filename]
-
-		// TODO(vishen): make this a pointer?
-		pi.Finished = time.Now().Unix()
-		a.playedItems[filename] = pi
-		a.writePlayedItems()
+		})
 	})
 
-	go func() {
-		a.log("media server listening on %d", a.serverPort)
-		if err := a.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.WithField("package", "application").WithError(err).Fatal("error serving HTTP")
+	a.pollOnce.Do(func() { go a.pollPositions() })
+
+	go func(port int) {
+		a.log("media server listening on %d", port)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			// Only this application's media stops being served, the next
+			// media loaded starts the server again.
+			log.WithField("package", "application").WithError(err).Error("error serving HTTP")
+			a.serverMu.Lock()
+			defer a.serverMu.Unlock()
+			server.Close()
+			if a.httpServer == server {
+				a.httpServer = nil
+			}
 		}
-	}()
+	}(a.serverPort)
 
 	return nil
 }
 
 func (a *Application) serveLiveStreaming(w http.ResponseWriter, r *http.Request, filename string) {
-	cmd := exec.Command(
-		"ffmpeg",
-		"-re", // encode at 1x playback speed, to not burn the CPU
-		"-i", filename,
-		"-vcodec", "h264",
-		"-acodec", "aac",
-		"-ac", "2", // chromecasts don't support more than two audio channels
-		"-f", "mp4",
-		"-movflags", "frag_keyframe+faststart",
-		"-strict", "-experimental",
-		"pipe:1",
-	)
+	// Seeking transcodes again from the new position.
+	var start float32
+	if s, err := strconv.ParseFloat(r.URL.Query().Get("start"), 32); err == nil && s > 0 {
+		start = float32(s)
+	}
+	// Stop transcoding when the chromecast, or the application, hangs up.
+	cmd := exec.CommandContext(r.Context(), "ffmpeg", transcodeArgs(filename, start, a.transcodePlan(filename, "mp4"))...)
 
 	cmd.Stdout = w
 	if a.debug {
@@ -1006,32 +1412,70 @@ func (a *Application) log(message string, args ...interface{}) {
 	}
 }
 
-func (a *Application) send(payload cast.Payload, sourceID, destinationID, namespace string) (int, error) {
-	// NOTE: Not concurrent safe, but currently only synchronous flow is possible
-	// TODO(vishen): just make concurrent safe regardless of current flow
-	requestID += 1
+func nextRequestID() int {
+	return int(atomic.AddInt64(&requestID, 1))
+}
+
+// withRequestID sets the request id on the payload. The known headers in
+// 'cast' are shared between every request, and custom payloads belong to
+// the caller, so those are copied rather than modified.
+func withRequestID(payload cast.Payload, requestID int) cast.Payload {
+	switch p := payload.(type) {
+	case *cast.PayloadHeader:
+		h := *p
+		payload = &h
+	case cast.CustomPayload:
+		// Don't modify the caller's map.
+		c := make(cast.CustomPayload, len(p)+1)
+		for k, v := range p {
+			c[k] = v
+		}
+		payload = c
+	}
 	payload.SetRequestId(requestID)
+	return payload
+}
+
+func (a *Application) send(ctx context.Context, payload cast.Payload, sourceID, destinationID, namespace string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	requestID := nextRequestID()
+	payload = withRequestID(payload, requestID)
 	return requestID, a.conn.Send(requestID, payload, sourceID, destinationID, namespace)
 }
 
-func (a *Application) sendAndWait(payload cast.Payload, sourceID, destinationID, namespace string) (*pb.CastMessage, error) {
-	requestID, err := a.send(payload, sourceID, destinationID, namespace)
-	if err != nil {
+func (a *Application) sendAndWait(ctx context.Context, payload cast.Payload, sourceID, destinationID, namespace string) (*pb.CastMessage, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	requestID := nextRequestID()
+	payload = withRequestID(payload, requestID)
 
-	// Set a timeout to wait for the response
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	// TODO(vishen): not concurrent safe. Not a problem at the moment
-	// because only synchronous flow currently allowed.
+	// Register for the response before sending the request, otherwise
+	// the response could arrive before we are waiting for it.
 	resultChan := make(chan *pb.CastMessage, 1)
+	a.resultMu.Lock()
 	a.resultChanMap[requestID] = resultChan
+	a.resultMu.Unlock()
 	defer func() {
+		a.resultMu.Lock()
 		delete(a.resultChanMap, requestID)
+		a.resultMu.Unlock()
 	}()
 
+	if err := a.conn.Send(requestID, payload, sourceID, destinationID, namespace); err != nil {
+		return nil, err
+	}
+
+	// Don't wait forever on a response that might never come, unless
+	// the caller has said how long to wait.
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultResponseTimeout)
+		defer cancel()
+	}
+
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -1042,133 +1486,92 @@ func (a *Application) sendAndWait(payload cast.Payload, sourceID, destinationID,
 
 // TODO(vishen): needing send(AndWait)* method seems a bit clunky, is there a better approach?
 // Maybe having a struct that has send and sendAndWait, similar to before.
-func (a *Application) sendDefaultConn(payload cast.Payload) error {
-	_, err := a.send(payload, defaultSender, defaultRecv, namespaceConn)
+func (a *Application) sendDefaultConn(ctx context.Context, payload cast.Payload) error {
+	_, err := a.send(ctx, payload, defaultSender, defaultRecv, namespaceConn)
 	return err
 }
 
-func (a *Application) sendDefaultRecv(payload cast.Payload) error {
-	_, err := a.send(payload, defaultSender, defaultRecv, namespaceRecv)
+func (a *Application) sendDefaultRecv(ctx context.Context, payload cast.Payload) error {
+	_, err := a.send(ctx, payload, defaultSender, defaultRecv, namespaceRecv)
 	return err
 }
 
-func (a *Application) sendMediaConn(payload cast.Payload) error {
-	if a.application == nil {
+func (a *Application) sendMediaConn(ctx context.Context, payload cast.Payload) error {
+	app := a.Application()
+	if app == nil {
 		return ErrApplicationNotSet
 	}
-	_, err := a.send(payload, defaultSender, a.application.TransportId, namespaceConn)
+	_, err := a.send(ctx, payload, defaultSender, app.TransportId, namespaceConn)
 	return err
 }
 
-func (a *Application) sendMediaRecv(payload cast.Payload) error {
-	if a.application == nil {
-		return ErrApplicationNotSet
-	}
-	_, err := a.send(payload, defaultSender, a.application.TransportId, namespaceMedia)
+func (a *Application) sendMediaRecv(ctx context.Context, payload cast.Payload) error {
+	_, err := a.sendMediaRecvRequest(ctx, payload)
 	return err
 }
 
-func (a *Application) sendAndWaitDefaultConn(payload cast.Payload) (*pb.CastMessage, error) {
-	return a.sendAndWait(payload, defaultSender, defaultRecv, namespaceConn)
+// sendMediaRecvRequest is 'sendMediaRecv', returning the id of the request
+// so the reply to it, ie: a LOAD_FAILED, can be told apart.
+func (a *Application) sendMediaRecvRequest(ctx context.Context, payload cast.Payload) (int, error) {
+	app := a.Application()
+	if app == nil {
+		return 0, ErrApplicationNotSet
+	}
+	return a.send(ctx, payload, defaultSender, app.TransportId, namespaceMedia)
+}
+
+func (a *Application) sendAndWaitDefaultConn(ctx context.Context, payload cast.Payload) (*pb.CastMessage, error) {
+	return a.sendAndWait(ctx, payload, defaultSender, defaultRecv, namespaceConn)
 }
 
-func (a *Application) sendAndWaitDefaultRecv(payload cast.Payload) (*pb.CastMessage, error) {
-	return a.sendAndWait(payload, defaultSender, defaultRecv, namespaceRecv)
+func (a *Application) sendAndWaitDefaultRecv(ctx context.Context, payload cast.Payload) (*pb.CastMessage, error) {
+	return a.sendAndWait(ctx, payload, defaultSender, defaultRecv, namespaceRecv)
 }
 
-func (a *Application) sendAndWaitMediaConn(payload cast.Payload) (*pb.CastMessage, error) {
-	if a.application == nil {
+func (a *Application) sendAndWaitMediaConn(ctx context.Context, payload cast.Payload) (*pb.CastMessage, error) {
+	app := a.Application()
+	if app == nil {
 		return nil, ErrApplicationNotSet
 	}
-	return a.sendAndWait(payload, defaultSender, a.application.TransportId, namespaceConn)
+	return a.sendAndWait(ctx, payload, defaultSender, app.TransportId, namespaceConn)
 }
 
-func (a *Application) sendAndWaitMediaRecv(payload cast.Payload) (*pb.CastMessage, error) {
-	if a.application == nil {
+func (a *Application) sendAndWaitMediaRecv(ctx context.Context, payload cast.Payload) (*pb.CastMessage, error) {
+	app := a.Application()
+	if app == nil {
 		return nil, ErrApplicationNotSet
 	}
-	return a.sendAndWait(payload, defaultSender, a.application.TransportId, namespaceMedia)
+	return a.sendAndWait(ctx, payload, defaultSender, app.TransportId, namespaceMedia)
 }
 
-func (a *Application) startTranscodingServer(command string) error {
-	if a.httpServer != nil {
-		return nil
-	}
-	a.log("trying to find available port to start transcoding server on")
+// serveCommandOutput streams what the command of 'f' writes to stdout.
+func (a *Application) serveCommandOutput(w http.ResponseWriter, r *http.Request, f servedFile) {
+	args := strings.Split(f.command, " ")
+	cmd := exec.CommandContext(r.Context(), args[0], args[1:]...)
 
-	listener, err := net.Listen("tcp", ":0")
-	if err != nil {
-		return errors.Wrap(err, "unable to bind to local tcp address")
+	cmd.Stdout = w
+	if a.debug {
+		cmd.Stderr = os.Stderr
 	}
 
-	a.serverPort = listener.Addr().(*net.TCPAddr).Port
-	a.log("found available port :%d", a.serverPort)
-
-	a.httpServer = &http.Server{}
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Check to see if we have a 'filename' and if it is one of the ones that have
-		// already been validated and is useable.
-		filename := r.URL.Query().Get("media_file")
-		canServe := false
-		for _, fn := range a.mediaFilenames {
-			if fn == filename {
-				canServe = true
-			}
-		}
-
-		a.playedItems[filename] = PlayedItem{ContentID: filename, Started: time.Now().Unix()}
-		a.writePlayedItems()
-
-		a.log("canServe=%t, liveStreaming=%t, filename=%s", canServe, true, filename)
-		if canServe {
-			args := strings.Split(command, " ")
-			cmd := exec.Command(args[0], args[1:]...)
-
-			cmd.Stdout = w
-			if a.debug {
-				cmd.Stderr = os.Stderr
-			}
-
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Transfer-Encoding", "chunked")
-
-			if err := cmd.Run(); err != nil {
-				log.WithField("package", "application").WithFields(logrus.Fields{
-					"filename": filename,
-				}).WithError(err).Error("error transcoding")
-			}
-		} else {
-			http.Error(w, "Invalid file", 400)
-		}
-		a.log("method=%s, headers=%v, reponse_headers=%v", r.Method, r.Header, w.Header())
-		pi := a.playedItems[filename]
-
-		// TODO(vishen): make this a pointer?
-		pi.Finished = time.Now().Unix()
-		a.playedItems[filename] = pi
-		a.writePlayedItems()
-	})
-
-	go func() {
-		a.log("media server listening on %d", a.serverPort)
-		if err := a.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.WithField("package", "application").WithError(err).Fatal("error serving HTTP")
-		}
-	}()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Transfer-Encoding", "chunked")
 
-	return nil
+	if err := cmd.Run(); err != nil {
+		log.WithField("package", "application").WithFields(logrus.Fields{
+			"filename": f.filename,
+		}).WithError(err).Error("error transcoding")
+	}
 }
 
-func (a *Application) Transcode(command string, contentType string) error {
+func (a *Application) Transcode(ctx context.Context, command string, contentType string) error {
 
 	if command == "" || contentType == "" {
 		return errors.New("command and content-type flags needs to be set when transcoding")
 	}
 
+	// The output of the command is served as this.
 	filename := "pipe_output"
-	// Add the filename to the list of filenames that go-chromecast will serve.
-	a.mediaFilenames = append(a.mediaFilenames, filename)
 
 	localIP, err := a.getLocalIP()
 	if err != nil {
@@ -1176,37 +1579,43 @@ func (a *Application) Transcode(command string, contentType string) error {
 	}
 	a.log("local IP address: %s", localIP)
 
-	a.log("starting transcoding server...")
+	a.log("starting streaming server...")
 	// Start server to serve the media
-	if err := a.startTranscodingServer(command); err != nil {
-		return errors.Wrap(err, "unable to start transcoding server")
+	if err := a.startStreamingServer(); err != nil {
+		return errors.Wrap(err, "unable to start streaming server")
 	}
-	a.log("started transcoding server")
+	a.log("started streaming server")
 
 	// We can only set the content url after the server has started, otherwise we have
 	// no way to know the port used.
-	contentURL := fmt.Sprintf("http://%s:%d?media_file=%s", localIP, a.serverPort, filename)
+	generation := a.newServedGeneration()
+	contentURL := a.servedCommandURL(localIP, filename, command)
 
-	if err := a.ensureIsDefaultMediaReceiver(); err != nil {
+	if err := a.ensureReceiver(ctx, defaultChromecastAppId); err != nil {
 		return err
 	}
 
-	// NOTE: This isn't concurrent safe, but it doesn't need to be at the moment!
-	a.MediaStart()
+	// Subscribe before sending the command, so we can't miss the media
+	// finishing.
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
 
 	// Send the command to the chromecast
-	a.sendMediaRecv(&cast.LoadMediaCommand{
+	loadRequestID, err := a.sendMediaRecvRequest(ctx, &cast.LoadMediaCommand{
 		PayloadHeader: cast.LoadHeader,
 		CurrentTime:   0,
 		Autoplay:      true,
 		Media: cast.MediaItem{
 			ContentId:   contentURL,
-			StreamType:  "BUFFERED",
+			StreamType:  cast.StreamTypeLive,
 			ContentType: contentType,
 		},
 	})
+	if err != nil {
+		return errors.Wrap(err, "unable to load media")
+	}
+	a.expireServedFiles(generation)
 
 	// Wait until we have been notified that the media has finished playing
-	a.MediaWait()
-	return nil
+	return a.waitForMedia(ctx, sub, loadRequestID)
 }
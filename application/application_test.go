@@ -0,0 +1,732 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vishen/go-chromecast/cast"
+	"github.com/vishen/go-chromecast/cast/casttest"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+const testMediaURL = "http://example.com/media.mp4"
+
+func startReceiver(t *testing.T) *casttest.Receiver {
+	t.Helper()
+	receiver, err := casttest.NewReceiver()
+	if err != nil {
+		t.Fatalf("unable to start receiver: %v", err)
+	}
+	t.Cleanup(func() { receiver.Close() })
+	return receiver
+}
+
+// localClient only reaches servers on this machine, no test asks the
+// internet what a url is.
+var localClient = &http.Client{Transport: localTransport{}}
+
+type localTransport struct{}
+
+func (localTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if host := req.URL.Hostname(); host != "127.0.0.1" && host != "localhost" && host != "::1" {
+		return nil, fmt.Errorf("tests don't reach %s", req.URL.Host)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func startApplication(t *testing.T, receiver *casttest.Receiver, opts ...ApplicationOption) *Application {
+	t.Helper()
+	app := NewApplication(append([]ApplicationOption{WithCacheDisabled(true), WithHTTPClient(localClient)}, opts...)...)
+	addr, port := receiver.Addr()
+	if err := app.Start(context.Background(), addr, port); err != nil {
+		t.Fatalf("unable to start application: %v", err)
+	}
+	t.Cleanup(func() { app.Close(false) })
+	return app
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 10)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestApplicationConcurrentUse(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	if err := app.Load(context.Background(), testMediaURL, "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	if err := app.Update(context.Background()); err != nil {
+		t.Fatalf("unable to update: %v", err)
+	}
+
+	calls := []func(context.Context) error{
+		app.Update,
+		app.Pause,
+		app.Unpause,
+		func(ctx context.Context) error { return app.SetVolume(ctx, 0.3) },
+		func(context.Context) error {
+			if castApplication, _, _ := app.Status(); castApplication == nil {
+				t.Error("application status is missing")
+			}
+			return nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, call := range calls {
+			wg.Add(1)
+			go func(call func(context.Context) error) {
+				defer wg.Done()
+				if err := call(context.Background()); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(call)
+		}
+	}
+	wg.Wait()
+
+	if media := app.Media(); media == nil || media.Media.ContentId != testMediaURL {
+		t.Fatalf("unexpected media status: %#v", media)
+	}
+	if volume := receiver.Volume(); volume.Level != 0.3 {
+		t.Fatalf("receiver volume is %0.2f, expected 0.30", volume.Level)
+	}
+}
+
+func TestApplicationRepeatedReplies(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	receiver.RepeatReplies(3)
+	for i := 0; i < 5; i++ {
+		if err := app.SetVolume(context.Background(), 0.5); err != nil {
+			t.Fatalf("unable to set volume: %v", err)
+		}
+	}
+
+	receiver.RepeatReplies(1)
+
+	// A reply for a request that is still registered, but whose caller
+	// has stopped reading, as happens when the replies arrive before it
+	// gives up waiting.
+	const requestID = -100
+	app.resultMu.Lock()
+	app.resultChanMap[requestID] = make(chan *pb.CastMessage, 1)
+	app.resultMu.Unlock()
+	payload := fmt.Sprintf(`{"type":"RECEIVER_STATUS","requestId":%d,"status":{}}`, requestID)
+	namespace := "urn:x-cast:com.google.cast.receiver"
+	for i := 0; i < 3; i++ {
+		app.recvMsgChan <- &pb.CastMessage{Namespace: &namespace, PayloadUtf8: &payload}
+	}
+
+	// The replies nobody is waiting for mustn't hold up what is received
+	// after them.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := app.Update(ctx); err != nil {
+		t.Fatalf("unable to update after repeated replies: %v", err)
+	}
+}
+
+func TestApplicationLoadWaitsForMediaToFinish(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	done := make(chan error, 1)
+	go func() { done <- app.Load(context.Background(), testMediaURL, "", false, false, false) }()
+
+	waitFor(t, "media to start playing", func() bool {
+		media := receiver.Media()
+		return media != nil && media.PlayerState == "PLAYING"
+	})
+	select {
+	case err := <-done:
+		t.Fatalf("load returned before the media finished: %v", err)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	receiver.FinishMedia()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("load never returned after the media finished")
+	}
+}
+
+func TestApplicationLoadCanceled(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Load(ctx, testMediaURL, "", false, false, false) }()
+
+	waitFor(t, "media to start playing", func() bool {
+		media := receiver.Media()
+		return media != nil && media.PlayerState == "PLAYING"
+	})
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("got error %v, expected %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("load never returned after being canceled")
+	}
+}
+
+func TestApplicationLoadFailed(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	receiver.FailNextLoad()
+
+	done := make(chan error, 1)
+	go func() { done <- app.Load(context.Background(), testMediaURL, "", false, false, false) }()
+	select {
+	case err := <-done:
+		if err != ErrLoadFailed {
+			t.Fatalf("got error %v, expected %v", err, ErrLoadFailed)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("load never returned after LOAD_FAILED")
+	}
+}
+
+func TestApplicationLoadFailedOtherRequest(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	done := make(chan error, 1)
+	go func() { done <- app.Load(context.Background(), testMediaURL, "", false, false, false) }()
+	waitFor(t, "media to start playing", func() bool { return receiver.Media() != nil })
+
+	// Some other request failing doesn't stop the media that was loaded.
+	app.publish(LoadFailed{RequestID: -1})
+	select {
+	case err := <-done:
+		t.Fatalf("load returned %v when another request failed", err)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	receiver.FinishMedia()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error waiting for the media: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("load never returned after the media finished")
+	}
+}
+
+func TestApplicationReconnect(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver, WithReconnect(5))
+	if err := app.Load(context.Background(), testMediaURL, "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "media to start playing", func() bool { return receiver.Media() != nil })
+
+	// The application is never taken for gone while it reconnects.
+	stop, gone := make(chan struct{}), make(chan bool, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				gone <- false
+				return
+			default:
+			}
+			if app.Disconnected() {
+				gone <- true
+				return
+			}
+		}
+	}()
+
+	receiver.DropConnections()
+	waitFor(t, "the connection to be dropped", func() bool { return !app.Connected() || app.Reconnecting() })
+	waitFor(t, "the application to reconnect", func() bool { return app.Connected() && !app.Reconnecting() })
+	close(stop)
+	if <-gone {
+		t.Fatal("application was disconnected while it was reconnecting")
+	}
+
+	// The media session should have been re-joined, so controlling the
+	// media still works.
+	if err := app.Pause(context.Background()); err != nil {
+		t.Fatalf("unable to pause after reconnecting: %v", err)
+	}
+	waitFor(t, "media to pause", func() bool { return receiver.Media().PlayerState == "PAUSED" })
+}
+
+func TestApplicationDisconnected(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	if app.Disconnected() {
+		t.Fatal("application is disconnected before the connection was dropped")
+	}
+	receiver.DropConnections()
+	waitFor(t, "the application to be disconnected", app.Disconnected)
+}
+
+func TestApplicationEvents(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	// Never read from, which mustn't hold up anyone else.
+	stalled := app.Subscribe()
+	defer stalled.Unsubscribe()
+
+	sub := app.Subscribe()
+	defer sub.Unsubscribe()
+
+	if err := app.Load(context.Background(), testMediaURL, "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	if err := app.SetVolume(context.Background(), 0.5); err != nil {
+		t.Fatalf("unable to set volume: %v", err)
+	}
+
+	var (
+		applicationChanged, mediaPlaying, volumeChanged bool
+	)
+	timeout := time.After(time.Second * 10)
+	for !applicationChanged || !mediaPlaying || !volumeChanged {
+		select {
+		case event := <-sub.Events():
+			switch e := event.(type) {
+			case ApplicationChanged:
+				applicationChanged = e.Current.AppId == casttest.DefaultMediaReceiverAppID
+			case MediaStatusChanged:
+				for _, status := range e.Status {
+					mediaPlaying = mediaPlaying || status.PlayerState == "PLAYING"
+				}
+			case VolumeChanged:
+				volumeChanged = volumeChanged || e.Volume.Level == 0.5
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for events: application changed=%t, media playing=%t, volume changed=%t", applicationChanged, mediaPlaying, volumeChanged)
+		}
+	}
+
+	sub.Unsubscribe()
+	for range sub.Events() {
+		// Drained until closed.
+	}
+}
+
+func TestApplicationCloseEndsSubscriptions(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	sub := app.Subscribe()
+	app.Close(false)
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case _, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("subscription was not closed with the application")
+		}
+	}
+}
+
+func TestApplicationSlowSubscriber(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	sub := app.Subscribe()
+	defer sub.Unsubscribe()
+	const published = maxQueuedEvents * 2
+	for i := 1; i <= published; i++ {
+		app.publish(LoadFailed{RequestID: i})
+	}
+
+	// Only the newest events are kept for a subscriber that isn't reading,
+	// besides the one that may already be on its way.
+	received := 0
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case event := <-sub.Events():
+			e, ok := event.(LoadFailed)
+			if !ok {
+				continue
+			}
+			received++
+			if e.RequestID != published {
+				continue
+			}
+			if received > maxQueuedEvents+1 {
+				t.Fatalf("received %d events, expected at most %d", received, maxQueuedEvents+1)
+			}
+			return
+		case <-timeout:
+			t.Fatalf("timed out waiting for the newest event, received %d", received)
+		}
+	}
+}
+
+func TestApplicationAddMessageFunc(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	received := make(chan *pb.CastMessage, 1)
+	app.AddMessageFunc(func(msg *pb.CastMessage) {
+		select {
+		case received <- msg:
+		default:
+		}
+	})
+	if err := app.SetVolume(context.Background(), 0.5); err != nil {
+		t.Fatalf("unable to set volume: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second * 5):
+		t.Fatal("message func was never called")
+	}
+}
+
+func TestApplicationReplay(t *testing.T) {
+	var capture bytes.Buffer
+	recorder := cast.NewCaptureRecorder(&capture)
+
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver, WithRecorder(recorder))
+	done := make(chan error, 1)
+	go func() { done <- app.Load(context.Background(), testMediaURL, "", false, false, false) }()
+	waitFor(t, "media to start playing", func() bool {
+		media := receiver.Media()
+		return media != nil && media.PlayerState == "PLAYING"
+	})
+	if err := app.Pause(context.Background()); err != nil {
+		t.Fatalf("unable to pause: %v", err)
+	}
+	waitFor(t, "media to pause", func() bool { return receiver.Media().PlayerState == "PAUSED" })
+	receiver.FinishMedia()
+	if err := <-done; err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	app.Close(false)
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("unable to record: %v", err)
+	}
+
+	replay := func() ([]string, *cast.Media) {
+		replayed := NewApplication(WithCacheDisabled(true))
+		defer replayed.Close(false)
+		sub := replayed.Subscribe()
+		defer sub.Unsubscribe()
+
+		go func() {
+			if err := replayed.Replay(context.Background(), bytes.NewReader(capture.Bytes())); err != nil {
+				t.Errorf("unable to replay: %v", err)
+			}
+		}()
+		var events []string
+		for event := range sub.Events() {
+			switch e := event.(type) {
+			case Disconnected:
+				return events, replayed.Media()
+			case MediaStatusChanged:
+				for _, status := range e.Status {
+					events = append(events, status.PlayerState)
+				}
+			case ApplicationChanged:
+				events = append(events, e.Current.AppId)
+			}
+		}
+		t.Fatal("replay never finished")
+		return nil, nil
+	}
+
+	events, media := replay()
+	if media == nil || media.PlayerState != "IDLE" || media.IdleReason != "FINISHED" {
+		t.Fatalf("unexpected media status after replay: %#v", media)
+	}
+	for _, expected := range []string{casttest.DefaultMediaReceiverAppID, "PLAYING", "PAUSED", "IDLE"} {
+		found := false
+		for _, event := range events {
+			found = found || event == expected
+		}
+		if !found {
+			t.Fatalf("%s is missing from the replayed events %v", expected, events)
+		}
+	}
+
+	// Replaying is deterministic.
+	again, _ := replay()
+	if strings.Join(again, ",") != strings.Join(events, ",") {
+		t.Fatalf("replaying again gave events %v, expected %v", again, events)
+	}
+}
+
+func TestApplicationCustomApp(t *testing.T) {
+	const (
+		appID     = "1A2B3C4D"
+		namespace = "urn:x-cast:com.example.app"
+	)
+	receiver := startReceiver(t)
+	receiver.HandleNamespace(namespace, func(payload []byte) interface{} {
+		var request cast.CustomPayload
+		json.Unmarshal(payload, &request)
+		return cast.CustomPayload{"type": "WELCOME", "requestId": request["requestId"], "name": request["name"]}
+	})
+	app := startApplication(t, receiver)
+
+	if err := app.LaunchApp(context.Background(), appID); err != nil {
+		t.Fatalf("unable to launch app: %v", err)
+	}
+	castApplication := app.Application()
+	if castApplication == nil || castApplication.AppId != appID {
+		t.Fatalf("unexpected application after launch: %#v", castApplication)
+	}
+	waitFor(t, "a virtual connection to the launched app", func() bool {
+		for _, message := range receiver.MessagesOfType("CONNECT") {
+			if message.GetDestinationId() == castApplication.TransportId {
+				return true
+			}
+		}
+		return false
+	})
+
+	payload := cast.CustomPayload{"type": "HELLO", "name": "test"}
+	reply, err := app.SendMessageAndWait(context.Background(), namespace, payload)
+	if err != nil {
+		t.Fatalf("unable to send message: %v", err)
+	}
+	var response cast.CustomPayload
+	if err := json.Unmarshal([]byte(reply.GetPayloadUtf8()), &response); err != nil {
+		t.Fatalf("unable to decode reply: %v", err)
+	}
+	if response["type"] != "WELCOME" || response["name"] != "test" {
+		t.Fatalf("unexpected reply: %v", response)
+	}
+	if _, ok := payload["requestId"]; ok {
+		t.Fatal("the caller's payload was modified")
+	}
+
+	sub := app.Subscribe()
+	defer sub.Unsubscribe()
+	if err := app.SendMessage(context.Background(), namespace, payload); err != nil {
+		t.Fatalf("unable to send message: %v", err)
+	}
+	timeout := time.After(time.Second * 10)
+	for {
+		select {
+		case event := <-sub.Events():
+			if e, ok := event.(MessageReceived); ok && e.Message.GetNamespace() == namespace {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the app's reply")
+		}
+	}
+}
+
+func TestApplicationSetPlaybackRate(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	if err := app.SetPlaybackRate(ctx, 1.5); err != ErrMediaNotYetInitialised {
+		t.Fatalf("changing the rate with nothing playing returned %v, expected %v", err, ErrMediaNotYetInitialised)
+	}
+	if err := app.Load(ctx, "http://example.com/lecture.mp3", "audio/mpeg", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+
+	if err := app.SetPlaybackRate(ctx, 1.5); err != nil {
+		t.Fatalf("unable to change playback rate: %v", err)
+	}
+	if rate := app.PlaybackRate(); rate != 1.5 {
+		t.Fatalf("playback rate is %0.2f, expected 1.50", rate)
+	}
+	if rate := receiver.Media().PlaybackRate; rate != 1.5 {
+		t.Fatalf("receiver playback rate is %0.2f, expected 1.50", rate)
+	}
+	for _, rate := range []float32{0.25, 3} {
+		if err := app.SetPlaybackRate(ctx, rate); err != ErrPlaybackRateOutOfRange {
+			t.Fatalf("changing the rate to %0.2f returned %v, expected %v", rate, err, ErrPlaybackRateOutOfRange)
+		}
+	}
+}
+
+func TestApplicationStreamingServers(t *testing.T) {
+	dir := tempDir(t)
+	fetch := func(app *Application, contentURL string) (int, error) {
+		u, err := url.Parse(contentURL)
+		if err != nil {
+			return 0, err
+		}
+		u.Host = fmt.Sprintf("127.0.0.1:%d", app.serverPort)
+		resp, err := http.Get(u.String())
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	// Two devices, as with the http server, each serving their own media.
+	var apps []*Application
+	var contentURLs []string
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte("not really a movie"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		receiver := startReceiver(t)
+		app := startApplication(t, receiver)
+		if err := app.Load(context.Background(), filename, "", false, false, true); err != nil {
+			t.Fatalf("unable to load %s: %v", name, err)
+		}
+		waitFor(t, "the media to load", func() bool { return receiver.Media() != nil })
+		contentURL := receiver.Media().Media.ContentId
+		if strings.Contains(contentURL, dir) || !strings.HasSuffix(contentURL, "/"+name) {
+			t.Fatalf("media is served at %s, which says where it is", contentURL)
+		}
+		apps, contentURLs = append(apps, app), append(contentURLs, contentURL)
+	}
+	if apps[0].serverPort == apps[1].serverPort {
+		t.Fatalf("both applications serve on port %d", apps[0].serverPort)
+	}
+	for i, app := range apps {
+		if code, err := fetch(app, contentURLs[i]); err != nil || code != http.StatusOK {
+			t.Fatalf("unable to fetch its own media: %d %v", code, err)
+		}
+		if code, err := fetch(app, contentURLs[1-i]); err != nil || code != http.StatusNotFound {
+			t.Fatalf("fetching the other application's media returned %d %v, expected %d", code, err, http.StatusNotFound)
+		}
+	}
+
+	if err := apps[0].Close(false); err != nil {
+		t.Fatalf("unable to close: %v", err)
+	}
+	if _, err := fetch(apps[0], contentURLs[0]); err == nil {
+		t.Fatalf("media is still served after closing")
+	}
+	if code, err := fetch(apps[1], contentURLs[1]); err != nil || code != http.StatusOK {
+		t.Fatalf("unable to fetch media of the open application: %d %v", code, err)
+	}
+	if apps[0].servedFilename(contentURLs[0]) != "" {
+		t.Fatalf("media url still works after closing")
+	}
+}
+
+func TestApplicationServedFilesExpire(t *testing.T) {
+	dir := tempDir(t)
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	var contentURLs []string
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte("not really a movie"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Load(context.Background(), filename, "", false, false, true); err != nil {
+			t.Fatalf("unable to load %s: %v", name, err)
+		}
+		waitFor(t, "the media to load", func() bool {
+			media := receiver.Media()
+			return media != nil && strings.HasSuffix(media.Media.ContentId, "/"+name)
+		})
+		contentURLs = append(contentURLs, receiver.Media().Media.ContentId)
+	}
+
+	// Only the media that was loaded last is still served.
+	if app.servedFilename(contentURLs[0]) != "" {
+		t.Fatalf("%s is still served once other media was loaded", contentURLs[0])
+	}
+	if app.servedFilename(contentURLs[1]) == "" {
+		t.Fatalf("%s isn't served", contentURLs[1])
+	}
+}
+
+func TestApplicationTranscodeAfterLoad(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "movie.mp4")
+	if err := ioutil.WriteFile(filename, []byte("not really a movie"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	if err := app.Load(context.Background(), filename, "", false, false, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return receiver.Media() != nil })
+	loaded := receiver.Media().Media.ContentId
+
+	// The streaming server is already running, and serves the command too.
+	go app.Transcode(context.Background(), "echo transcoded", "video/mp4")
+	waitFor(t, "the command output to load", func() bool { return receiver.Media().Media.ContentId != loaded })
+	u, err := url.Parse(receiver.Media().Media.ContentId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Host = fmt.Sprintf("127.0.0.1:%d", app.serverPort)
+	resp, err := http.Get(u.String())
+	if err != nil {
+		t.Fatalf("unable to fetch the command output: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "transcoded\n" {
+		t.Fatalf("command output returned %d %q", resp.StatusCode, body)
+	}
+	// It isn't media that can be played again.
+	if _, ok := app.PlayedItems()["pipe_output"]; ok {
+		t.Fatalf("the command output is recorded as played: %v", app.PlayedItems())
+	}
+}
+
+func TestApplicationServeToDeviceOnly(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver, WithServeToDeviceOnly(true))
+	path := app.serveFile(servedMedia, "/videos/movie.mp4", false)
+
+	// The receiver is on the loopback address too.
+	r := httptest.NewRequest("GET", path, nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	if !app.allowedClient(r) {
+		t.Fatalf("the device isn't allowed its media")
+	}
+	r.RemoteAddr = "192.0.2.1:1234"
+	if app.allowedClient(r) {
+		t.Fatalf("another client is allowed the media")
+	}
+	w := httptest.NewRecorder()
+	app.serveServed(w, r, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("serving another client returned %d, expected %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,183 @@
+package application
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishen/go-chromecast/tags"
+)
+
+// coverArtFilenames are the images next to media that are its cover art,
+// in the order they are looked for.
+var coverArtFilenames = []string{
+	"cover.jpg", "cover.jpeg", "cover.png",
+	"folder.jpg", "folder.jpeg", "folder.png",
+	"front.jpg", "front.png",
+	"poster.jpg", "poster.png",
+}
+
+// thumbnailOffsets are how far, in seconds, into a video the thumbnail is
+// grabbed from. Further in skips any opening titles, but short videos need
+// it from the start.
+var thumbnailOffsets = []string{"60", "0"}
+
+// coverArt is where the cover art of a media file comes from.
+type coverArt struct {
+	// An image next to the media file.
+	filename string
+	// The picture in the tags of the media file, it is read again when
+	// asked for rather than kept around.
+	embedded bool
+	// A frame of the video, grabbed with ffmpeg when first asked for.
+	thumbnail     bool
+	thumbnailData []byte
+}
+
+// findCoverArt returns where the cover art of 'mi' comes from, or nil if it
+// has none.
+func findCoverArt(mi mediaItem) *coverArt {
+	// Photos are their own art.
+	if mi.filename == "" || strings.HasPrefix(mi.contentType, "image/") {
+		return nil
+	}
+	if mi.tags != nil && mi.tags.Picture != nil {
+		return &coverArt{embedded: true}
+	}
+	if filename := findCoverArtFile(filepath.Dir(mi.filename)); filename != "" {
+		return &coverArt{filename: filename}
+	}
+	if strings.HasPrefix(mi.contentType, "video/") || mi.hls {
+		if _, err := exec.LookPath("ffmpeg"); err == nil {
+			return &coverArt{thumbnail: true}
+		}
+	}
+	return nil
+}
+
+// findCoverArtFile returns the cover art image in 'dir', ignoring the case
+// of its name.
+func findCoverArtFile(dir string) string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	names := map[string]string{}
+	for _, f := range files {
+		if !f.IsDir() {
+			names[strings.ToLower(f.Name())] = f.Name()
+		}
+	}
+	for _, name := range coverArtFilenames {
+		if found, ok := names[name]; ok {
+			return filepath.Join(dir, found)
+		}
+	}
+	return ""
+}
+
+// coverArtURL returns the url the cover art of 'mi' is served at, or "" if
+// it has none. The streaming server must already be started.
+func (a *Application) coverArtURL(mi mediaItem, localIP string) string {
+	art := findCoverArt(mi)
+	if art == nil {
+		return ""
+	}
+	a.servedMu.Lock()
+	a.coverArt[mi.filename] = art
+	a.servedMu.Unlock()
+	a.log("cover art for %s: %+v", mi.filename, art)
+	return a.servedURL(localIP, servedCoverArt, mi.filename, false)
+}
+
+// serveCoverArt serves the cover art of the media file 'filename'.
+func (a *Application) serveCoverArt(w http.ResponseWriter, r *http.Request, filename string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	a.servedMu.Lock()
+	art, ok := a.coverArt[filename]
+	a.servedMu.Unlock()
+	if !ok {
+		http.Error(w, "Invalid file", 400)
+		return
+	}
+
+	switch {
+	case art.filename != "":
+		http.ServeFile(w, r, art.filename)
+	case art.embedded:
+		t, err := tags.Read(filename)
+		if err != nil || t.Picture == nil {
+			http.Error(w, "Unable to read cover art", 500)
+			return
+		}
+		serveImage(w, r, t.Picture.MIMEType, t.Picture.Data)
+	case art.thumbnail:
+		data, err := a.thumbnail(filename, art)
+		if err != nil {
+			log.WithField("package", "application").WithField("filename", filename).WithError(err).Error("error grabbing thumbnail")
+			http.Error(w, "Unable to grab thumbnail", 500)
+			return
+		}
+		serveImage(w, r, "image/jpeg", data)
+	}
+}
+
+// thumbnail returns a frame of the video 'filename' as a jpeg, grabbing it
+// the first time.
+func (a *Application) thumbnail(filename string, art *coverArt) ([]byte, error) {
+	a.servedMu.Lock()
+	data := art.thumbnailData
+	a.servedMu.Unlock()
+	if data != nil {
+		return data, nil
+	}
+
+	var err error
+	for _, offset := range thumbnailOffsets {
+		cmd := exec.Command(
+			"ffmpeg",
+			"-ss", offset,
+			"-i", filename,
+			"-vf", "thumbnail,scale=480:-2", // the most representative of the next frames
+			"-frames:v", "1",
+			"-f", "image2",
+			"-c:v", "mjpeg",
+			"pipe:1",
+		)
+		if a.debug {
+			cmd.Stderr = os.Stderr
+		}
+		if data, err = cmd.Output(); err == nil && len(data) > 0 {
+			break
+		}
+	}
+	if len(data) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no frames in video")
+		}
+		return nil, err
+	}
+
+	a.servedMu.Lock()
+	art.thumbnailData = data
+	a.servedMu.Unlock()
+	return data, nil
+}
+
+func serveImage(w http.ResponseWriter, r *http.Request, mimeType string, data []byte) {
+	// Taggers don't always use the proper type, ie: "image/jpg".
+	if mimeType != "image/jpeg" && mimeType != "image/png" {
+		mimeType = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", mimeType)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
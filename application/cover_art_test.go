@@ -0,0 +1,98 @@
+package application
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishen/go-chromecast/tags"
+)
+
+func TestFindCoverArt(t *testing.T) {
+	album, movies := tempDir(t), tempDir(t)
+	cover := filepath.Join(album, "Folder.JPG")
+	if err := ioutil.WriteFile(cover, []byte("jpeg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := exec.LookPath("ffmpeg")
+	haveFFmpeg := err == nil
+
+	tests := []struct {
+		name     string
+		mi       mediaItem
+		expected *coverArt
+	}{
+		{
+			name: "url",
+			mi:   mediaItem{contentURL: "http://example.com/song.mp3", contentType: "audio/mp3"},
+		},
+		{
+			name: "photo",
+			mi:   mediaItem{filename: filepath.Join(album, "photo.jpg"), contentType: "image/jpeg"},
+		},
+		{
+			name:     "embedded",
+			mi:       mediaItem{filename: filepath.Join(album, "song.mp3"), contentType: "audio/mp3", tags: &tags.Tags{Picture: &tags.Picture{}}},
+			expected: &coverArt{embedded: true},
+		},
+		{
+			name:     "file",
+			mi:       mediaItem{filename: filepath.Join(album, "song.mp3"), contentType: "audio/mp3", tags: &tags.Tags{Title: "Song"}},
+			expected: &coverArt{filename: cover},
+		},
+		{
+			name: "no art",
+			mi:   mediaItem{filename: filepath.Join(movies, "song.mp3"), contentType: "audio/mp3"},
+		},
+	}
+	if haveFFmpeg {
+		tests = append(tests, struct {
+			name     string
+			mi       mediaItem
+			expected *coverArt
+		}{
+			name:     "thumbnail",
+			mi:       mediaItem{filename: filepath.Join(movies, "movie.mkv"), contentType: "video/mp4", transcode: true},
+			expected: &coverArt{thumbnail: true},
+		})
+	}
+	for _, test := range tests {
+		got := findCoverArt(test.mi)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: found %+v, expected %+v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestServeCoverArt(t *testing.T) {
+	dir := tempDir(t)
+	media, cover := filepath.Join(dir, "song.mp3"), filepath.Join(dir, "cover.png")
+	png := []byte("\x89PNG\r\n\x1a\n")
+	if err := ioutil.WriteFile(cover, png, 0600); err != nil {
+		t.Fatal(err)
+	}
+	app := NewApplication()
+	app.coverArt[media] = &coverArt{filename: cover}
+	path := app.serveFile(servedCoverArt, media, false)
+
+	w := httptest.NewRecorder()
+	app.serveServed(w, httptest.NewRequest("GET", path, nil), nil)
+	if w.Code != http.StatusOK || w.Body.String() != string(png) || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("unexpected cover art response %d %v: %q", w.Code, w.Header(), w.Body.String())
+	}
+	if strings.Contains(path, dir) || !strings.HasSuffix(path, "/cover") {
+		t.Fatalf("cover art is served at %s, which says where it is", path)
+	}
+
+	// Only the cover art of media being served is served.
+	w = httptest.NewRecorder()
+	app.serveServed(w, httptest.NewRequest("GET", servedPrefix+newToken()+"/cover", nil), nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("serving unknown cover art returned %d, expected %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,63 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vishen/go-chromecast/cast"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+// LaunchApp launches the receiver app 'appID' on the chromecast, unless it
+// is already running, and opens a virtual connection to it so it can be
+// sent messages with 'SendMessage'.
+func (a *Application) LaunchApp(ctx context.Context, appID string) error {
+	if app := a.Application(); app == nil || app.AppId != appID {
+		apiMessage, err := a.sendAndWaitDefaultRecv(ctx, &cast.LaunchRequest{
+			PayloadHeader: cast.LaunchHeader,
+			AppId:         appID,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "unable to launch %s", appID)
+		}
+		decoded, err := cast.Decode(apiMessage)
+		if err != nil {
+			return errors.Wrapf(err, "unable to launch %s", appID)
+		}
+		if _, ok := decoded.(*cast.ReceiverStatusResponse); !ok {
+			return errors.Wrapf(unexpectedResponse(decoded), "unable to launch %s", appID)
+		}
+	}
+
+	// The application has already been updated from the reply, see
+	// 'handleMessage'.
+	app := a.Application()
+	if app == nil || app.AppId != appID {
+		return fmt.Errorf("chromecast didn't launch %s", appID)
+	}
+	return a.sendMediaConn(ctx, &cast.ConnectHeader)
+}
+
+// SendMessage sends 'payload' to the running app on 'namespace'. Anything
+// the app sends back is delivered as a 'MessageReceived' event.
+func (a *Application) SendMessage(ctx context.Context, namespace string, payload cast.Payload) error {
+	app := a.Application()
+	if app == nil {
+		return ErrApplicationNotSet
+	}
+	_, err := a.send(ctx, payload, defaultSender, app.TransportId, namespace)
+	return err
+}
+
+// SendMessageAndWait sends 'payload' to the running app on 'namespace',
+// and waits for the reply with the same request id. Not every app replies
+// to every message, for those use 'SendMessage' instead.
+func (a *Application) SendMessageAndWait(ctx context.Context, namespace string, payload cast.Payload) (*pb.CastMessage, error) {
+	app := a.Application()
+	if app == nil {
+		return nil, ErrApplicationNotSet
+	}
+	return a.sendAndWait(ctx, payload, defaultSender, app.TransportId, namespace)
+}
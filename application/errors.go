@@ -3,7 +3,11 @@ package application
 import "github.com/pkg/errors"
 
 var (
+	ErrApplicationClosed      = errors.New("application is closed")
 	ErrApplicationNotSet      = errors.New("application isn't set")
+	ErrInvalidDeviceProfile   = errors.New("device profile must be one of chromecast, ultra, google-tv or audio")
+	ErrInvalidRepeatMode      = errors.New("repeat mode must be one of off, all, single or all-and-shuffle")
+	ErrLoadFailed             = errors.New("chromecast was unable to load the media")
 	ErrMediaNotYetInitialised = errors.New("media not yet initialised")
 	ErrNoMediaNext            = errors.New("media not yet initialised, there is nothing to go to next")
 	ErrNoMediaPause           = errors.New("media not yet initialised, there is nothing to pause")
@@ -11,5 +15,8 @@ var (
 	ErrNoMediaSkip            = errors.New("media not yet initialised, there is nothing to skip")
 	ErrNoMediaStop            = errors.New("media not yet initialised, there is nothing to stop")
 	ErrNoMediaUnpause         = errors.New("media not yet initialised, there is nothing to unpause")
+	ErrPlaybackRateOutOfRange = errors.New("specified playback rate is out of range (0.5 - 2)")
+	ErrSeekLiveMedia          = errors.New("live media can't be seeked in")
+	ErrTrackNotFound          = errors.New("media has no such track")
 	ErrVolumeOutOfRange       = errors.New("specified volume is out of range (0 - 1)")
 )
@@ -0,0 +1,262 @@
+package application
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/vishen/go-chromecast/cast"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+// Event is something that happened on the chromecast. Events are delivered
+// to every 'Subscription' of the application, and are one of the types
+// below.
+type Event interface {
+	isEvent()
+}
+
+// ReceiverStatusChanged is sent whenever the chromecast reports the status
+// of the receiver, either unprompted or in reply to a request.
+type ReceiverStatusChanged struct {
+	// Id of the request this is a reply to, 0 when it was broadcast.
+	RequestID    int
+	Applications []cast.Application
+	Volume       cast.Volume
+}
+
+// MediaStatusChanged is sent whenever the chromecast reports the status of
+// the media session, either unprompted or in reply to a request.
+type MediaStatusChanged struct {
+	// Id of the request this is a reply to, 0 when it was broadcast.
+	RequestID int
+	Status    []cast.Media
+
+	// Whether this was the reply to a status request made by the
+	// application, rather than the media changing.
+	reply bool
+}
+
+// LoadFailed is sent when the chromecast was unable to load the requested
+// media.
+type LoadFailed struct {
+	RequestID int
+}
+
+// ApplicationChanged is sent when the application running on the chromecast
+// changes, 'Previous' is nil if there wasn't an application before.
+type ApplicationChanged struct {
+	Previous *cast.Application
+	Current  *cast.Application
+}
+
+// VolumeChanged is sent when the receiver volume changes.
+type VolumeChanged struct {
+	Volume cast.Volume
+}
+
+// Disconnected is sent when the connection to the chromecast has been lost
+// and could not be re-established.
+type Disconnected struct {
+	Err error
+}
+
+// MessageReceived carries every raw message received from the chromecast,
+// including the ones that are also delivered as one of the typed events.
+type MessageReceived struct {
+	Message *pb.CastMessage
+}
+
+func (ReceiverStatusChanged) isEvent() {}
+func (MediaStatusChanged) isEvent()    {}
+func (LoadFailed) isEvent()            {}
+func (ApplicationChanged) isEvent()    {}
+func (VolumeChanged) isEvent()         {}
+func (Disconnected) isEvent()          {}
+func (MessageReceived) isEvent()       {}
+
+// maxQueuedEvents is how many events a subscription holds for a subscriber
+// that isn't keeping up, before the oldest are dropped.
+const maxQueuedEvents = 1024
+
+// Subscription receives the events of an application. Events are queued
+// per subscription, so a slow subscriber never holds up the others, or the
+// application itself. A subscriber that falls more than 'maxQueuedEvents'
+// behind misses the oldest of them.
+type Subscription struct {
+	a      *Application
+	events chan Event
+
+	mu      sync.Mutex
+	queue   []Event
+	pending chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Subscribe returns a subscription to all events from now on. The
+// subscription must be unsubscribed once it is no longer needed.
+func (a *Application) Subscribe() *Subscription {
+	s := &Subscription{
+		a:       a,
+		events:  make(chan Event),
+		pending: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	a.subscribersMu.Lock()
+	a.subscribers[s] = struct{}{}
+	a.subscribersMu.Unlock()
+
+	go s.deliver()
+	return s
+}
+
+// CastMessageFunc is called with every message received from the
+// chromecast.
+type CastMessageFunc func(*pb.CastMessage)
+
+// AddMessageFunc calls 'f' with every message received from the chromecast
+// from now on, until the application is closed.
+//
+// Deprecated: use 'Subscribe' and handle the 'MessageReceived' events.
+func (a *Application) AddMessageFunc(f CastMessageFunc) {
+	sub := a.Subscribe()
+	go func() {
+		for event := range sub.Events() {
+			if e, ok := event.(MessageReceived); ok {
+				f(e.Message)
+			}
+		}
+	}()
+}
+
+// Events returns the channel the events are delivered on. It is closed
+// once the subscription is unsubscribed, or the application is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Unsubscribe stops the delivery of events, any queued events are dropped.
+func (s *Subscription) Unsubscribe() {
+	s.a.subscribersMu.Lock()
+	delete(s.a.subscribers, s)
+	s.a.subscribersMu.Unlock()
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *Subscription) publish(event Event) {
+	s.mu.Lock()
+	if len(s.queue) >= maxQueuedEvents {
+		s.a.log("subscriber isn't keeping up, dropping %T", s.queue[0])
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, event)
+	s.mu.Unlock()
+	select {
+	case s.pending <- struct{}{}:
+	default:
+		// Already signalled.
+	}
+}
+
+func (s *Subscription) deliver() {
+	defer close(s.events)
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.pending:
+		}
+
+		// Events are taken one at a time, so those not yet read stay in
+		// the queue, where the oldest can be dropped.
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			event := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			select {
+			case <-s.done:
+				return
+			case s.events <- event:
+			}
+		}
+	}
+}
+
+func (a *Application) publish(events ...Event) {
+	a.subscribersMu.Lock()
+	defer a.subscribersMu.Unlock()
+	for s := range a.subscribers {
+		for _, event := range events {
+			s.publish(event)
+		}
+	}
+}
+
+func (a *Application) unsubscribeAll() {
+	a.subscribersMu.Lock()
+	subscribers := a.subscribers
+	a.subscribers = map[*Subscription]struct{}{}
+	a.subscribersMu.Unlock()
+	for s := range subscribers {
+		s.Unsubscribe()
+	}
+}
+
+// mediaFinished reports whether 'event' means the media that was loaded by
+// the request 'loadRequestID' is no longer going to play, and the error if
+// that wasn't because it finished playing.
+func mediaFinished(event Event, loadRequestID int) (bool, error) {
+	switch e := event.(type) {
+	case LoadFailed:
+		// Only the LOAD that was sent, other requests on the application
+		// fail on their own.
+		if loadRequestID == 0 || e.RequestID != loadRequestID {
+			return false, nil
+		}
+		return true, ErrLoadFailed
+	case Disconnected:
+		return true, errors.Wrap(e.Err, "lost connection to chromecast")
+	case ApplicationChanged:
+		// It is likely not this running instance that changed the
+		// application, so whatever we loaded has been replaced.
+		return e.Previous != nil && e.Current.AppId != e.Previous.AppId, nil
+	case MediaStatusChanged:
+		// Replies to status requests only repeat what has already been
+		// reported.
+		if e.reply {
+			return false, nil
+		}
+		for _, status := range e.Status {
+			// The LoadingItemId is only set when there is a playlist and there
+			// is an item being loaded to play next.
+			if status.IdleReason == "FINISHED" && status.LoadingItemId == 0 {
+				return true, nil
+			} else if status.IdleReason == "INTERRUPTED" && status.Media.ContentId == "" {
+				// This can happen when we go "next" in a playlist when it
+				// is playing the last track.
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// interruptedMedia reports whether 'event' is the media being interrupted,
+// ie: by other media being loaded.
+func interruptedMedia(event Event) bool {
+	if e, ok := event.(MediaStatusChanged); ok {
+		for _, status := range e.Status {
+			if status.IdleReason == "INTERRUPTED" {
+				return true
+			}
+		}
+	}
+	return false
+}
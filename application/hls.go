@@ -0,0 +1,256 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	hlsContentType = "application/x-mpegURL"
+	hlsPlaylist    = "playlist.m3u8"
+	// hlsSegmentDuration is how long, in seconds, each segment is.
+	hlsSegmentDuration = "6"
+	// hlsWaitTimeout is how long a request waits for ffmpeg to write the
+	// playlist or segment it is for.
+	hlsWaitTimeout = 30 * time.Second
+)
+
+// hlsSegment matches the names of the segments ffmpeg writes, which say
+// where it transcoded from when that isn't the start.
+var hlsSegment = regexp.MustCompile(`^segment(?:([0-9]+)-)?[0-9]+\.ts$`)
+
+// WithHLS transcodes media to HLS, a playlist of segments, rather than a
+// single stream. It can be seeked in as far as it has been transcoded, and
+// survives the chromecast reconnecting, but uses disk space while playing.
+func WithHLS() LoadOption {
+	return func(o *loadOptions) {
+		o.hls = true
+	}
+}
+
+// hlsStream is media being transcoded to HLS, in its own temporary
+// directory.
+type hlsStream struct {
+	dir    string
+	cancel context.CancelFunc
+	// Closed once ffmpeg has finished, 'err' is why it failed.
+	done chan struct{}
+	err  error
+}
+
+// hlsKey is what an HLS stream is of, the media 'filename' transcoded from
+// 'start' milliseconds into it.
+type hlsKey struct {
+	filename string
+	start    int64
+}
+
+// hlsStreams are the media files being transcoded to HLS.
+type hlsStreams struct {
+	mu      sync.Mutex
+	streams map[hlsKey]*hlsStream
+	closed  bool
+}
+
+// hlsSegmentPrefix is what the names of the segments of media transcoded
+// from 'start' milliseconds into it begin with.
+func hlsSegmentPrefix(start int64) string {
+	if start <= 0 {
+		return "segment"
+	}
+	return fmt.Sprintf("segment%d-", start)
+}
+
+// hlsArgs are the arguments to ffmpeg to transcode 'filename' to HLS in
+// 'dir', from 'start' milliseconds into it, as 'plan' says. The playlist
+// is an event playlist until it is finished, when it becomes a VOD
+// playlist.
+func hlsArgs(filename, dir string, start int64, plan transcodePlan) []string {
+	var args []string
+	if start > 0 {
+		// Before the input, so it seeks rather than decoding up to it.
+		args = append(args, "-ss", strconv.FormatFloat(float64(start)/1000, 'f', 3, 64))
+	}
+	args = append(args, "-i", filename)
+	args = append(args, plan.args()...)
+	return append(args,
+		"-f", "hls",
+		"-hls_time", hlsSegmentDuration,
+		"-hls_playlist_type", "event",
+		"-hls_flags", "temp_file", // don't serve segments that are being written
+		"-hls_segment_filename", filepath.Join(dir, hlsSegmentPrefix(start)+"%05d.ts"),
+		filepath.Join(dir, hlsPlaylist),
+	)
+}
+
+// hlsStart returns where, in milliseconds, the media the HLS playlist or
+// segment 'name' is for was transcoded from. The playlist url says so the
+// same way as media streamed as it is transcoded, the segments are named
+// after it.
+func hlsStart(r *http.Request, name string) int64 {
+	if name == hlsPlaylist {
+		start, err := strconv.ParseFloat(r.URL.Query().Get("start"), 64)
+		if err != nil || start <= 0 {
+			return 0
+		}
+		return int64(math.Round(start * 1000))
+	}
+	m := hlsSegment.FindStringSubmatch(name)
+	if m == nil || m[1] == "" {
+		return 0
+	}
+	start, _ := strconv.ParseInt(m[1], 10, 64)
+	return start
+}
+
+// hlsStream returns the HLS stream of 'filename' from 'start' milliseconds
+// into it, starting to transcode it the first time it is asked for, or
+// again once transcoding it failed. Only one stream of each file is kept,
+// seeking outside of what has been transcoded starts another.
+func (a *Application) hlsStream(filename string, start int64) (*hlsStream, error) {
+	a.hls.mu.Lock()
+	defer a.hls.mu.Unlock()
+	if a.hls.closed {
+		return nil, ErrApplicationClosed
+	}
+	key := hlsKey{filename: filename, start: start}
+	if s, ok := a.hls.streams[key]; ok {
+		return s, nil
+	}
+	for k, s := range a.hls.streams {
+		if k.filename == filename {
+			delete(a.hls.streams, k)
+			go a.removeHLSStream(s)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-chromecast-hls-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create directory for HLS")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &hlsStream{dir: dir, cancel: cancel, done: make(chan struct{})}
+	cmd := exec.CommandContext(ctx, "ffmpeg", hlsArgs(filename, dir, start, a.transcodePlan(filename, "hls"))...)
+	if a.debug {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		os.RemoveAll(dir)
+		return nil, errors.Wrap(err, "unable to start ffmpeg")
+	}
+	a.log("transcoding %s to HLS in %s", filename, dir)
+	go func() {
+		defer close(s.done)
+		if s.err = cmd.Wait(); s.err == nil || ctx.Err() != nil {
+			return
+		}
+		log.WithField("package", "application").WithField("filename", filename).WithError(s.err).Error("error transcoding to HLS")
+		// What it got through isn't kept, the next request starts again.
+		a.hls.mu.Lock()
+		if a.hls.streams[key] == s {
+			delete(a.hls.streams, key)
+		}
+		a.hls.mu.Unlock()
+		cancel()
+		if err := os.RemoveAll(dir); err != nil {
+			a.log("unable to remove %s: %v", dir, err)
+		}
+	}()
+	a.hls.streams[key] = s
+	return s, nil
+}
+
+// serveHLS serves the playlist, or a segment, of the media 'filename'
+// transcoded to HLS.
+func (a *Application) serveHLS(w http.ResponseWriter, r *http.Request, filename string) {
+	// The receiver fetches HLS with cross-origin requests.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	contentType := "video/mp2t"
+	switch {
+	case name == hlsPlaylist:
+		contentType = hlsContentType
+	case !hlsSegment.MatchString(name):
+		http.NotFound(w, r)
+		return
+	}
+
+	s, err := a.hlsStream(filename, hlsStart(r, name))
+	if err != nil {
+		log.WithField("package", "application").WithField("filename", filename).WithError(err).Error("error transcoding to HLS")
+		http.Error(w, "Unable to transcode", 500)
+		return
+	}
+	path := filepath.Join(s.dir, name)
+	if !waitForFile(r.Context(), path, s.done) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if name == hlsPlaylist {
+		// It changes until it has been transcoded.
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeFile(w, r, path)
+}
+
+// waitForFile waits for ffmpeg to write 'path', until it is 'done' or the
+// request is. It reports whether the file is there.
+func waitForFile(ctx context.Context, path string, done <-chan struct{}) bool {
+	timeout := time.NewTimer(hlsWaitTimeout)
+	defer timeout.Stop()
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+		select {
+		case <-done:
+			_, err := os.Stat(path)
+			return err == nil
+		case <-ctx.Done():
+			return false
+		case <-timeout.C:
+			return false
+		case <-tick.C:
+		}
+	}
+}
+
+// closeHLS stops transcoding to HLS, and removes what was transcoded.
+func (a *Application) closeHLS() {
+	a.hls.mu.Lock()
+	streams := a.hls.streams
+	a.hls.streams = map[hlsKey]*hlsStream{}
+	a.hls.closed = true
+	a.hls.mu.Unlock()
+	for _, s := range streams {
+		a.removeHLSStream(s)
+	}
+}
+
+// removeHLSStream stops transcoding 's', and removes what was transcoded.
+func (a *Application) removeHLSStream(s *hlsStream) {
+	s.cancel()
+	<-s.done
+	if err := os.RemoveAll(s.dir); err != nil {
+		a.log("unable to remove %s: %v", s.dir, err)
+	}
+}
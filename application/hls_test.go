@@ -0,0 +1,147 @@
+package application
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHLSArgs(t *testing.T) {
+	plan := transcodePlan{unknown: true, profile: defaultDeviceProfile}
+	args := hlsArgs("/videos/movie.avi", "/tmp/hls", 0, plan)
+	if !reflect.DeepEqual(args[:2], []string{"-i", "/videos/movie.avi"}) {
+		t.Fatalf("unexpected ffmpeg arguments %q", args)
+	}
+	if args[len(args)-1] != "/tmp/hls/playlist.m3u8" {
+		t.Fatalf("ffmpeg writes the playlist to %q, expected /tmp/hls/playlist.m3u8", args[len(args)-1])
+	}
+	if !strings.Contains(strings.Join(args, " "), "-hls_segment_filename /tmp/hls/segment%05d.ts") {
+		t.Fatalf("unexpected ffmpeg arguments %q", args)
+	}
+
+	// Transcoding from partway through seeks, and names the segments after
+	// where it started.
+	args = hlsArgs("/videos/movie.avi", "/tmp/hls", 90500, plan)
+	if !reflect.DeepEqual(args[:4], []string{"-ss", "90.500", "-i", "/videos/movie.avi"}) {
+		t.Fatalf("unexpected ffmpeg arguments %q", args)
+	}
+	if !strings.Contains(strings.Join(args, " "), "-hls_segment_filename /tmp/hls/segment90500-%05d.ts") {
+		t.Fatalf("unexpected ffmpeg arguments %q", args)
+	}
+}
+
+func TestHLSStart(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected int64
+	}{
+		{"/m/token/playlist.m3u8", 0},
+		{"/m/token/playlist.m3u8?start=90.5&duration=600", 90500},
+		{"/m/token/segment00003.ts", 0},
+		{"/m/token/segment90500-00003.ts", 90500},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if start := hlsStart(r, name); start != test.expected {
+			t.Errorf("%s starts at %dms, expected %dms", test.path, start, test.expected)
+		}
+	}
+}
+
+func TestApplicationServeHLS(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+
+	// Pretend ffmpeg has already transcoded the media.
+	dir, err := ioutil.TempDir("", "go-chromecast-hls-test-")
+	if err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	const playlist = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.0,\nsegment00000.ts\n#EXT-X-ENDLIST\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, hlsPlaylist), []byte(playlist), 0644); err != nil {
+		t.Fatalf("unable to write playlist: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "segment00000.ts"), []byte("ts"), 0644); err != nil {
+		t.Fatalf("unable to write segment: %v", err)
+	}
+	done := make(chan struct{})
+	close(done)
+	app.hls.streams[hlsKey{filename: "/videos/movie.avi"}] = &hlsStream{dir: dir, cancel: func() {}, done: done}
+
+	playlistPath := app.serveFile(servedHLS, "/videos/movie.avi", true)
+	if !strings.HasSuffix(playlistPath, "/"+hlsPlaylist) {
+		t.Fatalf("playlist served at %s, expected it to end in %s", playlistPath, hlsPlaylist)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.serveServed(w, httptest.NewRequest("GET", path, nil), nil)
+		return w
+	}
+
+	w := get(playlistPath)
+	if w.Code != http.StatusOK || w.Body.String() != playlist {
+		t.Fatalf("playlist returned %d %q", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != hlsContentType {
+		t.Fatalf("playlist served as %q, expected %q", contentType, hlsContentType)
+	}
+	segmentPath := strings.TrimSuffix(playlistPath, hlsPlaylist) + "segment00000.ts"
+	if w := get(segmentPath); w.Code != http.StatusOK || w.Body.String() != "ts" {
+		t.Fatalf("segment returned %d %q", w.Code, w.Body.String())
+	}
+	for _, name := range []string{"segment00001.ts", "movie.avi", "..%2Fmovie.avi"} {
+		path := strings.TrimSuffix(playlistPath, hlsPlaylist) + name
+		if w := get(path); w.Code != http.StatusNotFound {
+			t.Fatalf("%s returned %d, expected %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+
+	app.closeHLS()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("%s is still there once closed: %v", dir, err)
+	}
+	if _, err := app.hlsStream("/videos/movie.avi", 0); err != ErrApplicationClosed {
+		t.Fatalf("transcoding to HLS once closed returned %v, expected %v", err, ErrApplicationClosed)
+	}
+}
+
+func TestApplicationHLSFailedTranscode(t *testing.T) {
+	// An ffmpeg that always fails.
+	bin := tempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("unable to write ffmpeg: %v", err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	t.Cleanup(func() { os.Setenv("PATH", path) })
+
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	failed, err := app.hlsStream("/videos/movie.avi", 0)
+	if err != nil {
+		t.Fatalf("unable to start transcoding: %v", err)
+	}
+	<-failed.done
+	if failed.err == nil {
+		t.Fatal("transcoding didn't fail")
+	}
+	if _, err := os.Stat(failed.dir); !os.IsNotExist(err) {
+		t.Fatalf("%s is still there once transcoding failed: %v", failed.dir, err)
+	}
+
+	// It is transcoded again, rather than failing from then on.
+	retried, err := app.hlsStream("/videos/movie.avi", 0)
+	if err != nil {
+		t.Fatalf("unable to start transcoding again: %v", err)
+	}
+	if retried == failed {
+		t.Fatal("the failed transcode is still used")
+	}
+}
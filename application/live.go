@@ -0,0 +1,183 @@
+package application
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// probeTimeout is how long a url is given to say what it is.
+const probeTimeout = 5 * time.Second
+
+// maxManifestSize is the most of a playlist or manifest that is read to
+// find out whether it is live.
+const maxManifestSize = 1 << 20
+
+// WithLive loads urls as live streams, without checking whether they are.
+func WithLive() LoadOption {
+	return func(o *loadOptions) {
+		o.live = true
+	}
+}
+
+// WithHTTPClient has urls that are loaded asked what they are with
+// 'client', rather than with 'http.DefaultClient'.
+func WithHTTPClient(client *http.Client) ApplicationOption {
+	return func(a *Application) {
+		a.httpClient = client
+	}
+}
+
+// streamType returns the stream type the chromecast is told 'mi' is.
+func (mi mediaItem) streamType() string {
+	if mi.live {
+		return cast.StreamTypeLive
+	}
+	return cast.StreamTypeBuffered
+}
+
+// urlMediaItem returns the media item that plays 'rawurl' from where it is.
+// Only urls whose extension doesn't say what they are, or that are
+// playlists or manifests, are asked what they are and whether they are
+// live.
+func (a *Application) urlMediaItem(ctx context.Context, rawurl, contentType string, options loadOptions) (mediaItem, error) {
+	mi := mediaItem{contentURL: rawurl, contentType: contentType, live: options.live}
+	extType, _ := a.possibleContentType(rawurl)
+	if mi.contentType == "" {
+		mi.contentType = extType
+	}
+	if !options.live && (extType == "" || isManifestType(extType)) {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		probedType, live := probeURL(ctx, a.httpClient, rawurl, 1)
+		a.log("probed %s: content-type=%q, live=%t", rawurl, probedType, live)
+		if mi.contentType == "" {
+			mi.contentType = probedType
+		}
+		mi.live = live
+	}
+	if mi.contentType == "" {
+		// Say why, now that the url hasn't said either.
+		_, err := a.possibleContentType(rawurl)
+		return mediaItem{}, err
+	}
+	return mi, nil
+}
+
+// isManifestType reports whether 'contentType' is that of a playlist or
+// manifest, which may be live.
+func isManifestType(contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl",
+		"application/dash+xml", "application/vnd.ms-sstr+xml":
+		return true
+	}
+	return false
+}
+
+// probeURL asks 'rawurl' what it is with 'client', returning "" when it
+// can't tell. Internet radio, live HLS playlists and dynamic DASH
+// manifests are live, anything else isn't. HLS master playlists are
+// followed to their first variant, at most 'follow' times.
+func probeURL(ctx context.Context, client *http.Client, rawurl string, follow int) (contentType string, live bool) {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return "", false
+	}
+	// Internet radio only sends its icy-* headers when asked for them.
+	req.Header.Set("Icy-MetaData", "1")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		// SHOUTcast servers reply with "ICY 200 OK" rather than HTTP.
+		if strings.Contains(err.Error(), `"ICY`) {
+			return "audio/mpeg", true
+		}
+		return "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", false
+	}
+
+	contentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	for name := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(name), "icy-") {
+			return contentType, true
+		}
+	}
+
+	switch strings.ToLower(contentType) {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+	case "application/dash+xml", "application/vnd.ms-sstr+xml":
+	case "", "application/octet-stream", "text/plain", "text/xml", "application/xml":
+		// Servers don't always know what manifests are.
+	default:
+		if strings.HasPrefix(contentType, "image/") {
+			return contentType, false
+		}
+		if !strings.HasPrefix(contentType, "audio/") && !strings.HasPrefix(contentType, "video/") {
+			return "", false
+		}
+		// Chunked media isn't necessarily live, only its icy-* headers
+		// would have said so.
+		return contentType, false
+	}
+
+	manifest, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return contentType, false
+	}
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(manifest), []byte("#EXTM3U")):
+		live, variant := hlsLive(manifest)
+		if variant == "" {
+			return "application/x-mpegURL", live
+		}
+		if follow > 0 {
+			if u, err := resp.Request.URL.Parse(variant); err == nil {
+				_, live = probeURL(ctx, client, u.String(), follow-1)
+			}
+		}
+		return "application/x-mpegURL", live
+	case bytes.Contains(manifest, []byte("<MPD")):
+		return "application/dash+xml", bytes.Contains(manifest, []byte(`type="dynamic"`))
+	case bytes.Contains(manifest, []byte("<SmoothStreamingMedia")):
+		return "application/vnd.ms-sstr+xml", bytes.Contains(bytes.ToUpper(manifest), []byte(`ISLIVE="TRUE"`))
+	}
+	return "", false
+}
+
+// hlsLive reports whether the HLS playlist 'playlist' is live, it is until
+// it says it has ended. If it is a master playlist, the url of its first
+// variant is returned instead.
+func hlsLive(playlist []byte) (live bool, variant string) {
+	live = true
+	streamInf := false
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			streamInf = true
+		case line == "#EXT-X-ENDLIST", line == "#EXT-X-PLAYLIST-TYPE:VOD":
+			live = false
+		case !strings.HasPrefix(line, "#") && streamInf:
+			return false, line
+		}
+	}
+	return live, ""
+}
+
+// isLive reports whether 'media' was loaded as a live stream.
+func isLive(media *cast.Media) bool {
+	return media != nil && media.Media.StreamType == cast.StreamTypeLive
+}
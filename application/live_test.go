@@ -0,0 +1,155 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+func startStreams(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/radio", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("icy-name", "Radio")
+		w.Write([]byte("mp3"))
+	})
+	mux.HandleFunc("/song.mp3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("Content-Length", "3")
+		w.Write([]byte("mp3"))
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.(http.Flusher).Flush()
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/live.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:100\n#EXTINF:6.0,\n100.ts\n")
+	})
+	mux.HandleFunc("/vod.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.0,\n0.ts\n#EXT-X-ENDLIST\n")
+	})
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1280000\nlive.m3u8\n")
+	})
+	mux.HandleFunc("/live.mpd", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/dash+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><MPD type="dynamic"></MPD>`)
+	})
+	mux.HandleFunc("/vod.mpd", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0"?><MPD type="static"></MPD>`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbeURL(t *testing.T) {
+	server := startStreams(t)
+	tests := []struct {
+		path        string
+		contentType string
+		live        bool
+	}{
+		{path: "/radio", contentType: "audio/mpeg", live: true},
+		{path: "/song.mp3", contentType: "audio/mpeg"},
+		{path: "/stream", contentType: "video/mp2t"},
+		{path: "/page"},
+		{path: "/missing"},
+		{path: "/live.m3u8", contentType: "application/x-mpegURL", live: true},
+		{path: "/vod.m3u8", contentType: "application/x-mpegURL"},
+		{path: "/master.m3u8", contentType: "application/x-mpegURL", live: true},
+		{path: "/live.mpd", contentType: "application/dash+xml", live: true},
+		{path: "/vod.mpd", contentType: "application/dash+xml"},
+	}
+	for _, test := range tests {
+		contentType, live := probeURL(context.Background(), server.Client(), server.URL+test.path, 1)
+		if contentType != test.contentType || live != test.live {
+			t.Errorf("%s: probed %q, live=%t, expected %q, live=%t", test.path, contentType, live, test.contentType, test.live)
+		}
+	}
+}
+
+func TestApplicationLoadLive(t *testing.T) {
+	server := startStreams(t)
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	if err := app.Load(ctx, server.URL+"/live.m3u8", "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+	if media := receiver.Media().Media; media.StreamType != cast.StreamTypeLive || media.ContentType != "application/x-mpegURL" {
+		t.Fatalf("unexpected media %+v", media)
+	}
+	if err := app.Seek(ctx, 10); err != ErrSeekLiveMedia {
+		t.Fatalf("seeking in live media returned %v, expected %v", err, ErrSeekLiveMedia)
+	}
+	if err := app.SeekToTime(ctx, 10); err != ErrSeekLiveMedia {
+		t.Fatalf("seeking to a time in live media returned %v, expected %v", err, ErrSeekLiveMedia)
+	}
+
+	// The url can't say what it is, it is still live when asked for.
+	if err := app.Load(ctx, server.URL+"/page", "audio/mpeg", false, true, true, WithLive()); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media().Media.ContentId == server.URL+"/page" })
+	if media := receiver.Media().Media; media.StreamType != cast.StreamTypeLive {
+		t.Fatalf("unexpected media %+v", media)
+	}
+
+	if err := app.Load(ctx, server.URL+"/vod.m3u8", "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media().Media.ContentId == server.URL+"/vod.m3u8" })
+	if media := receiver.Media().Media; media.StreamType != cast.StreamTypeBuffered {
+		t.Fatalf("unexpected media %+v", media)
+	}
+}
+
+func TestApplicationLoadURLProbed(t *testing.T) {
+	var mu sync.Mutex
+	probed := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		probed[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("icy-name", "Radio")
+	}))
+	t.Cleanup(server.Close)
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	// The extension says what it is, there is nothing to ask.
+	if err := app.Load(ctx, server.URL+"/song.mp3", "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+	if err := app.QueueInsert(ctx, []string{server.URL + "/1.mp4", server.URL + "/2.mp4"}, "", false, 0); err != nil {
+		t.Fatalf("unable to queue media: %v", err)
+	}
+	if err := app.Load(ctx, server.URL+"/radio", "", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media().Media.ContentId == server.URL+"/radio" })
+	if media := receiver.Media().Media; media.StreamType != cast.StreamTypeLive || media.ContentType != "audio/mpeg" {
+		t.Fatalf("unexpected media %+v", media)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(probed) != 1 || probed["/radio"] != 1 {
+		t.Fatalf("probed %v, expected only /radio", probed)
+	}
+}
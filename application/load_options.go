@@ -0,0 +1,39 @@
+package application
+
+import "github.com/vishen/go-chromecast/cast"
+
+// LoadOption changes how media is loaded by 'Load', 'QueueLoad' and
+// 'QueueInsert'.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	subtitles    string
+	subtitlesDir string
+	repeatMode   string
+	startIndex   int
+	title        string
+	artist       string
+	live         bool
+	appID        string
+	start        float32
+	hls          bool
+
+	resume           bool
+	watchedThreshold float32
+}
+
+func newLoadOptions(opts []LoadOption) loadOptions {
+	options := loadOptions{repeatMode: cast.RepeatOff, appID: defaultChromecastAppId}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithAppID plays the media with the receiver app 'appID', ie: a styled or
+// custom receiver, rather than the default media receiver.
+func WithAppID(appID string) LoadOption {
+	return func(o *loadOptions) {
+		o.appID = appID
+	}
+}
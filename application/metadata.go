@@ -0,0 +1,96 @@
+package application
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/vishen/go-chromecast/cast"
+	"github.com/vishen/go-chromecast/tags"
+)
+
+// WithTitle shows 'title' as the title of the media, instead of the one in
+// its tags. With 'QueueLoad' it is the title of the first item.
+func WithTitle(title string) LoadOption {
+	return func(o *loadOptions) {
+		o.title = title
+	}
+}
+
+// WithArtist shows 'artist' as the artist of the media, instead of the one
+// in its tags. With 'QueueLoad' it is the artist of the first item.
+func WithArtist(artist string) LoadOption {
+	return func(o *loadOptions) {
+		o.artist = artist
+	}
+}
+
+// readTags reads the tags in the local file of 'mi', media without tags is
+// still played.
+func (a *Application) readTags(mi *mediaItem) {
+	if strings.HasPrefix(mi.contentType, "image/") {
+		return
+	}
+	t, err := tags.Read(mi.filename)
+	if err != nil {
+		if err != tags.ErrNoTags {
+			a.log("unable to read tags from %q: %v", mi.filename, err)
+		}
+		return
+	}
+	mi.tags = t
+}
+
+// metadataFor returns the metadata the chromecast shows for 'mi', 'first'
+// is whether it is the first item being loaded.
+func (o loadOptions) metadataFor(mi mediaItem, first bool) cast.MediaMetadata {
+	t := tags.Tags{}
+	if mi.tags != nil {
+		t = *mi.tags
+	}
+	if first && o.title != "" {
+		t.Title = o.title
+	}
+	if first && o.artist != "" {
+		t.Artist = o.artist
+	}
+	if t.Title == "" && mi.filename != "" {
+		t.Title = strings.TrimSuffix(filepath.Base(mi.filename), filepath.Ext(mi.filename))
+	}
+
+	metadata := cast.MediaMetadata{
+		Title:  t.Title,
+		Artist: t.Artist,
+	}
+	if mi.coverArtURL != "" {
+		metadata.Images = []cast.Image{{URL: mi.coverArtURL}}
+	}
+	switch {
+	case strings.HasPrefix(mi.contentType, "image/"):
+		metadata.MetadataType = cast.MetadataPhoto
+	case strings.HasPrefix(mi.contentType, "audio/") || t.Album != "" && t.Show == "":
+		// .m4a files are served as video/mp4, but have an album.
+		metadata.MetadataType = cast.MetadataMusicTrack
+		metadata.AlbumName = t.Album
+		metadata.AlbumArtist = t.AlbumArtist
+		metadata.Composer = t.Composer
+		metadata.TrackNumber = t.TrackNumber
+		metadata.DiscNumber = t.DiscNumber
+		metadata.ReleaseDate = t.Date
+	case t.Show != "":
+		metadata.MetadataType = cast.MetadataTvShow
+		metadata.SeriesTitle = t.Show
+		metadata.Season = t.Season
+		metadata.Episode = t.Episode
+		metadata.OriginalAirdate = t.Date
+	default:
+		// Movies and generic media have no artist, so show it as the
+		// subtitle.
+		metadata.MetadataType = cast.MetadataGeneric
+		if mi.tags != nil {
+			metadata.MetadataType = cast.MetadataMovie
+		}
+		metadata.Subtitle = t.Artist
+		metadata.ReleaseDate = t.Date
+	}
+	return metadata
+}
@@ -0,0 +1,119 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vishen/go-chromecast/cast"
+	"github.com/vishen/go-chromecast/tags"
+)
+
+func TestMetadataFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		mi       mediaItem
+		options  loadOptions
+		first    bool
+		expected cast.MediaMetadata
+	}{
+		{
+			name: "url",
+			mi:   mediaItem{contentURL: "http://example.com/movie.mp4", contentType: "video/mp4"},
+			expected: cast.MediaMetadata{
+				MetadataType: cast.MetadataGeneric,
+			},
+		},
+		{
+			name:    "url with title and artist",
+			mi:      mediaItem{contentURL: "http://example.com/song.mp3", contentType: "audio/mp3"},
+			options: loadOptions{title: "Song", artist: "Band"},
+			first:   true,
+			expected: cast.MediaMetadata{
+				MetadataType: cast.MetadataMusicTrack,
+				Title:        "Song",
+				Artist:       "Band",
+			},
+		},
+		{
+			name:    "title only for the first item",
+			mi:      mediaItem{filename: "/music/02 Other.mp3", contentType: "audio/mp3"},
+			options: loadOptions{title: "Song"},
+			expected: cast.MediaMetadata{
+				MetadataType: cast.MetadataMusicTrack,
+				Title:        "02 Other",
+			},
+		},
+		{
+			name: "photo",
+			mi:   mediaItem{filename: "/photos/beach.jpg", contentType: "image/jpeg"},
+			expected: cast.MediaMetadata{
+				MetadataType: cast.MetadataPhoto,
+				Title:        "beach",
+			},
+		},
+		{
+			name: "music track",
+			mi: mediaItem{filename: "/music/song.m4a", contentType: "video/mp4", coverArtURL: "http://127.0.0.1/cover_art", tags: &tags.Tags{
+				Title: "Song", Artist: "Band", Album: "Record", AlbumArtist: "Various", TrackNumber: 3, DiscNumber: 1, Date: "2006",
+			}},
+			expected: cast.MediaMetadata{
+				MetadataType: cast.MetadataMusicTrack,
+				Images:       []cast.Image{{URL: "http://127.0.0.1/cover_art"}},
+				Title:        "Song",
+				Artist:       "Band",
+				AlbumName:    "Record",
+				AlbumArtist:  "Various",
+				TrackNumber:  3,
+				DiscNumber:   1,
+				ReleaseDate:  "2006",
+			},
+		},
+		{
+			name: "tv show",
+			mi: mediaItem{filename: "/tv/pilot.mp4", contentType: "video/mp4", tags: &tags.Tags{
+				Title: "Pilot", Show: "The Show", Season: 1, Episode: 2, Date: "2010-01-02",
+			}},
+			expected: cast.MediaMetadata{
+				MetadataType:    cast.MetadataTvShow,
+				Title:           "Pilot",
+				SeriesTitle:     "The Show",
+				Season:          1,
+				Episode:         2,
+				OriginalAirdate: "2010-01-02",
+			},
+		},
+		{
+			name: "movie",
+			mi: mediaItem{filename: "/movies/movie.mkv", contentType: "video/mp4", transcode: true, tags: &tags.Tags{
+				Title: "The Movie", Artist: "Director", Date: "1999",
+			}},
+			expected: cast.MediaMetadata{
+				MetadataType: cast.MetadataMovie,
+				Title:        "The Movie",
+				Artist:       "Director",
+				Subtitle:     "Director",
+				ReleaseDate:  "1999",
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := test.options.metadataFor(test.mi, test.first); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestApplicationLoadWithTitle(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	if err := app.Load(ctx, "http://example.com/stream", "audio/mpeg", false, true, true, WithTitle("Radio"), WithArtist("DJ")); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+	if metadata := receiver.Media().Media.Metadata; metadata.Title != "Radio" || metadata.Artist != "DJ" || metadata.MetadataType != cast.MetadataMusicTrack {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+}
@@ -0,0 +1,293 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DeviceProfile is what a chromecast can play as it is. Media being
+// transcoded only has the streams it can't play re-encoded, the rest are
+// copied, which is much cheaper.
+type DeviceProfile struct {
+	Name string
+	// The codecs it plays, as ffprobe and ffmpeg name them. A profile
+	// without video codecs is for speakers, video is left out.
+	VideoCodecs []string
+	AudioCodecs []string
+	// The highest H.264 level it plays, ie: 41 for 4.1.
+	MaxH264Level int
+	MaxWidth     int
+	MaxHeight    int
+	// The pixel formats of the video it plays, ie: 8 bit 4:2:0.
+	PixelFormats     []string
+	MaxAudioChannels int
+}
+
+// deviceProfiles are the device profiles, by the name given on the command
+// line.
+var deviceProfiles = map[string]*DeviceProfile{
+	"chromecast": {
+		Name:             "chromecast",
+		VideoCodecs:      []string{"h264"},
+		AudioCodecs:      []string{"aac", "mp3", "opus", "vorbis", "flac"},
+		MaxH264Level:     41,
+		MaxWidth:         1920,
+		MaxHeight:        1080,
+		PixelFormats:     []string{"yuv420p", "yuvj420p"},
+		MaxAudioChannels: 2,
+	},
+	"ultra": {
+		Name:             "ultra",
+		VideoCodecs:      []string{"h264", "hevc", "vp9"},
+		AudioCodecs:      []string{"aac", "mp3", "opus", "vorbis", "flac", "ac3", "eac3"},
+		MaxH264Level:     52,
+		MaxWidth:         3840,
+		MaxHeight:        2160,
+		PixelFormats:     []string{"yuv420p", "yuvj420p", "yuv420p10le"},
+		MaxAudioChannels: 6,
+	},
+	"google-tv": {
+		Name:             "google-tv",
+		VideoCodecs:      []string{"h264", "hevc", "vp9", "av1"},
+		AudioCodecs:      []string{"aac", "mp3", "opus", "vorbis", "flac", "ac3", "eac3"},
+		MaxH264Level:     52,
+		MaxWidth:         3840,
+		MaxHeight:        2160,
+		PixelFormats:     []string{"yuv420p", "yuvj420p", "yuv420p10le"},
+		MaxAudioChannels: 6,
+	},
+	"audio": {
+		Name:             "audio",
+		AudioCodecs:      []string{"aac", "mp3", "opus", "vorbis", "flac"},
+		MaxAudioChannels: 2,
+	},
+}
+
+// defaultDeviceProfile is what every chromecast can play.
+var defaultDeviceProfile = deviceProfiles["chromecast"]
+
+// muxerCodecs are the codecs that can be copied into what ffmpeg writes,
+// 'mp4' when streaming and 'hls' when transcoding to HLS segments.
+var muxerCodecs = map[string][]string{
+	"mp4": {"h264", "hevc", "vp9", "av1", "aac", "mp3", "opus", "flac", "ac3", "eac3"},
+	"hls": {"h264", "hevc", "aac", "mp3", "ac3", "eac3"},
+}
+
+// ParseDeviceProfile returns the device profile called 'name', one of
+// 'chromecast', 'ultra', 'google-tv' or 'audio'.
+func ParseDeviceProfile(name string) (*DeviceProfile, error) {
+	if profile, ok := deviceProfiles[strings.ToLower(name)]; ok {
+		return profile, nil
+	}
+	return nil, ErrInvalidDeviceProfile
+}
+
+// WithDeviceProfile transcodes media to what 'profile' can play, rather
+// than to what every chromecast can play.
+func WithDeviceProfile(profile *DeviceProfile) ApplicationOption {
+	return func(a *Application) {
+		if profile != nil {
+			a.deviceProfile = profile
+		}
+	}
+}
+
+// probedStream is a stream of a media file, as ffprobe describes it.
+type probedStream struct {
+	Index       int    `json:"index"`
+	CodecType   string `json:"codec_type"`
+	CodecName   string `json:"codec_name"`
+	PixelFormat string `json:"pix_fmt"`
+	Level       int    `json:"level"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Channels    int    `json:"channels"`
+	Disposition struct {
+		Default     int `json:"default"`
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+}
+
+// probeStreams returns the streams of the media 'filename' using ffprobe.
+func probeStreams(filename string) ([]probedStream, error) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find ffprobe")
+	}
+	out, err := exec.Command(ffprobe, "-v", "quiet", "-print_format", "json", "-show_streams", filename).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to run ffprobe")
+	}
+	var probe struct {
+		Streams []probedStream `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, errors.Wrap(err, "unable to decode ffprobe output")
+	}
+	return probe.Streams, nil
+}
+
+// streamPlan is what to do with a stream when transcoding.
+type streamPlan struct {
+	index int
+	copy  bool
+	// The number of audio channels to transcode to.
+	channels int
+	// Video too big for the chromecast is scaled down.
+	scale bool
+}
+
+// transcodePlan is what to do with the streams of media being transcoded,
+// any stream without a plan is left out.
+type transcodePlan struct {
+	// The streams weren't known, everything is re-encoded.
+	unknown bool
+	// Speakers don't get video.
+	noVideo      bool
+	video, audio *streamPlan
+	profile      *DeviceProfile
+}
+
+// planTranscode decides which of 'streams' 'profile' can play as they are,
+// when written by the muxer 'muxer', and which have to be re-encoded. Only
+// the main video and audio streams are kept, subtitles are served on their
+// own.
+func planTranscode(streams []probedStream, profile *DeviceProfile, muxer string) transcodePlan {
+	plan := transcodePlan{profile: profile, noVideo: len(profile.VideoCodecs) == 0}
+	if len(streams) == 0 {
+		plan.unknown = true
+		return plan
+	}
+
+	var video, audio *probedStream
+	for i, s := range streams {
+		switch s.CodecType {
+		case "video":
+			// Cover art is a video stream too.
+			if video == nil && s.Disposition.AttachedPic == 0 {
+				video = &streams[i]
+			}
+		case "audio":
+			if audio == nil || (s.Disposition.Default == 1 && audio.Disposition.Default == 0) {
+				audio = &streams[i]
+			}
+		}
+	}
+
+	if video != nil && !plan.noVideo {
+		plan.video = &streamPlan{
+			index: video.Index,
+			copy:  profile.canCopyVideo(*video) && contains(muxerCodecs[muxer], video.CodecName),
+			scale: profile.MaxWidth > 0 && video.Width > profile.MaxWidth || profile.MaxHeight > 0 && video.Height > profile.MaxHeight,
+		}
+	}
+	if audio != nil {
+		channels := audio.Channels
+		if channels <= 0 || channels > profile.MaxAudioChannels {
+			channels = profile.MaxAudioChannels
+		}
+		plan.audio = &streamPlan{
+			index:    audio.Index,
+			copy:     profile.canCopyAudio(*audio) && contains(muxerCodecs[muxer], audio.CodecName),
+			channels: channels,
+		}
+	}
+	return plan
+}
+
+// canCopyVideo reports whether the video stream 's' plays as it is.
+func (p *DeviceProfile) canCopyVideo(s probedStream) bool {
+	if !contains(p.VideoCodecs, s.CodecName) {
+		return false
+	}
+	if s.CodecName == "h264" && p.MaxH264Level > 0 && s.Level > p.MaxH264Level {
+		return false
+	}
+	if p.MaxWidth > 0 && s.Width > p.MaxWidth || p.MaxHeight > 0 && s.Height > p.MaxHeight {
+		return false
+	}
+	return len(p.PixelFormats) == 0 || s.PixelFormat == "" || contains(p.PixelFormats, s.PixelFormat)
+}
+
+// canCopyAudio reports whether the audio stream 's' plays as it is.
+func (p *DeviceProfile) canCopyAudio(s probedStream) bool {
+	return contains(p.AudioCodecs, s.CodecName) && s.Channels <= p.MaxAudioChannels
+}
+
+// args are the arguments to ffmpeg, after the input, that carry out the
+// plan.
+func (p transcodePlan) args() []string {
+	if p.unknown {
+		if p.noVideo {
+			return []string{"-vn", "-acodec", "aac", "-ac", strconv.Itoa(p.profile.MaxAudioChannels)}
+		}
+		return []string{"-vcodec", "h264", "-acodec", "aac", "-ac", strconv.Itoa(p.profile.MaxAudioChannels)}
+	}
+
+	var args []string
+	if v := p.video; v != nil {
+		args = append(args, "-map", fmt.Sprintf("0:%d", v.index))
+		switch {
+		case v.copy:
+			args = append(args, "-c:v", "copy")
+		case v.scale:
+			args = append(args, "-c:v", "h264", "-pix_fmt", "yuv420p",
+				"-vf", fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease:force_divisible_by=2", p.profile.MaxWidth, p.profile.MaxHeight))
+		default:
+			args = append(args, "-c:v", "h264", "-pix_fmt", "yuv420p")
+		}
+	}
+	if a := p.audio; a != nil {
+		args = append(args, "-map", fmt.Sprintf("0:%d", a.index))
+		if a.copy {
+			args = append(args, "-c:a", "copy")
+		} else {
+			args = append(args, "-c:a", "aac", "-ac", strconv.Itoa(a.channels))
+		}
+	}
+	return args
+}
+
+// String describes the plan, for logging.
+func (p transcodePlan) String() string {
+	if p.unknown {
+		return "re-encode everything"
+	}
+	describe := func(s *streamPlan) string {
+		switch {
+		case s == nil:
+			return "none"
+		case s.copy:
+			return "copy"
+		}
+		return "re-encode"
+	}
+	return fmt.Sprintf("video=%s, audio=%s", describe(p.video), describe(p.audio))
+}
+
+// transcodePlan decides how to transcode the media 'filename' for the
+// chromecast, written by the muxer 'muxer'. When ffprobe can't say what
+// is in it, everything is re-encoded.
+func (a *Application) transcodePlan(filename, muxer string) transcodePlan {
+	streams, err := probeStreams(filename)
+	if err != nil {
+		a.log("unable to probe %s, re-encoding it: %v", filename, err)
+	}
+	plan := planTranscode(streams, a.deviceProfile, muxer)
+	a.log("transcoding %s for %s: %s", filename, a.deviceProfile.Name, plan)
+	return plan
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package application
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceProfile(t *testing.T) {
+	for _, name := range []string{"chromecast", "Ultra", "google-tv", "audio"} {
+		if _, err := ParseDeviceProfile(name); err != nil {
+			t.Errorf("ParseDeviceProfile(%q) returned %v", name, err)
+		}
+	}
+	if _, err := ParseDeviceProfile("toaster"); err != ErrInvalidDeviceProfile {
+		t.Errorf("ParseDeviceProfile(%q) returned %v, expected %v", "toaster", err, ErrInvalidDeviceProfile)
+	}
+}
+
+func TestPlanTranscode(t *testing.T) {
+	h264 := probedStream{Index: 0, CodecType: "video", CodecName: "h264", PixelFormat: "yuv420p", Level: 40, Width: 1920, Height: 1080}
+	aac := probedStream{Index: 1, CodecType: "audio", CodecName: "aac", Channels: 2}
+	ac3 := probedStream{Index: 1, CodecType: "audio", CodecName: "ac3", Channels: 6}
+	hevc10 := probedStream{Index: 0, CodecType: "video", CodecName: "hevc", PixelFormat: "yuv420p10le", Width: 3840, Height: 2160}
+	coverArt := probedStream{Index: 0, CodecType: "video", CodecName: "mjpeg"}
+	coverArt.Disposition.AttachedPic = 1
+	commentary := probedStream{Index: 2, CodecType: "audio", CodecName: "aac", Channels: 2}
+	mainAudio := probedStream{Index: 1, CodecType: "audio", CodecName: "opus", Channels: 6}
+	mainAudio.Disposition.Default = 1
+
+	tests := []struct {
+		name     string
+		streams  []probedStream
+		profile  string
+		muxer    string
+		expected []string
+	}{
+		{
+			name:     "remux",
+			streams:  []probedStream{h264, aac},
+			profile:  "chromecast",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:0", "-c:v", "copy", "-map", "0:1", "-c:a", "copy"},
+		},
+		{
+			name:     "surround audio",
+			streams:  []probedStream{h264, ac3},
+			profile:  "chromecast",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:0", "-c:v", "copy", "-map", "0:1", "-c:a", "aac", "-ac", "2"},
+		},
+		{
+			name:     "surround audio on ultra",
+			streams:  []probedStream{hevc10, ac3},
+			profile:  "ultra",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:0", "-c:v", "copy", "-map", "0:1", "-c:a", "copy"},
+		},
+		{
+			name:    "4k hevc",
+			streams: []probedStream{hevc10, aac},
+			profile: "chromecast",
+			muxer:   "mp4",
+			expected: []string{
+				"-map", "0:0", "-c:v", "h264", "-pix_fmt", "yuv420p",
+				"-vf", "scale=1920:1080:force_original_aspect_ratio=decrease:force_divisible_by=2",
+				"-map", "0:1", "-c:a", "copy",
+			},
+		},
+		{
+			name:     "high level h264",
+			streams:  []probedStream{{Index: 0, CodecType: "video", CodecName: "h264", PixelFormat: "yuv420p", Level: 51, Width: 1280, Height: 720}, aac},
+			profile:  "chromecast",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:0", "-c:v", "h264", "-pix_fmt", "yuv420p", "-map", "0:1", "-c:a", "copy"},
+		},
+		{
+			name:     "default audio",
+			streams:  []probedStream{h264, commentary, mainAudio},
+			profile:  "chromecast",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:0", "-c:v", "copy", "-map", "0:1", "-c:a", "aac", "-ac", "2"},
+		},
+		{
+			name:     "cover art",
+			streams:  []probedStream{coverArt, {Index: 1, CodecType: "audio", CodecName: "flac", Channels: 2}},
+			profile:  "chromecast",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:1", "-c:a", "copy"},
+		},
+		{
+			name:     "speaker",
+			streams:  []probedStream{h264, aac},
+			profile:  "audio",
+			muxer:    "mp4",
+			expected: []string{"-map", "0:1", "-c:a", "copy"},
+		},
+		{
+			name:     "hls",
+			streams:  []probedStream{h264, {Index: 1, CodecType: "audio", CodecName: "opus", Channels: 2}},
+			profile:  "chromecast",
+			muxer:    "hls",
+			expected: []string{"-map", "0:0", "-c:v", "copy", "-map", "0:1", "-c:a", "aac", "-ac", "2"},
+		},
+		{
+			name:     "unknown",
+			profile:  "chromecast",
+			muxer:    "mp4",
+			expected: []string{"-vcodec", "h264", "-acodec", "aac", "-ac", "2"},
+		},
+	}
+	for _, test := range tests {
+		profile, err := ParseDeviceProfile(test.profile)
+		if err != nil {
+			t.Fatalf("%s: unable to parse device profile: %v", test.name, err)
+		}
+		plan := planTranscode(test.streams, profile, test.muxer)
+		if args := plan.args(); !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%s: ffmpeg arguments %q, expected %q", test.name, args, test.expected)
+		}
+	}
+}
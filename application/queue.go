@@ -0,0 +1,207 @@
+package application
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// QueueItemIds returns the ids of the items in the queue, in the order they
+// are played.
+func (a *Application) QueueItemIds(ctx context.Context) ([]int, error) {
+	media := a.Media()
+	if media == nil {
+		return nil, ErrMediaNotYetInitialised
+	}
+	apiMessage, err := a.sendAndWaitMediaRecv(ctx, &cast.QueueGetItemIds{
+		PayloadHeader:  cast.QueueGetItemIdsHeader,
+		MediaSessionId: media.MediaSessionId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get queue item ids")
+	}
+	decoded, err := cast.Decode(apiMessage)
+	if err != nil {
+		return nil, err
+	}
+	response, ok := decoded.(*cast.QueueItemIdsResponse)
+	if !ok {
+		return nil, unexpectedResponse(decoded)
+	}
+	return response.ItemIds, nil
+}
+
+// QueueItems returns the items 'itemIds' in the queue, or every item when
+// there are none.
+func (a *Application) QueueItems(ctx context.Context, itemIds ...int) ([]cast.QueueLoadItem, error) {
+	if len(itemIds) == 0 {
+		var err error
+		if itemIds, err = a.QueueItemIds(ctx); err != nil {
+			return nil, err
+		}
+		if len(itemIds) == 0 {
+			return nil, nil
+		}
+	}
+	media := a.Media()
+	if media == nil {
+		return nil, ErrMediaNotYetInitialised
+	}
+	apiMessage, err := a.sendAndWaitMediaRecv(ctx, &cast.QueueGetItems{
+		PayloadHeader:  cast.QueueGetItemsHeader,
+		MediaSessionId: media.MediaSessionId,
+		ItemIds:        itemIds,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get queue items")
+	}
+	decoded, err := cast.Decode(apiMessage)
+	if err != nil {
+		return nil, err
+	}
+	response, ok := decoded.(*cast.QueueItemsResponse)
+	if !ok {
+		return nil, unexpectedResponse(decoded)
+	}
+	return response.Items, nil
+}
+
+// QueueInsert adds the files or urls in 'filenamesOrUrls' to the queue
+// that is playing, before the item 'insertBefore' or at the end of the
+// queue when it is 0. Local files are served for as long as the application
+// is open, see 'WaitForMedia'.
+func (a *Application) QueueInsert(ctx context.Context, filenamesOrUrls []string, contentType string, transcode bool, insertBefore int, opts ...LoadOption) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	options := newLoadOptions(opts)
+	mediaItems, err := a.queueMediaItems(ctx, filenamesOrUrls, contentType, transcode, options)
+	if err != nil {
+		return err
+	}
+	items, err := a.queueItems(mediaItems, options)
+	if err != nil {
+		return err
+	}
+	return a.editQueue(ctx, &cast.QueueInsert{
+		PayloadHeader:  cast.QueueInsertHeader,
+		MediaSessionId: media.MediaSessionId,
+		InsertBefore:   insertBefore,
+		Items:          items,
+	})
+}
+
+// QueueRemove removes the items 'itemIds' from the queue, if the playing
+// item is removed the next one starts playing.
+func (a *Application) QueueRemove(ctx context.Context, itemIds ...int) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editQueue(ctx, &cast.QueueRemove{
+		PayloadHeader:  cast.QueueRemoveHeader,
+		MediaSessionId: media.MediaSessionId,
+		ItemIds:        itemIds,
+	})
+}
+
+// QueueReorder moves the items 'itemIds', in that order, before the item
+// 'insertBefore' or to the end of the queue when it is 0.
+func (a *Application) QueueReorder(ctx context.Context, itemIds []int, insertBefore int) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editQueue(ctx, &cast.QueueReorder{
+		PayloadHeader:  cast.QueueReorderHeader,
+		MediaSessionId: media.MediaSessionId,
+		ItemIds:        itemIds,
+		InsertBefore:   insertBefore,
+	})
+}
+
+// QueueJump starts playing the item 'itemId' in the queue.
+func (a *Application) QueueJump(ctx context.Context, itemId int) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editQueue(ctx, &cast.QueueUpdate{
+		PayloadHeader:  cast.QueueUpdateHeader,
+		MediaSessionId: media.MediaSessionId,
+		CurrentItemId:  itemId,
+	})
+}
+
+// editQueue sends a change to the queue, and waits for the media status
+// that has the change applied.
+func (a *Application) editQueue(ctx context.Context, payload cast.Payload) error {
+	apiMessage, err := a.sendAndWaitMediaRecv(ctx, payload)
+	if err != nil {
+		return errors.Wrap(err, "unable to change queue")
+	}
+	decoded, err := cast.Decode(apiMessage)
+	if err != nil {
+		return err
+	}
+	if _, ok := decoded.(*cast.MediaStatusResponse); !ok {
+		return unexpectedResponse(decoded)
+	}
+	return nil
+}
+
+// queueMediaItems serves the local files in 'filenamesOrUrls', urls are
+// played from where they are.
+func (a *Application) queueMediaItems(ctx context.Context, filenamesOrUrls []string, contentType string, transcode bool, options loadOptions) ([]mediaItem, error) {
+	mediaItems := make([]mediaItem, len(filenamesOrUrls))
+	for i, filenameOrUrl := range filenamesOrUrls {
+		if strings.HasPrefix(filenameOrUrl, "http://") || strings.HasPrefix(filenameOrUrl, "https://") {
+			mi, err := a.urlMediaItem(ctx, filenameOrUrl, contentType, options)
+			if err != nil {
+				return nil, err
+			}
+			mediaItems[i] = mi
+			continue
+		}
+		served, err := a.loadAndServeFiles([]string{filenameOrUrl}, contentType, transcode, options)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load and serve files")
+		}
+		mediaItems[i] = served[0]
+	}
+	return mediaItems, nil
+}
+
+// queueItems returns the queue items that play 'mediaItems', with their
+// subtitles and metadata.
+func (a *Application) queueItems(mediaItems []mediaItem, options loadOptions) ([]cast.QueueLoadItem, error) {
+	items := make([]cast.QueueLoadItem, len(mediaItems))
+	for i, mi := range mediaItems {
+		subtitles, err := options.subtitlesFor(mi, i == 0)
+		if err != nil {
+			return nil, err
+		}
+		tracks, err := a.subtitleTracks(subtitles)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to serve subtitles")
+		}
+		items[i] = cast.QueueLoadItem{
+			Autoplay:         true,
+			PlaybackDuration: 60,
+			Media: cast.MediaItem{
+				ContentId:   mi.contentURL,
+				StreamType:  mi.streamType(),
+				ContentType: mi.contentType,
+				Duration:    mi.duration,
+				Metadata:    options.metadataFor(mi, i == 0),
+				Tracks:      tracks,
+			},
+			ActiveTrackIds: activeTrackIds(tracks),
+		}
+	}
+	return items, nil
+}
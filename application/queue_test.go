@@ -0,0 +1,76 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestApplicationQueue(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	if err := app.Load(ctx, "http://example.com/1.mp3", "audio/mpeg", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+	if err := app.QueueInsert(ctx, []string{"http://example.com/3.mp3", "http://example.com/4.mp3"}, "audio/mpeg", false, 0); err != nil {
+		t.Fatalf("unable to add to queue: %v", err)
+	}
+	if err := app.QueueInsert(ctx, []string{"http://example.com/2.mp3"}, "audio/mpeg", false, 2); err != nil {
+		t.Fatalf("unable to add to queue: %v", err)
+	}
+
+	queue := func() []string {
+		t.Helper()
+		items, err := app.QueueItems(ctx)
+		if err != nil {
+			t.Fatalf("unable to get queue: %v", err)
+		}
+		var contentIDs []string
+		for _, item := range items {
+			contentIDs = append(contentIDs, item.Media.ContentId)
+		}
+		return contentIDs
+	}
+	expectQueue := func(what string, expected ...string) {
+		t.Helper()
+		if got := queue(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("after %s the queue is %v, expected %v", what, got, expected)
+		}
+	}
+	expectQueue("adding", "http://example.com/1.mp3", "http://example.com/2.mp3", "http://example.com/3.mp3", "http://example.com/4.mp3")
+
+	itemIDs, err := app.QueueItemIds(ctx)
+	if err != nil {
+		t.Fatalf("unable to get queue item ids: %v", err)
+	}
+	if !reflect.DeepEqual(itemIDs, []int{1, 4, 2, 3}) {
+		t.Fatalf("unexpected queue item ids %v", itemIDs)
+	}
+
+	if err := app.QueueReorder(ctx, []int{3, 2}, 1); err != nil {
+		t.Fatalf("unable to reorder queue: %v", err)
+	}
+	expectQueue("reordering", "http://example.com/4.mp3", "http://example.com/3.mp3", "http://example.com/1.mp3", "http://example.com/2.mp3")
+
+	if err := app.QueueJump(ctx, 2); err != nil {
+		t.Fatalf("unable to jump to queue item: %v", err)
+	}
+	if media := app.Media(); media.CurrentItemId != 2 || media.Media.ContentId != "http://example.com/3.mp3" {
+		t.Fatalf("unexpected media after jumping: %#v", media)
+	}
+	if err := app.QueueJump(ctx, 10); err == nil {
+		t.Fatal("jumping to an unknown item succeeded")
+	}
+
+	// Removing the playing item plays the one after it.
+	if err := app.QueueRemove(ctx, 2, 3); err != nil {
+		t.Fatalf("unable to remove from queue: %v", err)
+	}
+	expectQueue("removing", "http://example.com/1.mp3", "http://example.com/2.mp3")
+	if media := app.Media(); media.CurrentItemId != 1 || media.PlayerState != "PLAYING" {
+		t.Fatalf("unexpected media after removing the playing item: %#v", media)
+	}
+}
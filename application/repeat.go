@@ -0,0 +1,76 @@
+package application
+
+import (
+	"context"
+	"strings"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// repeatModes are the names of the repeat modes, as given on the command
+// line.
+var repeatModes = map[string]string{
+	"off":             cast.RepeatOff,
+	"all":             cast.RepeatAll,
+	"single":          cast.RepeatSingle,
+	"all-and-shuffle": cast.RepeatAllAndShuffle,
+}
+
+// ParseRepeatMode returns the repeat mode called 'name', one of 'off',
+// 'all', 'single' or 'all-and-shuffle'.
+func ParseRepeatMode(name string) (string, error) {
+	if mode, ok := repeatModes[strings.ToLower(name)]; ok {
+		return mode, nil
+	}
+	// The chromecast's own names are fine too.
+	for _, mode := range repeatModes {
+		if strings.EqualFold(name, mode) {
+			return mode, nil
+		}
+	}
+	return "", ErrInvalidRepeatMode
+}
+
+// WithRepeatMode has 'QueueLoad' play the queue in the repeat mode 'mode',
+// ie: 'cast.RepeatAll', rather than once.
+func WithRepeatMode(mode string) LoadOption {
+	return func(o *loadOptions) {
+		o.repeatMode = mode
+	}
+}
+
+// WithStartIndex has 'QueueLoad' start playing from the item at 'index'
+// rather than the first, the items before it are still in the queue, to be
+// played when it repeats or by going back.
+func WithStartIndex(index int) LoadOption {
+	return func(o *loadOptions) {
+		o.startIndex = index
+	}
+}
+
+// SetRepeatMode changes the repeat mode of the queue that is playing.
+func (a *Application) SetRepeatMode(ctx context.Context, mode string) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editQueue(ctx, &cast.QueueUpdate{
+		PayloadHeader:  cast.QueueUpdateHeader,
+		MediaSessionId: media.MediaSessionId,
+		RepeatMode:     mode,
+	})
+}
+
+// ShuffleQueue has the chromecast shuffle the queue that is playing, the
+// playing item keeps playing.
+func (a *Application) ShuffleQueue(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editQueue(ctx, &cast.QueueUpdate{
+		PayloadHeader:  cast.QueueUpdateHeader,
+		MediaSessionId: media.MediaSessionId,
+		Shuffle:        true,
+	})
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+func TestParseRepeatMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"off", cast.RepeatOff},
+		{"all", cast.RepeatAll},
+		{"Single", cast.RepeatSingle},
+		{"all-and-shuffle", cast.RepeatAllAndShuffle},
+		{"REPEAT_ALL", cast.RepeatAll},
+	}
+	for _, test := range tests {
+		mode, err := ParseRepeatMode(test.name)
+		if err != nil || mode != test.expected {
+			t.Errorf("ParseRepeatMode(%q) = %q, %v, expected %q", test.name, mode, err, test.expected)
+		}
+	}
+	if _, err := ParseRepeatMode("sometimes"); err != ErrInvalidRepeatMode {
+		t.Errorf("unknown repeat mode returned %v, expected %v", err, ErrInvalidRepeatMode)
+	}
+}
+
+func TestApplicationRepeatAndShuffle(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	if err := app.Load(ctx, "http://example.com/1.mp3", "audio/mpeg", false, true, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+	if err := app.QueueInsert(ctx, []string{"http://example.com/2.mp3", "http://example.com/3.mp3", "http://example.com/4.mp3"}, "audio/mpeg", false, 0); err != nil {
+		t.Fatalf("unable to add to queue: %v", err)
+	}
+
+	if err := app.SetRepeatMode(ctx, cast.RepeatAll); err != nil {
+		t.Fatalf("unable to change repeat mode: %v", err)
+	}
+	if mode := app.Media().RepeatMode; mode != cast.RepeatAll {
+		t.Fatalf("repeat mode is %q, expected %q", mode, cast.RepeatAll)
+	}
+	// The last item is followed by the first again.
+	if err := app.QueueJump(ctx, 4); err != nil {
+		t.Fatalf("unable to jump to queue item: %v", err)
+	}
+	receiver.FinishMedia()
+	waitFor(t, "the queue to repeat", func() bool { return app.Media().CurrentItemId == 1 })
+
+	if err := app.ShuffleQueue(ctx); err != nil {
+		t.Fatalf("unable to shuffle queue: %v", err)
+	}
+	itemIDs, err := app.QueueItemIds(ctx)
+	if err != nil {
+		t.Fatalf("unable to get queue item ids: %v", err)
+	}
+	sort.Ints(itemIDs)
+	if len(itemIDs) != 4 || itemIDs[0] != 1 || itemIDs[3] != 4 {
+		t.Fatalf("shuffling changed the items in the queue: %v", itemIDs)
+	}
+	if media := app.Media(); media.CurrentItemId != 1 || media.PlayerState != "PLAYING" {
+		t.Fatalf("shuffling changed the playing item: %#v", media)
+	}
+}
+
+func TestApplicationQueueLoadStartIndex(t *testing.T) {
+	dir := tempDir(t)
+	var filenames []string
+	for _, name := range []string{"1.mp3", "2.mp3", "3.mp3"} {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, nil, 0600); err != nil {
+			t.Fatalf("unable to write %s: %v", filename, err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	go app.QueueLoad(context.Background(), filenames, "", false, WithRepeatMode(cast.RepeatAll), WithStartIndex(1))
+	waitFor(t, "the queue to load", func() bool { return receiver.Media() != nil })
+	if media := receiver.Media(); media.CurrentItemId != 2 {
+		t.Fatalf("playing item %d, expected 2", media.CurrentItemId)
+	}
+
+	// The skipped item is played when the queue repeats.
+	receiver.FinishMedia()
+	receiver.FinishMedia()
+	waitFor(t, "the queue to repeat", func() bool { return receiver.Media().CurrentItemId == 1 })
+}
@@ -0,0 +1,63 @@
+package application
+
+import (
+	"context"
+	"io"
+
+	"github.com/buger/jsonparser"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// WithRecorder has every message sent to, and received from, the chromecast
+// passed to 'recorder'.
+func WithRecorder(recorder cast.Recorder) ApplicationOption {
+	return func(a *Application) {
+		a.conn.SetRecorder(recorder)
+	}
+}
+
+// Replay feeds the messages received in a capture, as written by a
+// 'cast.CaptureRecorder', through the application as if they came from a
+// chromecast. Messages are handled one at a time and in order, so the
+// resulting state and events are the same every time. The application must
+// not have been started, and once the capture is exhausted 'Disconnected'
+// is published with io.EOF.
+func (a *Application) Replay(ctx context.Context, r io.Reader) error {
+	capture := cast.NewCaptureReader(r)
+	// Requests that were sent, so the replies to them are handled as
+	// replies.
+	requests := map[int]bool{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		captured, err := capture.Next()
+		if err == io.EOF {
+			a.publish(Disconnected{Err: io.EOF})
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		payload := []byte(captured.Payload)
+		requestID, _ := jsonparser.GetInt(payload, "requestId")
+		if captured.Direction == cast.DirectionSent {
+			if requestID != 0 {
+				requests[int(requestID)] = true
+			}
+			continue
+		}
+
+		// Heartbeats, and anything without a type, never make it past the
+		// 'cast.Connection'.
+		switch messageType, _ := jsonparser.GetString(payload, "type"); messageType {
+		case "", "PING", "PONG":
+			continue
+		}
+
+		msg := captured.Message()
+		events := a.handleMessage(msg, requests[int(requestID)])
+		a.publish(append(events, MessageReceived{Message: msg})...)
+	}
+}
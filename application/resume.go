@@ -0,0 +1,181 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// positionInterval is how often the position of local media that is
+// playing is asked for, and saved.
+const positionInterval = 10 * time.Second
+
+// DefaultWatchedThreshold is how much of the media has to have been played
+// for it to count as watched.
+const DefaultWatchedThreshold = 0.95
+
+// Watched reports whether more than 'threshold', ie: 0.95, of the media has
+// been played.
+func (p PlayedItem) Watched(threshold float32) bool {
+	return p.Duration > 0 && p.Position >= p.Duration*threshold
+}
+
+// WithResume starts local media from where it was last played, unless more
+// than 'watchedThreshold', ie: 0.95, of it was played. With 'QueueLoad' the
+// first item is resumed.
+func WithResume(watchedThreshold float32) LoadOption {
+	return func(o *loadOptions) {
+		o.resume = true
+		o.watchedThreshold = watchedThreshold
+	}
+}
+
+// WithStart starts the media 'seconds' into it, rather than from where it
+// was last played. With 'QueueLoad' the first item starts there.
+func WithStart(seconds float32) LoadOption {
+	return func(o *loadOptions) {
+		o.start = seconds
+	}
+}
+
+// ParseOffset parses how far into media to start or seek to, either a
+// duration, ie: "1h02m" or "90s", or a number of seconds.
+func ParseOffset(offset string) (float32, error) {
+	if seconds, err := strconv.ParseFloat(offset, 32); err == nil && seconds >= 0 {
+		return float32(seconds), nil
+	}
+	d, err := time.ParseDuration(offset)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid offset %q, expected a duration like 1h02m or a number of seconds", offset)
+	}
+	return float32(d.Seconds()), nil
+}
+
+// startPosition returns the position, in seconds, to start playing 'mi'
+// from, given with 'WithStart' or where it was last played.
+func (a *Application) startPosition(mi mediaItem, options loadOptions) float32 {
+	if options.start <= 0 {
+		return a.resumePosition(mi, options)
+	}
+	// Live media, which includes media being transcoded when it isn't
+	// known how long it is, can only be played from where it is.
+	if mi.live {
+		a.log("unable to start live media %s at %.0fs, starting from the start", mi.contentURL, options.start)
+		return 0
+	}
+	return options.start
+}
+
+// resumePosition returns the position, in seconds, to start playing 'mi'
+// from.
+func (a *Application) resumePosition(mi mediaItem, options loadOptions) float32 {
+	// Live media, which includes media being transcoded when it isn't
+	// known how long it is, can only be played from the start.
+	if !options.resume || mi.filename == "" || mi.live {
+		return 0
+	}
+	a.servedMu.Lock()
+	p, ok := a.playedItems[mi.filename]
+	a.servedMu.Unlock()
+	if !ok || p.Watched(options.watchedThreshold) {
+		return 0
+	}
+	a.log("resuming %s from %.0fs", mi.filename, p.Position)
+	return p.Position
+}
+
+// servedFilename returns the local file that the media 'contentID' is
+// served from, or "" when it isn't local media.
+func (a *Application) servedFilename(contentID string) string {
+	u, err := url.Parse(contentID)
+	if err != nil {
+		return ""
+	}
+	f, ok := a.servedFileAt(u.Path)
+	if !ok || (f.kind != servedMedia && f.kind != servedHLS) {
+		return ""
+	}
+	return f.filename
+}
+
+// recordPosition keeps how far the chromecast is through 'media', if it
+// is local media, to resume it from. 'previous' is the media before this
+// status.
+func (a *Application) recordPosition(previous, media *cast.Media) {
+	sameSession := previous != nil && previous.MediaSessionId == media.MediaSessionId
+	contentID, duration := media.Media.ContentId, media.Media.Duration
+	if contentID == "" && sameSession {
+		// The chromecast doesn't always say what finished.
+		contentID, duration = previous.Media.ContentId, previous.Media.Duration
+	}
+
+	// When the queue moves on from an item, it is done with.
+	if sameSession && previous.PlayerState == "PLAYING" && previous.Media.ContentId != "" && previous.Media.ContentId != contentID {
+		if filename := a.servedFilename(previous.Media.ContentId); filename != "" {
+			a.servedMu.Lock()
+			p := a.playedItems[filename]
+			if p.Duration > 0 {
+				p.Position = p.Duration
+				a.playedItems[filename] = p
+			}
+			a.servedMu.Unlock()
+		}
+	}
+
+	filename := a.servedFilename(contentID)
+	if filename == "" {
+		return
+	}
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	p := a.playedItems[filename]
+	p.ContentID = filename
+	if duration > 0 {
+		p.Duration = duration
+	}
+	switch {
+	case media.PlayerState == "IDLE" && media.IdleReason == "FINISHED":
+		p.Position = p.Duration
+	case media.PlayerState == "IDLE":
+		// Stopped, keep where it got to.
+	case media.CurrentTime > 0:
+		p.Position = media.CurrentTime
+	}
+	a.playedItems[filename] = p
+
+	// The UI asks for the status every second, so don't write it out every
+	// time while playing.
+	if now := time.Now(); media.PlayerState != "PLAYING" || now.Sub(a.positionSaved) >= positionInterval {
+		a.positionSaved = now
+		if err := a.writePlayedItems(); err != nil {
+			a.log("unable to save played items: %v", err)
+		}
+	}
+}
+
+// pollPositions asks for the status of local media while it is playing,
+// the chromecast doesn't send its position otherwise.
+func (a *Application) pollPositions() {
+	t := time.NewTicker(positionInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.closed:
+			return
+		case <-t.C:
+		}
+		media := a.Media()
+		if media == nil || media.PlayerState != "PLAYING" || a.servedFilename(media.Media.ContentId) == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), positionInterval)
+		if _, err := a.getMediaStatus(ctx); err != nil {
+			a.log("unable to get media position: %v", err)
+		}
+		cancel()
+	}
+}
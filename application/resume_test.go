@@ -0,0 +1,105 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+func TestApplicationResume(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	// Pretend the media is being served, rather than starting the
+	// streaming server.
+	const filename = "/videos/movie & more.mp4"
+	mi := mediaItem{filename: filename, contentType: "video/mp4"}
+	if err := app.LaunchApp(ctx, defaultChromecastAppId); err != nil {
+		t.Fatalf("unable to launch app: %v", err)
+	}
+	if _, err := app.SendMessageAndWait(ctx, cast.NamespaceMedia, &cast.LoadMediaCommand{
+		PayloadHeader: cast.LoadHeader,
+		Media: cast.MediaItem{
+			ContentId:   app.servedURL("127.0.0.1", servedMedia, filename, false),
+			ContentType: "video/mp4",
+			StreamType:  "BUFFERED",
+			Duration:    100,
+		},
+		Autoplay: true,
+	}); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+
+	receiver.SetCurrentTime(42)
+	if err := app.Update(ctx); err != nil {
+		t.Fatalf("unable to update: %v", err)
+	}
+	played := app.PlayedItems()[filename]
+	if played.Position != 42 || played.Duration != 100 {
+		t.Fatalf("unexpected played item %+v", played)
+	}
+	options := newLoadOptions([]LoadOption{WithResume(DefaultWatchedThreshold)})
+	if got := app.resumePosition(mi, options); got != 42 {
+		t.Fatalf("resuming from %.0fs, expected 42s", got)
+	}
+	if got := app.resumePosition(mi, newLoadOptions(nil)); got != 0 {
+		t.Fatalf("starting from %.0fs without resuming, expected the start", got)
+	}
+	if got := app.resumePosition(mediaItem{filename: filename, transcode: true, live: true}, options); got != 0 {
+		t.Fatalf("resuming live transcoded media from %.0fs, expected the start", got)
+	}
+
+	// Finished media is watched, and starts from the beginning again.
+	receiver.FinishMedia()
+	waitFor(t, "the media to finish", func() bool { return app.PlayedItems()[filename].Watched(DefaultWatchedThreshold) })
+	if got := app.resumePosition(mi, options); got != 0 {
+		t.Fatalf("resuming watched media from %.0fs, expected the start", got)
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		offset   string
+		expected float32
+		invalid  bool
+	}{
+		{offset: "90", expected: 90},
+		{offset: "1.5", expected: 1.5},
+		{offset: "90s", expected: 90},
+		{offset: "1h02m", expected: 3720},
+		{offset: "-10", invalid: true},
+		{offset: "-1m", invalid: true},
+		{offset: "soon", invalid: true},
+	}
+	for _, test := range tests {
+		got, err := ParseOffset(test.offset)
+		if (err != nil) != test.invalid || got != test.expected {
+			t.Errorf("%s: parsed %0.2f (%v), expected %0.2f", test.offset, got, err, test.expected)
+		}
+	}
+}
+
+func TestApplicationLoadWithStart(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	const appID = "ABCD1234"
+	if err := app.Load(ctx, "http://example.com/movie.mp4", "video/mp4", false, true, true, WithStart(3720), WithAppID(appID)); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+	if got := receiver.Application().AppId; got != appID {
+		t.Fatalf("media loaded with receiver %s, expected %s", got, appID)
+	}
+	if got := receiver.Media().CurrentTime; got != 3720 {
+		t.Fatalf("media started at %.0fs, expected 3720s", got)
+	}
+
+	// Live media can't start partway through.
+	if got := app.startPosition(mediaItem{contentURL: "http://example.com/radio", live: true}, newLoadOptions([]LoadOption{WithStart(60)})); got != 0 {
+		t.Fatalf("starting live media at %.0fs, expected the start", got)
+	}
+}
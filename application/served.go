@@ -0,0 +1,236 @@
+package application
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// servedPrefix is where the streaming server serves files, at
+// "/m/<token>/<name>".
+const servedPrefix = "/m/"
+
+// servedKind is what a served file is served as.
+type servedKind int
+
+const (
+	servedMedia servedKind = iota
+	servedSubtitles
+	servedCoverArt
+	// Media transcoded to HLS, its playlist and segments.
+	servedHLS
+	// The output of a command, which isn't a file that can be played
+	// again.
+	servedCommand
+)
+
+// servedFile is a file the streaming server serves. It is served at a
+// random token, so the url doesn't give away where the file is, or let any
+// other file be asked for.
+type servedFile struct {
+	kind     servedKind
+	filename string
+	// Media that is transcoded as it is served.
+	transcode bool
+	// The command whose output is served, for 'servedCommand'.
+	command string
+	// The load it was last served for, see 'newServedGeneration'.
+	generation int
+}
+
+// WithServeToDeviceOnly only serves media to the chromecast that is
+// connected to, rather than to anything on the network that has its url.
+func WithServeToDeviceOnly(deviceOnly bool) ApplicationOption {
+	return func(a *Application) {
+		a.serveToDeviceOnly = deviceOnly
+	}
+}
+
+// serveFile has the streaming server serve 'filename' as 'kind', and
+// returns the path it is served at. A file is served at the same path
+// until other media is loaded, or the application is closed.
+func (a *Application) serveFile(kind servedKind, filename string, transcode bool) string {
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	for token, f := range a.servedFiles {
+		if f.kind == kind && f.filename == filename && f.transcode == transcode {
+			f.generation = a.servedGeneration
+			a.servedFiles[token] = f
+			return servedPrefix + token + "/" + f.name()
+		}
+	}
+	f := servedFile{kind: kind, filename: filename, transcode: transcode, generation: a.servedGeneration}
+	token := newToken()
+	a.servedFiles[token] = f
+	return servedPrefix + token + "/" + f.name()
+}
+
+// servedURL returns the url the streaming server serves 'filename' at.
+// The streaming server must already be started.
+func (a *Application) servedURL(localIP string, kind servedKind, filename string, transcode bool) string {
+	return fmt.Sprintf("http://%s:%d%s", localIP, a.serverPort, a.serveFile(kind, filename, transcode))
+}
+
+// servedCommandURL returns the url the streaming server serves the output
+// of 'command' at, as 'name'. Each call serves it at a new url. The
+// streaming server must already be started.
+func (a *Application) servedCommandURL(localIP, name, command string) string {
+	token := newToken()
+	a.servedMu.Lock()
+	f := servedFile{kind: servedCommand, filename: name, command: command, generation: a.servedGeneration}
+	a.servedFiles[token] = f
+	a.servedMu.Unlock()
+	return fmt.Sprintf("http://%s:%d%s%s/%s", localIP, a.serverPort, servedPrefix, token, f.name())
+}
+
+// servedFileAt returns the file served at the path 'urlPath'.
+func (a *Application) servedFileAt(urlPath string) (servedFile, bool) {
+	if !strings.HasPrefix(urlPath, servedPrefix) {
+		return servedFile{}, false
+	}
+	token := strings.SplitN(strings.TrimPrefix(urlPath, servedPrefix), "/", 2)[0]
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	f, ok := a.servedFiles[token]
+	return f, ok
+}
+
+// newServedGeneration is called before serving the files of media that is
+// about to be loaded. Once it has been loaded, 'expireServedFiles' stops
+// serving the files of the media that was loaded before.
+func (a *Application) newServedGeneration() int {
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	a.servedGeneration++
+	return a.servedGeneration
+}
+
+// expireServedFiles stops serving the files that were last served for
+// media loaded before 'generation', their urls no longer work.
+func (a *Application) expireServedFiles(generation int) {
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	for token, f := range a.servedFiles {
+		if f.generation < generation {
+			delete(a.servedFiles, token)
+		}
+	}
+}
+
+// forgetServedFiles stops serving every file, their urls no longer work
+// even if the streaming server is started again.
+func (a *Application) forgetServedFiles() {
+	a.servedMu.Lock()
+	a.servedFiles = map[string]servedFile{}
+	a.servedMu.Unlock()
+}
+
+// serveServed serves the file the request is for, if it is allowed to
+// have it.
+func (a *Application) serveServed(w http.ResponseWriter, r *http.Request, serveMedia func(http.ResponseWriter, *http.Request, servedFile)) {
+	f, ok := a.servedFileAt(r.URL.Path)
+	if !ok {
+		a.log("no file served at %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	if !a.allowedClient(r) {
+		a.log("not serving %s to %s", f.filename, r.RemoteAddr)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch f.kind {
+	case servedMedia:
+		a.servedMu.Lock()
+		pi := a.playedItems[f.filename]
+		pi.ContentID = f.filename
+		pi.Started = time.Now().Unix()
+		a.playedItems[f.filename] = pi
+		a.writePlayedItems()
+		a.servedMu.Unlock()
+
+		a.log("serving media %s, transcode=%t", f.filename, f.transcode)
+		serveMedia(w, r, f)
+		a.log("method=%s, headers=%v, reponse_headers=%v", r.Method, r.Header, w.Header())
+
+		a.servedMu.Lock()
+		pi = a.playedItems[f.filename]
+		// TODO(vishen): make this a pointer?
+		pi.Finished = time.Now().Unix()
+		a.playedItems[f.filename] = pi
+		a.writePlayedItems()
+		a.servedMu.Unlock()
+	case servedSubtitles:
+		a.serveSubtitles(w, r, f.filename)
+	case servedCoverArt:
+		a.serveCoverArt(w, r, f.filename)
+	case servedHLS:
+		a.serveHLS(w, r, f.filename)
+	case servedCommand:
+		a.log("serving the output of %q", f.command)
+		a.serveCommandOutput(w, r, f)
+	}
+}
+
+// allowedClient reports whether the request can be served, when serving to
+// the chromecast only it has to come from it.
+func (a *Application) allowedClient(r *http.Request) bool {
+	if !a.serveToDeviceOnly {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	deviceIP, err := a.conn.RemoteAddr()
+	if err != nil {
+		return false
+	}
+	client, device := net.ParseIP(host), net.ParseIP(deviceIP)
+	return client != nil && client.Equal(device)
+}
+
+// name is the name the file is served as, which only says what it is to
+// the chromecast.
+func (f servedFile) name() string {
+	base := filepath.Base(f.filename)
+	switch {
+	case f.kind == servedSubtitles:
+		base = strings.TrimSuffix(base, filepath.Ext(base)) + ".vtt"
+	case f.kind == servedCoverArt:
+		base = "cover"
+	case f.kind == servedHLS:
+		// The segments are next to it.
+		return hlsPlaylist
+	case f.transcode:
+		base = strings.TrimSuffix(base, filepath.Ext(base)) + ".mp4"
+	}
+	return safeName(base)
+}
+
+// safeName replaces anything in 'name' that isn't a letter, number, '.',
+// '-' or '_' so it can be used in a url as is.
+func safeName(name string) string {
+	safe := []rune(name)
+	for i, c := range safe {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			safe[i] = '_'
+		}
+	}
+	return string(safe)
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// There is nothing sensible to serve files at without it.
+		panic(fmt.Sprintf("unable to generate token: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
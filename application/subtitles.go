@@ -0,0 +1,194 @@
+package application
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// WithSubtitles shows the subtitles in 'filename', a .srt or .vtt file,
+// instead of any found next to the media. With 'QueueLoad' they are shown
+// with the first item.
+func WithSubtitles(filename string) LoadOption {
+	return func(o *loadOptions) {
+		o.subtitles = filename
+	}
+}
+
+// WithSubtitlesDir looks for subtitles files in 'dir' as well as next to
+// the media.
+func WithSubtitlesDir(dir string) LoadOption {
+	return func(o *loadOptions) {
+		o.subtitlesDir = dir
+	}
+}
+
+// Matches the language in subtitles files named like 'movie.en.srt' or
+// 'movie.pt-BR.vtt'.
+var subtitlesLanguage = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,4})?$`)
+
+type subtitlesFile struct {
+	filename string
+	language string
+}
+
+// subtitlesFor returns the subtitles to show with 'mi', 'first' is whether
+// it is the first item being loaded.
+func (o loadOptions) subtitlesFor(mi mediaItem, first bool) ([]subtitlesFile, error) {
+	if first && o.subtitles != "" {
+		if _, err := os.Stat(o.subtitles); err != nil {
+			return nil, errors.Wrapf(err, "unable to find subtitles %q", o.subtitles)
+		}
+		return []subtitlesFile{{filename: o.subtitles}}, nil
+	}
+	// Only local media can have subtitles next to it.
+	if mi.filename == "" || strings.HasPrefix(mi.contentType, "image/") {
+		return nil, nil
+	}
+	dirs := []string{filepath.Dir(mi.filename)}
+	if o.subtitlesDir != "" {
+		dirs = append(dirs, o.subtitlesDir)
+	}
+	return findSubtitles(mi.filename, dirs...), nil
+}
+
+// findSubtitles looks in 'dirs' for the .srt and .vtt files with the same
+// name as the media in 'filename', optionally with a language, ie: for
+// 'movie.mkv' both 'movie.srt' and 'movie.en.vtt' are found. Those without
+// a language come first.
+func findSubtitles(filename string, dirs ...string) []subtitlesFile {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	var found []subtitlesFile
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			ext := strings.ToLower(filepath.Ext(f.Name()))
+			if f.IsDir() || (ext != ".srt" && ext != ".vtt") {
+				continue
+			}
+			name := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+			var language string
+			if name != base {
+				if !strings.HasPrefix(name, base+".") || !subtitlesLanguage.MatchString(name[len(base)+1:]) {
+					continue
+				}
+				language = name[len(base)+1:]
+			}
+			found = append(found, subtitlesFile{filename: filepath.Join(dir, f.Name()), language: language})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].language == "" && found[j].language != ""
+	})
+	return found
+}
+
+// subtitleTracks serves the subtitles files from the streaming server, and
+// returns the text tracks for them.
+func (a *Application) subtitleTracks(files []subtitlesFile) ([]cast.MediaTrack, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	localIP, err := a.getLocalIP()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.startStreamingServer(); err != nil {
+		return nil, errors.Wrap(err, "unable to start streaming server")
+	}
+
+	tracks := make([]cast.MediaTrack, len(files))
+	for i, f := range files {
+		name := f.language
+		if name == "" {
+			name = filepath.Base(f.filename)
+		}
+		tracks[i] = cast.MediaTrack{
+			TrackId:          i + 1,
+			Type:             "TEXT",
+			Subtype:          "SUBTITLES",
+			TrackContentId:   a.servedURL(localIP, servedSubtitles, f.filename, false),
+			TrackContentType: "text/vtt",
+			Name:             name,
+			Language:         f.language,
+		}
+		a.log("subtitles track %d: %s", tracks[i].TrackId, f.filename)
+	}
+	return tracks, nil
+}
+
+// activeTrackIds are the tracks shown when the media starts, the first of
+// the subtitles.
+func activeTrackIds(tracks []cast.MediaTrack) []int {
+	if len(tracks) == 0 {
+		return nil
+	}
+	return []int{tracks[0].TrackId}
+}
+
+// serveSubtitles serves a subtitles file as WebVTT, converting it first if
+// it is SubRip.
+func (a *Application) serveSubtitles(w http.ResponseWriter, r *http.Request, filename string) {
+	// The receiver fetches text tracks with cross-origin requests.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	if strings.ToLower(filepath.Ext(filename)) == ".vtt" {
+		http.ServeFile(w, r, filename)
+		return
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		http.Error(w, "Unable to open subtitles", 500)
+		return
+	}
+	defer f.Close()
+	if err := srtToVTT(w, f); err != nil {
+		log.WithField("package", "application").WithField("filename", filename).WithError(err).Error("error converting subtitles")
+	}
+}
+
+// srtToVTT converts SubRip subtitles to WebVTT. The cues are the same
+// other than the milliseconds in the timings being after a '.' rather
+// than a ','.
+func srtToVTT(w io.Writer, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if first {
+			// Drop the byte order mark.
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.Contains(line, "-->") {
+			line = strings.Replace(line, ",", ".", -1)
+		}
+		bw.WriteString(line)
+		bw.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "unable to read subtitles")
+	}
+	return bw.Flush()
+}
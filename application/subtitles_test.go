@@ -0,0 +1,132 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+const testSRT = "\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\nHello, world\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nGoodbye\r\n"
+
+func TestSrtToVTT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := srtToVTT(&buf, strings.NewReader(testSRT)); err != nil {
+		t.Fatalf("unable to convert: %v", err)
+	}
+	expected := "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello, world\n\n2\n00:00:03.000 --> 00:00:04.000\nGoodbye\n"
+	if buf.String() != expected {
+		t.Fatalf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+// tempDir returns a directory that is removed once the test is done.
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "go-chromecast-test-")
+	if err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestFindSubtitles(t *testing.T) {
+	dir := tempDir(t)
+	other := tempDir(t)
+	for _, filename := range []string{
+		filepath.Join(dir, "movie.mp4"),
+		filepath.Join(dir, "movie.en.vtt"),
+		filepath.Join(dir, "movie.srt"),
+		filepath.Join(dir, "movie.part2.srt"),
+		filepath.Join(dir, "movie2.srt"),
+		filepath.Join(other, "movie.pt-BR.srt"),
+	} {
+		if err := ioutil.WriteFile(filename, nil, 0600); err != nil {
+			t.Fatalf("unable to write %s: %v", filename, err)
+		}
+	}
+
+	found := findSubtitles(filepath.Join(dir, "movie.mp4"), dir, other)
+	expected := []subtitlesFile{
+		{filename: filepath.Join(dir, "movie.srt")},
+		{filename: filepath.Join(dir, "movie.en.vtt"), language: "en"},
+		{filename: filepath.Join(other, "movie.pt-BR.srt"), language: "pt-BR"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("found %v, expected %v", found, expected)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Fatalf("found %v, expected %v", found, expected)
+		}
+	}
+}
+
+func TestApplicationLoadSubtitles(t *testing.T) {
+	dir := tempDir(t)
+	media := filepath.Join(dir, "movie.mp4")
+	if err := ioutil.WriteFile(media, []byte("not really a movie"), 0600); err != nil {
+		t.Fatalf("unable to write media: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "movie.srt"), []byte(testSRT), 0600); err != nil {
+		t.Fatalf("unable to write subtitles: %v", err)
+	}
+
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	if err := app.Load(context.Background(), media, "", false, false, true); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return receiver.Media() != nil })
+
+	loads := receiver.MessagesOfType("LOAD")
+	var load cast.LoadMediaCommand
+	if err := json.Unmarshal([]byte(loads[len(loads)-1].GetPayloadUtf8()), &load); err != nil {
+		t.Fatalf("unable to decode LOAD: %v", err)
+	}
+	if len(load.Media.Tracks) != 1 || len(load.ActiveTrackIds) != 1 || load.ActiveTrackIds[0] != load.Media.Tracks[0].TrackId {
+		t.Fatalf("unexpected tracks %#v, active %v", load.Media.Tracks, load.ActiveTrackIds)
+	}
+	track := load.Media.Tracks[0]
+	if track.Type != "TEXT" || track.TrackContentType != "text/vtt" {
+		t.Fatalf("unexpected track: %#v", track)
+	}
+
+	resp, err := http.Get(track.TrackContentId)
+	if err != nil {
+		t.Fatalf("unable to fetch subtitles: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\n") {
+		t.Fatalf("unexpected subtitles response %d: %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/vtt") {
+		t.Fatalf("unexpected subtitles headers: %v", resp.Header)
+	}
+
+	if strings.Contains(track.TrackContentId, dir) || !strings.HasSuffix(track.TrackContentId, "/movie.vtt") {
+		t.Fatalf("subtitles are served at %s, which says where they are", track.TrackContentId)
+	}
+
+	// Only the subtitles that were loaded are served.
+	u, _ := url.Parse(track.TrackContentId)
+	u.Path = servedPrefix + newToken() + "/other.vtt"
+	resp, err = http.Get(u.String())
+	if err != nil {
+		t.Fatalf("unable to fetch subtitles: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("serving unknown subtitles returned %d, expected %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,138 @@
+package application
+
+import (
+	"context"
+	"strings"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// Tracks returns the tracks of the current media, and the ids of the ones
+// that are active.
+func (a *Application) Tracks() ([]cast.MediaTrack, []int) {
+	media := a.Media()
+	if media == nil {
+		return nil, nil
+	}
+	return media.Media.Tracks, media.ActiveTrackIds
+}
+
+// SetActiveTracks turns on the tracks in 'trackIds', and every other
+// track off.
+func (a *Application) SetActiveTracks(ctx context.Context, trackIds []int) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	for _, id := range trackIds {
+		if findTrack(media.Media.Tracks, id) == nil {
+			return ErrTrackNotFound
+		}
+	}
+	return a.editTracks(ctx, media, trackIds, nil)
+}
+
+// EnableTrack turns on the track 'trackId' instead of the active track of
+// the same type, ie: switches the subtitles or the audio language.
+func (a *Application) EnableTrack(ctx context.Context, trackId int) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	track := findTrack(media.Media.Tracks, trackId)
+	if track == nil {
+		return ErrTrackNotFound
+	}
+	return a.editTracks(ctx, media, append(activeTracksExcept(media, track.Type), trackId), nil)
+}
+
+// DisableSubtitles turns off all text tracks.
+func (a *Application) DisableSubtitles(ctx context.Context) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editTracks(ctx, media, activeTracksExcept(media, "TEXT"), nil)
+}
+
+// SetAudioLanguage switches to the first audio track in 'language', ie:
+// 'en' or 'pt-BR'. A language without a region matches every region.
+func (a *Application) SetAudioLanguage(ctx context.Context, language string) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	for _, track := range media.Media.Tracks {
+		if track.Type == "AUDIO" && matchesLanguage(track.Language, language) {
+			return a.EnableTrack(ctx, track.TrackId)
+		}
+	}
+	return ErrTrackNotFound
+}
+
+// SetTextTrackStyle changes how the text tracks are shown.
+func (a *Application) SetTextTrackStyle(ctx context.Context, style cast.TextTrackStyle) error {
+	media := a.Media()
+	if media == nil {
+		return ErrMediaNotYetInitialised
+	}
+	return a.editTracks(ctx, media, media.ActiveTrackIds, &style)
+}
+
+func (a *Application) editTracks(ctx context.Context, media *cast.Media, trackIds []int, style *cast.TextTrackStyle) error {
+	// An empty list turns every track off, where leaving it out would be
+	// sent as null.
+	if trackIds == nil {
+		trackIds = []int{}
+	}
+	// Wait for the new media status, so one edit after another starts
+	// from the tracks the previous one left active.
+	apiMessage, err := a.sendAndWaitMediaRecv(ctx, &cast.EditTracksInfo{
+		PayloadHeader:  cast.EditTracksHeader,
+		MediaSessionId: media.MediaSessionId,
+		ActiveTrackIds: trackIds,
+		TextTrackStyle: style,
+	})
+	if err != nil {
+		return err
+	}
+	decoded, err := cast.Decode(apiMessage)
+	if err != nil {
+		return err
+	}
+	if _, ok := decoded.(*cast.MediaStatusResponse); !ok {
+		return unexpectedResponse(decoded)
+	}
+	return nil
+}
+
+func findTrack(tracks []cast.MediaTrack, trackId int) *cast.MediaTrack {
+	for i := range tracks {
+		if tracks[i].TrackId == trackId {
+			return &tracks[i]
+		}
+	}
+	return nil
+}
+
+// activeTracksExcept returns the active tracks that aren't of 'trackType'.
+func activeTracksExcept(media *cast.Media, trackType string) []int {
+	trackIds := []int{}
+	for _, id := range media.ActiveTrackIds {
+		if track := findTrack(media.Media.Tracks, id); track == nil || track.Type != trackType {
+			trackIds = append(trackIds, id)
+		}
+	}
+	return trackIds
+}
+
+func matchesLanguage(trackLanguage, language string) bool {
+	if strings.EqualFold(trackLanguage, language) {
+		return true
+	}
+	if strings.Contains(language, "-") {
+		return false
+	}
+	base := strings.SplitN(trackLanguage, "-", 2)[0]
+	return strings.EqualFold(base, language)
+}
@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+func TestApplicationSwitchTracks(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	if err := app.LaunchApp(ctx, defaultChromecastAppId); err != nil {
+		t.Fatalf("unable to launch app: %v", err)
+	}
+	// Load media with tracks of its own, like a sender that knows what is
+	// in the stream would.
+	if _, err := app.SendMessageAndWait(ctx, cast.NamespaceMedia, &cast.LoadMediaCommand{
+		PayloadHeader: cast.LoadHeader,
+		Media: cast.MediaItem{
+			ContentId:   "http://example.com/movie.mp4",
+			ContentType: "video/mp4",
+			StreamType:  "BUFFERED",
+			Tracks: []cast.MediaTrack{
+				{TrackId: 1, Type: "TEXT", Subtype: "SUBTITLES", Language: "en"},
+				{TrackId: 2, Type: "TEXT", Subtype: "SUBTITLES", Language: "fr"},
+				{TrackId: 3, Type: "AUDIO", Language: "en-US"},
+				{TrackId: 4, Type: "AUDIO", Language: "fr-CA"},
+			},
+		},
+		Autoplay:       true,
+		ActiveTrackIds: []int{1, 3},
+	}); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	tracks, active := app.Tracks()
+	if len(tracks) != 4 || !reflect.DeepEqual(active, []int{1, 3}) {
+		t.Fatalf("unexpected tracks %v, active %v", tracks, active)
+	}
+
+	steps := []struct {
+		name   string
+		edit   func() error
+		active []int
+	}{
+		{"enable track", func() error { return app.EnableTrack(ctx, 2) }, []int{3, 2}},
+		{"disable subtitles", func() error { return app.DisableSubtitles(ctx) }, []int{3}},
+		{"set audio language", func() error { return app.SetAudioLanguage(ctx, "fr") }, []int{4}},
+		{"set active tracks", func() error { return app.SetActiveTracks(ctx, nil) }, []int{}},
+	}
+	for _, step := range steps {
+		if err := step.edit(); err != nil {
+			t.Fatalf("unable to %s: %v", step.name, err)
+		}
+		if got := receiver.Media().ActiveTrackIds; !reflect.DeepEqual(got, step.active) {
+			t.Fatalf("after %s the receiver has tracks %v active, expected %v", step.name, got, step.active)
+		}
+		if _, got := app.Tracks(); !reflect.DeepEqual(got, step.active) {
+			t.Fatalf("after %s the application has tracks %v active, expected %v", step.name, got, step.active)
+		}
+	}
+
+	if err := app.EnableTrack(ctx, 5); err != ErrTrackNotFound {
+		t.Fatalf("enabling an unknown track returned %v, expected %v", err, ErrTrackNotFound)
+	}
+	if err := app.SetAudioLanguage(ctx, "de"); err != ErrTrackNotFound {
+		t.Fatalf("switching to a missing language returned %v, expected %v", err, ErrTrackNotFound)
+	}
+
+	style := cast.TextTrackStyle{FontScale: 1.5, EdgeType: "OUTLINE"}
+	if err := app.SetTextTrackStyle(ctx, style); err != nil {
+		t.Fatalf("unable to set text track style: %v", err)
+	}
+	if got := receiver.Media().Media.TextTrackStyle; got == nil || *got != style {
+		t.Fatalf("unexpected text track style %v, expected %v", got, style)
+	}
+}
@@ -0,0 +1,156 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// interruptedTimeout is how long to wait, after the media being waited on
+// is interrupted, for it to be loaded again. Seeking in media being
+// transcoded loads it again, transcoding from the new position.
+var interruptedTimeout = 3 * time.Second
+
+// probeDuration returns the duration, in seconds, of the media 'filename'
+// using ffprobe, or 0 if it doesn't know.
+func probeDuration(filename string) (float32, error) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return 0, nil
+	}
+	out, err := exec.Command(ffprobe, "-v", "quiet", "-print_format", "json", "-show_format", filename).Output()
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to run ffprobe")
+	}
+	var probe struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, errors.Wrap(err, "unable to decode ffprobe output")
+	}
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 32)
+	if err != nil {
+		return 0, nil
+	}
+	return float32(duration), nil
+}
+
+// transcodedURL returns the url of the media being transcoded at
+// 'contentURL', transcoding from 'start' seconds into it. The url says
+// where it starts and how long the media is, so whatever seeks in it, even
+// another go-chromecast, can tell.
+func transcodedURL(contentURL string, start, duration float32) string {
+	u, err := url.Parse(contentURL)
+	if err != nil {
+		return contentURL
+	}
+	q := u.Query()
+	q.Set("start", strconv.FormatFloat(float64(start), 'f', -1, 32))
+	q.Set("duration", strconv.FormatFloat(float64(duration), 'f', -1, 32))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// transcodedPosition returns where the media being transcoded at
+// 'contentID' was transcoded from, and how long it is. 'ok' is false when
+// it isn't media that can be seeked in by transcoding it again.
+func transcodedPosition(contentID string) (start, duration float32, ok bool) {
+	u, err := url.Parse(contentID)
+	if err != nil || !strings.HasPrefix(u.Path, servedPrefix) {
+		return 0, 0, false
+	}
+	q := u.Query()
+	s, err := strconv.ParseFloat(q.Get("start"), 32)
+	if err != nil || s < 0 {
+		return 0, 0, false
+	}
+	d, err := strconv.ParseFloat(q.Get("duration"), 32)
+	if err != nil || d <= 0 {
+		return 0, 0, false
+	}
+	return float32(s), float32(d), true
+}
+
+// startAt returns 'mi' to play from 'position' seconds into it, and the
+// position the chromecast starts it at. Media being transcoded, including
+// to HLS, is transcoded from the position instead, as the chromecast can't
+// seek past what has been transcoded.
+func (mi mediaItem) startAt(position float32) (mediaItem, float32) {
+	if position <= 0 || !mi.transcode {
+		return mi, position
+	}
+	if mi.duration <= 0 {
+		// Where it was transcoded from can't be told without knowing how
+		// long it is.
+		return mi, 0
+	}
+	mi.contentURL = transcodedURL(mi.contentURL, position, mi.duration)
+	return mi, 0
+}
+
+// seekTranscoded seeks to 'position' seconds into the media being
+// transcoded, by loading it again transcoding from there.
+func (a *Application) seekTranscoded(ctx context.Context, media *cast.Media, position float32) error {
+	_, duration, _ := transcodedPosition(media.Media.ContentId)
+	if position < 0 {
+		position = 0
+	}
+	if position > duration {
+		position = duration
+	}
+	a.log("seeking to %.0fs by transcoding from there", position)
+
+	item := media.Media
+	item.ContentId = transcodedURL(item.ContentId, position, duration)
+	item.Duration = duration
+	return a.sendMediaRecv(ctx, &cast.LoadMediaCommand{
+		PayloadHeader:  cast.LoadHeader,
+		Autoplay:       true,
+		Media:          item,
+		ActiveTrackIds: media.ActiveTrackIds,
+	})
+}
+
+// transcodeArgs are the arguments to ffmpeg to transcode 'filename' to
+// something the chromecast can play, as 'plan' says, from 'start' seconds
+// into it.
+func transcodeArgs(filename string, start float32, plan transcodePlan) []string {
+	args := []string{
+		"-re", // encode at 1x playback speed, to not burn the CPU
+	}
+	if start > 0 {
+		// Before the input, so it seeks rather than decoding up to it.
+		args = append(args, "-ss", strconv.FormatFloat(float64(start), 'f', 3, 32))
+	}
+	args = append(args, "-i", filename)
+	args = append(args, plan.args()...)
+	return append(args,
+		"-f", "mp4",
+		"-movflags", "frag_keyframe+faststart",
+		"-strict", "-experimental",
+		"pipe:1",
+	)
+}
+
+// transcoding reports whether any of the media being served is being
+// transcoded.
+func (a *Application) transcoding() bool {
+	a.servedMu.Lock()
+	defer a.servedMu.Unlock()
+	for _, f := range a.servedFiles {
+		if f.transcode {
+			return true
+		}
+	}
+	return false
+}
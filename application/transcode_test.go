@@ -0,0 +1,154 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+func TestTranscodedURL(t *testing.T) {
+	const contentURL = "http://127.0.0.1:8080/m/0123/movie.mp4"
+	if _, _, ok := transcodedPosition(contentURL); ok {
+		t.Fatalf("%s can't be seeked in by transcoding it again", contentURL)
+	}
+	u := transcodedURL(contentURL, 0, 5400.5)
+	if start, duration, ok := transcodedPosition(u); !ok || start != 0 || duration != 5400.5 {
+		t.Fatalf("%s starts at %.1fs of %.1fs (%t), expected 0s of 5400.5s", u, start, duration, ok)
+	}
+	u = transcodedURL(u, 3720, 5400.5)
+	if start, duration, ok := transcodedPosition(u); !ok || start != 3720 || duration != 5400.5 {
+		t.Fatalf("%s starts at %.1fs of %.1fs (%t), expected 3720s of 5400.5s", u, start, duration, ok)
+	}
+	if _, _, ok := transcodedPosition("http://example.com/movie.mp4?start=0&duration=10"); ok {
+		t.Fatalf("urls that aren't served can't be seeked in by transcoding them again")
+	}
+
+	mi := mediaItem{contentURL: transcodedURL(contentURL, 0, 100), transcode: true, duration: 100}
+	if started, currentTime := mi.startAt(60); currentTime != 0 || started.contentURL != transcodedURL(contentURL, 60, 100) {
+		t.Fatalf("transcoded media starts at %.0fs of %s, expected it to be transcoded from 60s", currentTime, started.contentURL)
+	}
+	// HLS can only be seeked in as far as it has been transcoded.
+	playlist := "http://127.0.0.1:8080/m/token/playlist.m3u8"
+	mi = mediaItem{contentURL: playlist, transcode: true, hls: true, duration: 100}
+	if started, currentTime := mi.startAt(60); currentTime != 0 || started.contentURL != transcodedURL(playlist, 60, 100) {
+		t.Fatalf("HLS starts at %.0fs of %s, expected it to be transcoded from 60s", currentTime, started.contentURL)
+	}
+	mi = mediaItem{contentURL: contentURL}
+	if started, currentTime := mi.startAt(60); currentTime != 60 || started.contentURL != contentURL {
+		t.Fatalf("media starts at %.0fs of %s, expected 60s", currentTime, started.contentURL)
+	}
+}
+
+func TestTranscodeArgs(t *testing.T) {
+	args := transcodeArgs("/videos/movie.avi", 62.5, transcodePlan{unknown: true, profile: defaultDeviceProfile})
+	if !reflect.DeepEqual(args[:5], []string{"-re", "-ss", "62.500", "-i", "/videos/movie.avi"}) {
+		t.Fatalf("unexpected ffmpeg arguments %q", args)
+	}
+	args = transcodeArgs("/videos/movie.avi", 0, transcodePlan{unknown: true, profile: defaultDeviceProfile})
+	if !reflect.DeepEqual(args[:3], []string{"-re", "-i", "/videos/movie.avi"}) {
+		t.Fatalf("unexpected ffmpeg arguments %q", args)
+	}
+}
+
+func TestApplicationSeekTranscoded(t *testing.T) {
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	// Pretend the media is being transcoded, rather than starting the
+	// streaming server and ffmpeg.
+	contentURL := app.servedURL("127.0.0.1", servedMedia, "/videos/movie.avi", true)
+	if err := app.LaunchApp(ctx, defaultChromecastAppId); err != nil {
+		t.Fatalf("unable to launch app: %v", err)
+	}
+	if _, err := app.SendMessageAndWait(ctx, cast.NamespaceMedia, &cast.LoadMediaCommand{
+		PayloadHeader: cast.LoadHeader,
+		Media: cast.MediaItem{
+			ContentId:   transcodedURL(contentURL, 0, 100),
+			ContentType: "video/mp4",
+			StreamType:  cast.StreamTypeBuffered,
+			Duration:    100,
+		},
+		Autoplay: true,
+	}); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+
+	position := func(currentTime float32) (float32, float32) {
+		t.Helper()
+		receiver.SetCurrentTime(currentTime)
+		if err := app.Update(ctx); err != nil {
+			t.Fatalf("unable to update: %v", err)
+		}
+		media := app.Media()
+		return media.CurrentTime, media.Media.Duration
+	}
+	if current, duration := position(10); current != 10 || duration != 100 {
+		t.Fatalf("at %.0fs of %.0fs, expected 10s of 100s", current, duration)
+	}
+
+	if err := app.SeekToTime(ctx, 60); err != nil {
+		t.Fatalf("unable to seek: %v", err)
+	}
+	waitFor(t, "the media to be transcoded from 60s", func() bool {
+		return receiver.Media().Media.ContentId == transcodedURL(contentURL, 60, 100)
+	})
+	if current, duration := position(5); current != 65 || duration != 100 {
+		t.Fatalf("at %.0fs of %.0fs, expected 65s of 100s", current, duration)
+	}
+
+	if err := app.Seek(ctx, 10); err != nil {
+		t.Fatalf("unable to seek: %v", err)
+	}
+	waitFor(t, "the media to be transcoded from 75s", func() bool {
+		return receiver.Media().Media.ContentId == transcodedURL(contentURL, 75, 100)
+	})
+}
+
+func TestApplicationWaitForMediaSeekingTranscoded(t *testing.T) {
+	defer func(timeout time.Duration) { interruptedTimeout = timeout }(interruptedTimeout)
+	interruptedTimeout = 50 * time.Millisecond
+
+	receiver := startReceiver(t)
+	app := startApplication(t, receiver)
+	ctx := context.Background()
+
+	contentURL := transcodedURL(app.servedURL("127.0.0.1", servedMedia, "/videos/movie.avi", true), 0, 100)
+	if err := app.LaunchApp(ctx, defaultChromecastAppId); err != nil {
+		t.Fatalf("unable to launch app: %v", err)
+	}
+	if _, err := app.SendMessageAndWait(ctx, cast.NamespaceMedia, &cast.LoadMediaCommand{
+		PayloadHeader: cast.LoadHeader,
+		Media:         cast.MediaItem{ContentId: contentURL, ContentType: "video/mp4", StreamType: cast.StreamTypeBuffered},
+		Autoplay:      true,
+	}); err != nil {
+		t.Fatalf("unable to load media: %v", err)
+	}
+	waitFor(t, "the media to load", func() bool { return app.Media() != nil })
+
+	sub := app.Subscribe()
+	defer sub.Unsubscribe()
+	done := make(chan error, 1)
+	go func() { done <- app.waitForMedia(ctx, sub, 0) }()
+
+	// Seeking loads the media again, interrupting what was playing.
+	app.publish(MediaStatusChanged{Status: []cast.Media{{MediaSessionId: 1, PlayerState: "IDLE", IdleReason: "INTERRUPTED"}}})
+	select {
+	case err := <-done:
+		t.Fatalf("stopped waiting when the media was loaded again: %v", err)
+	case <-time.After(4 * interruptedTimeout):
+	}
+
+	receiver.FinishMedia()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error waiting for the media: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("still waiting after the media finished")
+	}
+}
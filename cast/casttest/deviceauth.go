@@ -0,0 +1,201 @@
+package casttest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/vishen/go-chromecast/cast"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+// deviceIdentity is the test CA, and the device certificate issued by its
+// intermediate, that every receiver authenticates with. It is created once,
+// as RSA keys are slow to generate.
+type deviceIdentity struct {
+	caCertificate           *x509.Certificate
+	intermediateCertificate []byte
+	deviceCertificate       []byte
+	deviceKey               *rsa.PrivateKey
+}
+
+var (
+	identityOnce sync.Once
+	identity     *deviceIdentity
+)
+
+func testIdentity() *deviceIdentity {
+	identityOnce.Do(func() {
+		var err error
+		if identity, err = newDeviceIdentity(); err != nil {
+			panic("casttest: unable to create device identity: " + err.Error())
+		}
+	})
+	return identity
+}
+
+func newDeviceIdentity() (*deviceIdentity, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "casttest CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	caCertificate, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like real devices, the device certificate isn't issued by the CA
+	// itself, so it only verifies with the intermediate sent along.
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	intermediateTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "casttest intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	intermediateDER, err := x509.CreateCertificate(rand.Reader, intermediateTemplate, caCertificate, &intermediateKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	intermediateCertificate, err := x509.ParseCertificate(intermediateDER)
+	if err != nil {
+		return nil, err
+	}
+
+	// Device certificates have RSA keys.
+	deviceKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	deviceTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "casttest device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	deviceCertificate, err := x509.CreateCertificate(rand.Reader, deviceTemplate, intermediateCertificate, &deviceKey.PublicKey, intermediateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &deviceIdentity{
+		caCertificate:           caCertificate,
+		intermediateCertificate: intermediateDER,
+		deviceCertificate:       deviceCertificate,
+		deviceKey:               deviceKey,
+	}, nil
+}
+
+// TrustStore returns a pool holding the test CA that issues the device
+// certificate receivers authenticate with, for 'cast.Connection.SetTrustStore'.
+func TrustStore() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(testIdentity().caCertificate)
+	return roots
+}
+
+// TrustStorePEM is the test CA of 'TrustStore' PEM encoded, as read by
+// 'cast.LoadTrustStore'.
+func TrustStorePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: testIdentity().caCertificate.Raw})
+}
+
+// ImpersonateDevice makes the receiver answer authentication challenges
+// like something pretending to be a chromecast, by replaying a signature
+// that wasn't made for its TLS certificate.
+func (r *Receiver) ImpersonateDevice() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.impersonate = true
+}
+
+// handleDeviceAuth answers an authentication challenge like a current
+// device, signing the nonce with the hash it was asked for. 'mu' must be
+// held.
+func (r *Receiver) handleDeviceAuth(conn net.Conn, message *pb.CastMessage) {
+	var request pb.DeviceAuthMessage
+	if err := proto.Unmarshal(message.GetPayloadBinary(), &request); err != nil || request.GetChallenge() == nil {
+		r.writeDeviceAuth(conn, message, &pb.DeviceAuthMessage{
+			Error: &pb.AuthError{ErrorType: pb.AuthError_INTERNAL_ERROR.Enum()},
+		})
+		return
+	}
+
+	id := testIdentity()
+	challenge := request.GetChallenge()
+	certificate := r.certificate
+	if r.impersonate {
+		certificate = id.deviceCertificate
+	}
+	signed := append(append([]byte{}, challenge.GetSenderNonce()...), certificate...)
+	hash := crypto.SHA1
+	var hashed []byte
+	if challenge.GetHashAlgorithm() == pb.HashAlgorithm_SHA256 {
+		sum := sha256.Sum256(signed)
+		hash, hashed = crypto.SHA256, sum[:]
+	} else {
+		sum := sha1.Sum(signed)
+		hashed = sum[:]
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, id.deviceKey, hash, hashed)
+	if err != nil {
+		return
+	}
+	r.writeDeviceAuth(conn, message, &pb.DeviceAuthMessage{
+		Response: &pb.AuthResponse{
+			Signature:               signature,
+			ClientAuthCertificate:   id.deviceCertificate,
+			IntermediateCertificate: [][]byte{id.intermediateCertificate},
+			SenderNonce:             challenge.GetSenderNonce(),
+			HashAlgorithm:           challenge.GetHashAlgorithm().Enum(),
+		},
+	})
+}
+
+func (r *Receiver) writeDeviceAuth(conn net.Conn, request *pb.CastMessage, response *pb.DeviceAuthMessage) error {
+	payload, err := proto.Marshal(response)
+	if err != nil {
+		return err
+	}
+	sourceID, destinationID, namespace := request.GetDestinationId(), request.GetSourceId(), cast.NamespaceDeviceAuth
+	return writeFrame(conn, &pb.CastMessage{
+		ProtocolVersion: pb.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &sourceID,
+		DestinationId:   &destinationID,
+		Namespace:       &namespace,
+		PayloadType:     pb.CastMessage_BINARY.Enum(),
+		PayloadBinary:   payload,
+	})
+}
@@ -0,0 +1,759 @@
+// Package casttest provides a fake cast receiver for testing code that
+// talks to a chromecast, in the same spirit as net/http/httptest.
+//
+// The receiver speaks the CASTV2 protocol over TLS, answers the requests
+// go-chromecast makes with realistic RECEIVER_STATUS and MEDIA_STATUS
+// payloads, and lets tests script what the device does next, ie: finish
+// the playing media or fail the next LOAD. Authentication challenges are
+// answered with a device certificate issued by a test CA, see 'TrustStore'.
+package casttest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/vishen/go-chromecast/cast"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+const (
+	DefaultMediaReceiverAppID = "CC1AD845"
+
+	namespaceConn      = cast.NamespaceConnection
+	namespaceHeartbeat = cast.NamespaceHeartbeat
+	namespaceRecv      = cast.NamespaceReceiver
+	namespaceMedia     = cast.NamespaceMedia
+
+	platformID  = "receiver-0"
+	broadcastID = "*"
+)
+
+// knownApps are the display names the receiver will report for apps
+// launched on it.
+var knownApps = map[string]string{
+	DefaultMediaReceiverAppID: "Default Media Receiver",
+	"E8C28D3C":                "Backdrop",
+}
+
+// Receiver is a fake chromecast listening on a local address.
+type Receiver struct {
+	listener net.Listener
+	// DER of the TLS certificate, which is signed when authenticating.
+	certificate []byte
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+
+	// Scripted behaviour.
+	ignoreHeartbeats bool
+	failNextLoad     bool
+	impersonate      bool
+	repeatReplies    int
+	handlers         map[string]func(payload []byte) interface{}
+
+	// Every message received, in order.
+	messages []*pb.CastMessage
+
+	// Device state.
+	sessionCount   int
+	application    *cast.Application
+	volume         cast.Volume
+	mediaSessionID int
+	media          *cast.Media
+	queue          []queueItem
+	queueIndex     int
+	lastItemID     int
+	repeatMode     string
+}
+
+type queueItem struct {
+	itemID int
+	media  cast.MediaItem
+}
+
+// NewReceiver starts a fake receiver listening on a random port on the
+// loopback interface. It is idle with the volume at 1.0.
+func NewReceiver() (*Receiver, error) {
+	certificate, err := selfSignedCertificate()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := &Receiver{
+		listener:    listener,
+		certificate: certificate.Certificate[0],
+		conns:       map[net.Conn]bool{},
+		volume:      cast.Volume{Level: 1},
+	}
+	go r.acceptLoop()
+	return r, nil
+}
+
+// Addr is the address and port the receiver is listening on.
+func (r *Receiver) Addr() (string, int) {
+	addr := r.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// Close stops the receiver and drops all connections to it.
+func (r *Receiver) Close() error {
+	err := r.listener.Close()
+	r.DropConnections()
+	return err
+}
+
+// DropConnections closes every open connection to the receiver, as
+// happens when a device reboots or drops off the network. New connections
+// are still accepted.
+func (r *Receiver) DropConnections() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for conn := range r.conns {
+		conn.Close()
+		delete(r.conns, conn)
+	}
+}
+
+// IgnoreHeartbeats stops the receiver from answering PINGs, which makes
+// it look dead to a sender.
+func (r *Receiver) IgnoreHeartbeats(ignore bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ignoreHeartbeats = ignore
+}
+
+// RepeatReplies makes the receiver send every reply 'times' times, as
+// chromecasts sometimes answer a request more than once.
+func (r *Receiver) RepeatReplies(times int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repeatReplies = times
+}
+
+// FailNextLoad makes the next LOAD or QUEUE_LOAD be answered with
+// LOAD_FAILED.
+func (r *Receiver) FailNextLoad() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failNextLoad = true
+}
+
+// FinishMedia finishes the currently playing item. If there is another
+// item in the queue it starts playing, otherwise the receiver goes idle.
+// The new media status is broadcast to all senders.
+func (r *Receiver) FinishMedia() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.media == nil {
+		return
+	}
+	if r.repeatMode == cast.RepeatSingle {
+		r.playQueueItem(r.queueIndex)
+	} else if r.queueIndex+1 < len(r.queue) || r.repeatMode == cast.RepeatAll || r.repeatMode == cast.RepeatAllAndShuffle {
+		r.playQueueItem((r.queueIndex + 1) % len(r.queue))
+	} else {
+		r.media.PlayerState = "IDLE"
+		r.media.IdleReason = "FINISHED"
+	}
+	r.broadcastMediaStatus()
+}
+
+// SetCurrentTime moves the playback position of the current media.
+func (r *Receiver) SetCurrentTime(currentTime float32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.media != nil {
+		r.media.CurrentTime = currentTime
+	}
+}
+
+// HandleNamespace has the running app answer the messages sent to it on
+// 'namespace' with whatever 'handler' returns for their payload, nothing
+// is sent back when it returns nil. 'handler' must not call the receiver.
+func (r *Receiver) HandleNamespace(namespace string, handler func(payload []byte) interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = map[string]func(payload []byte) interface{}{}
+	}
+	r.handlers[namespace] = handler
+}
+
+// LaunchApp switches the receiver to another app, as if a different
+// sender had cast to it. The new receiver status is broadcast.
+func (r *Receiver) LaunchApp(appID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.launch(appID)
+	r.broadcast(platformID, namespaceRecv, r.receiverStatus(0))
+}
+
+// Application is the app currently running on the receiver, or nil when
+// the receiver is idle.
+func (r *Receiver) Application() *cast.Application {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.application == nil {
+		return nil
+	}
+	app := *r.application
+	return &app
+}
+
+// Media is the current media status, or nil when nothing has been loaded.
+func (r *Receiver) Media() *cast.Media {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.media == nil {
+		return nil
+	}
+	media := *r.media
+	return &media
+}
+
+// Volume is the current receiver volume.
+func (r *Receiver) Volume() cast.Volume {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.volume
+}
+
+// Messages returns every message the receiver has received so far.
+func (r *Receiver) Messages() []*pb.CastMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*pb.CastMessage(nil), r.messages...)
+}
+
+// MessagesOfType returns the received payloads with the given 'type'.
+func (r *Receiver) MessagesOfType(messageType string) []*pb.CastMessage {
+	var messages []*pb.CastMessage
+	for _, message := range r.Messages() {
+		var header cast.PayloadHeader
+		if json.Unmarshal([]byte(message.GetPayloadUtf8()), &header) == nil && header.Type == messageType {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+func (r *Receiver) acceptLoop() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.conns[conn] = true
+		r.mu.Unlock()
+		go r.serve(conn)
+	}
+}
+
+func (r *Receiver) serve(conn net.Conn) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		message, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.messages = append(r.messages, message)
+		r.handle(conn, message)
+		r.mu.Unlock()
+	}
+}
+
+// handle answers a single message, 'mu' must be held.
+func (r *Receiver) handle(conn net.Conn, message *pb.CastMessage) {
+	if message.GetNamespace() == cast.NamespaceDeviceAuth {
+		r.handleDeviceAuth(conn, message)
+		return
+	}
+	payload := []byte(message.GetPayloadUtf8())
+	var header cast.PayloadHeader
+	if err := json.Unmarshal(payload, &header); err != nil {
+		return
+	}
+	reply := func(response interface{}) {
+		for i := 0; i == 0 || i < r.repeatReplies; i++ {
+			writeMessage(conn, message.GetDestinationId(), message.GetSourceId(), message.GetNamespace(), response)
+		}
+	}
+
+	switch message.GetNamespace() {
+	case namespaceConn:
+		// CONNECT and CLOSE need no answer.
+	case namespaceHeartbeat:
+		if header.Type == "PING" && !r.ignoreHeartbeats {
+			reply(cast.PongHeader)
+		}
+	case namespaceRecv:
+		switch header.Type {
+		case "GET_STATUS":
+		case "LAUNCH":
+			var launch cast.LaunchRequest
+			json.Unmarshal(payload, &launch)
+			r.launch(launch.AppId)
+		case "STOP":
+			r.application = nil
+			r.media = nil
+			r.queue = nil
+		case "SET_VOLUME":
+			var fields struct {
+				Volume map[string]interface{} `json:"volume"`
+			}
+			json.Unmarshal(payload, &fields)
+			if level, ok := fields.Volume["level"].(float64); ok {
+				r.volume.Level = float32(level)
+			}
+			if muted, ok := fields.Volume["muted"].(bool); ok {
+				r.volume.Muted = muted
+			}
+		default:
+			reply(invalidRequest(header.RequestId, "INVALID_COMMAND"))
+			return
+		}
+		reply(r.receiverStatus(header.RequestId))
+	case namespaceMedia:
+		// Media messages are only routed to the running app.
+		if r.application == nil || message.GetDestinationId() != r.application.TransportId {
+			return
+		}
+		r.handleMedia(header, payload, reply)
+	default:
+		handler, ok := r.handlers[message.GetNamespace()]
+		if !ok || r.application == nil || message.GetDestinationId() != r.application.TransportId {
+			return
+		}
+		if response := handler(payload); response != nil {
+			reply(response)
+		}
+	}
+}
+
+// handleMedia answers a media namespace message, 'mu' must be held.
+func (r *Receiver) handleMedia(header cast.PayloadHeader, payload []byte, reply func(interface{})) {
+	switch header.Type {
+	case "GET_STATUS":
+	case "LOAD":
+		var load cast.LoadMediaCommand
+		json.Unmarshal(payload, &load)
+		if r.failNextLoad {
+			r.failNextLoad = false
+			reply(cast.PayloadHeader{Type: "LOAD_FAILED", RequestId: header.RequestId})
+			return
+		}
+		r.loadQueue([]cast.MediaItem{load.Media}, 0, cast.RepeatOff)
+		r.media.CurrentTime = float32(load.CurrentTime)
+		r.media.ActiveTrackIds = load.ActiveTrackIds
+	case "QUEUE_LOAD":
+		var queueLoad cast.QueueLoad
+		json.Unmarshal(payload, &queueLoad)
+		if r.failNextLoad || len(queueLoad.Items) == 0 {
+			r.failNextLoad = false
+			reply(cast.PayloadHeader{Type: "LOAD_FAILED", RequestId: header.RequestId})
+			return
+		}
+		items := make([]cast.MediaItem, len(queueLoad.Items))
+		for i, item := range queueLoad.Items {
+			items[i] = item.Media
+		}
+		r.loadQueue(items, queueLoad.StartIndex, queueLoad.RepeatMode)
+		r.media.CurrentTime = queueLoad.CurrentTime
+		r.media.ActiveTrackIds = queueLoad.Items[queueLoad.StartIndex].ActiveTrackIds
+	default:
+		var command struct {
+			cast.MediaHeader
+			Jump int `json:"jump"`
+		}
+		json.Unmarshal(payload, &command)
+		if r.media == nil || command.MediaSessionId != r.mediaSessionID {
+			reply(invalidRequest(header.RequestId, "INVALID_MEDIA_SESSION_ID"))
+			return
+		}
+		switch header.Type {
+		case "PLAY":
+			r.media.PlayerState = "PLAYING"
+		case "PAUSE":
+			r.media.PlayerState = "PAUSED"
+		case "STOP":
+			r.media.PlayerState = "IDLE"
+			r.media.IdleReason = "CANCELLED"
+		case "SEEK":
+			if command.RelativeTime != 0 {
+				r.media.CurrentTime += command.RelativeTime
+			} else {
+				r.media.CurrentTime = command.CurrentTime
+			}
+		case "SET_VOLUME":
+			var volume cast.SetVolume
+			json.Unmarshal(payload, &volume)
+			r.media.Volume = volume.Volume
+		case "SET_PLAYBACK_RATE":
+			var rate cast.SetPlaybackRate
+			json.Unmarshal(payload, &rate)
+			r.media.PlaybackRate = rate.PlaybackRate
+		case "EDIT_TRACKS_INFO":
+			var edit cast.EditTracksInfo
+			json.Unmarshal(payload, &edit)
+			r.media.ActiveTrackIds = edit.ActiveTrackIds
+			if edit.TextTrackStyle != nil {
+				r.media.Media.TextTrackStyle = edit.TextTrackStyle
+			}
+		case "QUEUE_UPDATE":
+			var update cast.QueueUpdate
+			json.Unmarshal(payload, &update)
+			if update.RepeatMode != "" {
+				r.repeatMode = update.RepeatMode
+				r.media.RepeatMode = update.RepeatMode
+			}
+			if update.Shuffle {
+				// The playing item keeps playing, wherever it ends up.
+				mathrand.Shuffle(len(r.queue), func(i, j int) {
+					r.queue[i], r.queue[j] = r.queue[j], r.queue[i]
+				})
+				r.queueIndex = indexOfItem(r.queue, r.media.CurrentItemId)
+			}
+			switch {
+			case update.CurrentItemId != 0:
+				index := indexOfItem(r.queue, update.CurrentItemId)
+				if index < 0 {
+					reply(invalidRequest(header.RequestId, "INVALID_PARAMS"))
+					return
+				}
+				r.playQueueItem(index)
+			case update.Jump != 0:
+				next := r.queueIndex + update.Jump
+				if next < 0 || next >= len(r.queue) {
+					// Jumping off either end of the queue ends playback.
+					r.stopQueue()
+				} else {
+					r.playQueueItem(next)
+				}
+			}
+		case "QUEUE_INSERT":
+			var insert cast.QueueInsert
+			json.Unmarshal(payload, &insert)
+			index := len(r.queue)
+			if insert.InsertBefore != 0 {
+				if index = indexOfItem(r.queue, insert.InsertBefore); index < 0 {
+					reply(invalidRequest(header.RequestId, "INVALID_PARAMS"))
+					return
+				}
+			}
+			inserted := make([]queueItem, len(insert.Items))
+			itemIDs := make([]int, len(insert.Items))
+			for i, item := range insert.Items {
+				inserted[i] = r.newQueueItem(item.Media)
+				itemIDs[i] = inserted[i].itemID
+			}
+			r.queue = append(r.queue[:index], append(inserted, r.queue[index:]...)...)
+			r.queueIndex = indexOfItem(r.queue, r.media.CurrentItemId)
+			r.broadcastQueueChange("INSERT", itemIDs, insert.InsertBefore)
+		case "QUEUE_REMOVE":
+			var remove cast.QueueRemove
+			json.Unmarshal(payload, &remove)
+			// Where the item after the playing one ends up.
+			played := r.queue
+			if r.queueIndex+1 < len(played) {
+				played = played[:r.queueIndex+1]
+			}
+			next := len(withoutQueueItems(played, remove.ItemIds))
+			r.queue = withoutQueueItems(r.queue, remove.ItemIds)
+			if index := indexOfItem(r.queue, r.media.CurrentItemId); index >= 0 {
+				r.queueIndex = index
+			} else if next < len(r.queue) {
+				// The playing item was removed, so the one after it plays.
+				r.playQueueItem(next)
+			} else {
+				r.stopQueue()
+			}
+			r.broadcastQueueChange("REMOVE", remove.ItemIds, 0)
+		case "QUEUE_REORDER":
+			var reorder cast.QueueReorder
+			json.Unmarshal(payload, &reorder)
+			var moved []queueItem
+			for _, itemID := range reorder.ItemIds {
+				index := indexOfItem(r.queue, itemID)
+				if index < 0 {
+					reply(invalidRequest(header.RequestId, "INVALID_PARAMS"))
+					return
+				}
+				moved = append(moved, r.queue[index])
+			}
+			queue := withoutQueueItems(r.queue, reorder.ItemIds)
+			index := len(queue)
+			if reorder.InsertBefore != 0 {
+				if index = indexOfItem(queue, reorder.InsertBefore); index < 0 {
+					reply(invalidRequest(header.RequestId, "INVALID_PARAMS"))
+					return
+				}
+			}
+			r.queue = append(queue[:index], append(moved, queue[index:]...)...)
+			r.queueIndex = indexOfItem(r.queue, r.media.CurrentItemId)
+			r.broadcastQueueChange("ITEMS_CHANGE", reorder.ItemIds, reorder.InsertBefore)
+		case "QUEUE_GET_ITEM_IDS":
+			itemIDs := make([]int, len(r.queue))
+			for i, item := range r.queue {
+				itemIDs[i] = item.itemID
+			}
+			reply(cast.QueueItemIdsResponse{
+				PayloadHeader: cast.PayloadHeader{Type: "QUEUE_ITEM_IDS", RequestId: header.RequestId},
+				ItemIds:       itemIDs,
+			})
+			return
+		case "QUEUE_GET_ITEMS":
+			var get cast.QueueGetItems
+			json.Unmarshal(payload, &get)
+			items := []cast.QueueLoadItem{}
+			for _, itemID := range get.ItemIds {
+				if index := indexOfItem(r.queue, itemID); index >= 0 {
+					items = append(items, cast.QueueLoadItem{ItemId: itemID, Media: r.queue[index].media, Autoplay: true})
+				}
+			}
+			reply(cast.QueueItemsResponse{
+				PayloadHeader: cast.PayloadHeader{Type: "QUEUE_ITEMS", RequestId: header.RequestId},
+				Items:         items,
+			})
+			return
+		default:
+			reply(invalidRequest(header.RequestId, "INVALID_COMMAND"))
+			return
+		}
+	}
+	reply(r.mediaStatus(header.RequestId))
+}
+
+// launch starts a new session for 'appID', 'mu' must be held.
+func (r *Receiver) launch(appID string) {
+	if r.application != nil && r.application.AppId == appID {
+		return
+	}
+	r.sessionCount++
+	displayName, ok := knownApps[appID]
+	if !ok {
+		displayName = appID
+	}
+	r.application = &cast.Application{
+		AppId:        appID,
+		DisplayName:  displayName,
+		IsIdleScreen: appID == "E8C28D3C",
+		SessionId:    fmt.Sprintf("session-%d", r.sessionCount),
+		StatusText:   displayName,
+		TransportId:  fmt.Sprintf("transport-%d", r.sessionCount),
+	}
+	r.media = nil
+	r.queue = nil
+}
+
+// loadQueue starts a new media session, 'mu' must be held.
+func (r *Receiver) loadQueue(items []cast.MediaItem, startIndex int, repeatMode string) {
+	r.mediaSessionID++
+	r.queue = make([]queueItem, len(items))
+	for i, item := range items {
+		r.queue[i] = r.newQueueItem(item)
+	}
+	r.repeatMode = repeatMode
+	r.media = &cast.Media{
+		MediaSessionId: r.mediaSessionID,
+		Volume:         cast.Volume{Level: 1},
+		RepeatMode:     repeatMode,
+		PlaybackRate:   1,
+	}
+	r.playQueueItem(startIndex)
+}
+
+// playQueueItem starts playing the item at 'index', 'mu' must be held.
+func (r *Receiver) playQueueItem(index int) {
+	r.queueIndex = index
+	r.media.PlayerState = "PLAYING"
+	r.media.IdleReason = ""
+	r.media.CurrentTime = 0
+	r.media.CurrentItemId = r.queue[index].itemID
+	r.media.Media = r.queue[index].media
+}
+
+// stopQueue ends playback, as happens at the end of the queue, 'mu' must
+// be held.
+func (r *Receiver) stopQueue() {
+	r.media.PlayerState = "IDLE"
+	r.media.IdleReason = "INTERRUPTED"
+	r.media.Media = cast.MediaItem{}
+}
+
+// newQueueItem gives 'media' the next item id, 'mu' must be held.
+func (r *Receiver) newQueueItem(media cast.MediaItem) queueItem {
+	r.lastItemID++
+	return queueItem{itemID: r.lastItemID, media: media}
+}
+
+// indexOfItem returns where the item 'itemID' is in 'queue', or -1.
+func indexOfItem(queue []queueItem, itemID int) int {
+	for i, item := range queue {
+		if item.itemID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// withoutQueueItems returns a copy of 'queue' without the items 'itemIDs'.
+func withoutQueueItems(queue []queueItem, itemIDs []int) []queueItem {
+	var kept []queueItem
+	for _, item := range queue {
+		removed := false
+		for _, itemID := range itemIDs {
+			removed = removed || item.itemID == itemID
+		}
+		if !removed {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// broadcastQueueChange tells every sender the queue changed, 'mu' must be
+// held.
+func (r *Receiver) broadcastQueueChange(changeType string, itemIDs []int, insertBefore int) {
+	r.broadcast(r.application.TransportId, namespaceMedia, cast.QueueChangeResponse{
+		PayloadHeader: cast.PayloadHeader{Type: "QUEUE_CHANGE"},
+		ChangeType:    changeType,
+		ItemIds:       itemIDs,
+		InsertBefore:  insertBefore,
+	})
+}
+
+func (r *Receiver) receiverStatus(requestID int) interface{} {
+	var status cast.ReceiverStatusResponse
+	status.Type = "RECEIVER_STATUS"
+	status.RequestId = requestID
+	status.Status.Volume = r.volume
+	status.Status.Applications = []cast.Application{}
+	if r.application != nil {
+		status.Status.Applications = append(status.Status.Applications, *r.application)
+	}
+	return status
+}
+
+func (r *Receiver) mediaStatus(requestID int) interface{} {
+	status := cast.MediaStatusResponse{
+		PayloadHeader: cast.PayloadHeader{Type: "MEDIA_STATUS", RequestId: requestID},
+		Status:        []cast.Media{},
+	}
+	if r.media != nil {
+		status.Status = append(status.Status, *r.media)
+	}
+	return status
+}
+
+func (r *Receiver) broadcastMediaStatus() {
+	if r.application == nil {
+		return
+	}
+	r.broadcast(r.application.TransportId, namespaceMedia, r.mediaStatus(0))
+}
+
+// broadcast sends an unsolicited message to every sender, 'mu' must be held.
+func (r *Receiver) broadcast(sourceID, namespace string, payload interface{}) {
+	for conn := range r.conns {
+		writeMessage(conn, sourceID, broadcastID, namespace, payload)
+	}
+}
+
+func invalidRequest(requestID int, reason string) interface{} {
+	return struct {
+		cast.PayloadHeader
+		Reason string `json:"reason"`
+	}{
+		PayloadHeader: cast.PayloadHeader{Type: "INVALID_REQUEST", RequestId: requestID},
+		Reason:        reason,
+	}
+}
+
+func readMessage(r io.Reader) (*pb.CastMessage, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	message := &pb.CastMessage{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func writeMessage(w io.Writer, sourceID, destinationID, namespace string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	payloadUtf8 := string(payloadJSON)
+	return writeFrame(w, &pb.CastMessage{
+		ProtocolVersion: pb.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &sourceID,
+		DestinationId:   &destinationID,
+		Namespace:       &namespace,
+		PayloadType:     pb.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &payloadUtf8,
+	})
+}
+
+func writeFrame(w io.Writer, message *pb.CastMessage) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = w.Write(frame)
+	return err
+}
+
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "casttest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -1,13 +1,16 @@
 package cast
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -22,75 +25,204 @@ import (
 const (
 	dialerTimeout   = time.Second * 3
 	dialerKeepAlive = time.Second * 30
+
+	// How often we PING the chromecast, and how long we will go without
+	// hearing anything from it before the connection is considered dead.
+	defaultHeartbeatInterval = time.Second * 5
+	defaultHeartbeatTimeout  = time.Second * 15
+
+	heartbeatSender   = "sender-0"
+	heartbeatReceiver = "receiver-0"
 )
 
 type Connection struct {
 	conn *tls.Conn
 
 	recvMsgChan chan *pb.CastMessage
+	// Errors that caused the connection to be dropped are sent on this
+	// channel, it is never sent to when the connection is closed by 'Close'.
+	errChan chan error
+
+	debug bool
+
+	mu           sync.Mutex
+	connected    bool
+	lastReceived time.Time
+	// Held while writing a message, so concurrent messages are not
+	// interleaved on the wire.
+	writeMu sync.Mutex
+
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
 
-	debug     bool
-	connected bool
+	recorder Recorder
+	// Called as the connection is dropped, with 'mu' held.
+	disconnectHook func(err error)
+	// Certificates trusted to issue device certificates, when set the
+	// chromecast has to authenticate itself before the connection is used.
+	trustStore *x509.CertPool
 
 	cancel context.CancelFunc
 }
 
-func NewConnection(recvMsgChan chan *pb.CastMessage) *Connection {
+func NewConnection(recvMsgChan chan *pb.CastMessage, errChan chan error) *Connection {
 	c := &Connection{
-		recvMsgChan: recvMsgChan,
-		connected:   false,
+		recvMsgChan:       recvMsgChan,
+		errChan:           errChan,
+		connected:         false,
+		heartbeatInterval: defaultHeartbeatInterval,
+		heartbeatTimeout:  defaultHeartbeatTimeout,
 	}
 	return c
 }
 
-func (c *Connection) Start(addr string, port int) error {
+// Start connects to the chromecast, 'ctx' only applies to establishing the
+// connection and not to how long the connection is kept.
+func (c *Connection) Start(ctx context.Context, addr string, port int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if !c.connected {
-		err := c.connect(addr, port)
+		err := c.connect(ctx, addr, port)
 		if err != nil {
 			return err
 		}
-		var ctx context.Context
-		// TODO: Recieve context through function params?
 		ctx, c.cancel = context.WithCancel(context.Background())
-		go c.receiveLoop(ctx)
+		c.lastReceived = time.Now()
+		go c.receiveLoop(ctx, c.conn)
+		go c.heartbeatLoop(ctx, c.heartbeatInterval, c.heartbeatTimeout)
 	}
 	return nil
 }
 
 func (c *Connection) Close() error {
-	// TODO: nothing here is concurrent safe, fix?
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.connected = false
 	if c.cancel != nil {
 		c.cancel()
 	}
+	if c.conn == nil {
+		return nil
+	}
 	return c.conn.Close()
 }
 
+// IsConnected reports whether the connection to the chromecast is
+// currently up.
+func (c *Connection) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
 func (c *Connection) SetDebug(debug bool) { c.debug = debug }
 
+// SetHeartbeat changes how often the chromecast is sent a PING, and how
+// long to wait without receiving anything before giving up on the connection.
+// It applies from the next time the connection is started.
+func (c *Connection) SetHeartbeat(interval, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.heartbeatInterval = interval
+	c.heartbeatTimeout = timeout
+}
+
+// SetDisconnectHook has 'hook' called as soon as the connection is dropped
+// because of 'err', before the connection reports itself as not connected
+// or sends the error on the error channel. It is called with the connection
+// locked, so it mustn't use the connection. It needs to be set before the
+// connection is started.
+func (c *Connection) SetDisconnectHook(hook func(err error)) {
+	c.disconnectHook = hook
+}
+
+// SetRecorder has every message sent and received passed to 'recorder',
+// it needs to be set before the connection is started.
+func (c *Connection) SetRecorder(recorder Recorder) {
+	c.recorder = recorder
+}
+
+// SetTrustStore has the chromecast prove it is a genuine cast device,
+// issued a device certificate by one of 'roots', every time it is
+// connected to. A nil pool turns authentication off, which is the default.
+// It needs to be set before the connection is started.
+func (c *Connection) SetTrustStore(roots *x509.CertPool) {
+	c.trustStore = roots
+}
+
 func (c *Connection) LocalAddr() (addr string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return "", ErrConnectionClosed
+	}
 	host, _, err := net.SplitHostPort(c.conn.LocalAddr().String())
 	return host, err
 }
 
+// RemoteAddr returns the address of the chromecast that is connected to.
+func (c *Connection) RemoteAddr() (addr string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return "", ErrConnectionClosed
+	}
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	return host, err
+}
+
 func (c *Connection) log(message string, args ...interface{}) {
 	if c.debug {
 		log.WithField("package", "cast").Debugf(message, args...)
 	}
 }
 
-func (c *Connection) connect(addr string, port int) error {
-	var err error
+func (c *Connection) connect(ctx context.Context, addr string, port int) error {
 	dialer := &net.Dialer{
 		Timeout:   dialerTimeout,
 		KeepAlive: dialerKeepAlive,
 	}
-	c.conn, err = tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("%s:%d", addr, port), &tls.Config{
+	rawConn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", addr, port))
+	if err != nil {
+		return errors.Wrapf(err, "unable to connect to chromecast at '%s:%d'", addr, port)
+	}
+
+	// The TLS handshake and device authentication can't take a context, so
+	// abort them by closing the connection if 'ctx' is done first.
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			rawConn.Close()
+		case <-handshakeDone:
+		}
+	}()
+
+	conn := tls.Client(rawConn, &tls.Config{
 		InsecureSkipVerify: true,
 	})
-	if err != nil {
+	if err := conn.Handshake(); err != nil {
+		rawConn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return errors.Wrapf(err, "unable to connect to chromecast at '%s:%d'", addr, port)
+	}
+	if c.trustStore != nil {
+		if err := c.authenticate(conn); err != nil {
+			rawConn.Close()
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return errors.Wrapf(err, "unable to connect to chromecast at '%s:%d'", addr, port)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		rawConn.Close()
 		return errors.Wrapf(err, "unable to connect to chromecast at '%s:%d'", addr, port)
 	}
+	c.conn = conn
 	c.connected = true
 	return nil
 }
@@ -110,25 +242,105 @@ func (c *Connection) Send(requestID int, payload Payload, sourceID, destinationI
 		PayloadType:     pb.CastMessage_STRING.Enum(),
 		PayloadUtf8:     &payloadUtf8,
 	}
-	proto.SetDefaults(message)
-	data, err := proto.Marshal(message)
+	data, err := encodeMessage(message)
 	if err != nil {
-		return errors.Wrap(err, "unable to marshal proto payload")
+		return err
 	}
 
 	c.log("(%d)%s -> %s [%s]: %s", requestID, sourceID, destinationID, namespace, payloadJson)
 
-	if err := binary.Write(c.conn, binary.BigEndian, uint32(len(data))); err != nil {
-		return errors.Wrap(err, "unable to write binary format")
+	c.mu.Lock()
+	conn, connected, timeout := c.conn, c.connected, c.heartbeatTimeout
+	c.mu.Unlock()
+	if !connected {
+		return ErrConnectionClosed
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	// A chromecast that stops reading would otherwise block every sender,
+	// the heartbeat included, for good.
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(data); err != nil {
+		// Part of the message may have gone out, nothing after it would
+		// make sense to the chromecast.
+		err = errors.Wrap(err, "unable to send data")
+		c.disconnect(err)
+		return err
 	}
-	if _, err := c.conn.Write(data); err != nil {
-		return errors.Wrap(err, "unable to send data")
+	if c.recorder != nil {
+		c.recorder.Record(DirectionSent, message)
 	}
 
 	return nil
 }
 
-func (c *Connection) receiveLoop(ctx context.Context) {
+// encodeMessage frames 'message' as it is sent on the wire. The length
+// prefix and the message need to go out in a single write, otherwise
+// concurrent senders could interleave them.
+func encodeMessage(message *pb.CastMessage) ([]byte, error) {
+	proto.SetDefaults(message)
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal proto payload")
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, uint32(len(data))); err != nil {
+		return nil, errors.Wrap(err, "unable to write binary format")
+	}
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// disconnect drops the connection because of 'err' and lets whoever is
+// listening on the error channel know about it.
+func (c *Connection) disconnect(err error) {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return
+	}
+	if c.disconnectHook != nil {
+		c.disconnectHook(err)
+	}
+	c.connected = false
+	c.cancel()
+	c.conn.Close()
+	c.mu.Unlock()
+
+	c.log("connection dropped: %v", err)
+	select {
+	case c.errChan <- err:
+	default:
+		c.log("error channel is full, dropping error: %v", err)
+	}
+}
+
+func (c *Connection) heartbeatLoop(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		lastReceived := c.lastReceived
+		c.mu.Unlock()
+		if time.Since(lastReceived) > timeout {
+			c.disconnect(ErrHeartbeatTimeout)
+			return
+		}
+		if err := c.Send(-1, &PingHeader, heartbeatSender, heartbeatReceiver, NamespaceHeartbeat); err != nil {
+			c.disconnect(errors.Wrap(err, "unable to send heartbeat"))
+			return
+		}
+	}
+}
+
+func (c *Connection) receiveLoop(ctx context.Context, conn *tls.Conn) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -137,12 +349,13 @@ func (c *Connection) receiveLoop(ctx context.Context) {
 			// Fallthrough if not done
 		}
 		var length uint32
-		if c.conn == nil {
-			continue
-		}
-		if err := binary.Read(c.conn, binary.BigEndian, &length); err != nil {
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
 			c.log("failed to binary read payload: %v", err)
-			break
+			// A read error after we have been closed is expected.
+			if ctx.Err() == nil {
+				c.disconnect(errors.Wrap(err, "unable to read from chromecast"))
+			}
+			return
 		}
 		if length == 0 {
 			c.log("empty payload received")
@@ -150,12 +363,22 @@ func (c *Connection) receiveLoop(ctx context.Context) {
 		}
 
 		payload := make([]byte, length)
-		i, err := io.ReadFull(c.conn, payload)
+		i, err := io.ReadFull(conn, payload)
 		if err != nil {
 			c.log("failed to read payload: %v", err)
-			continue
+			// We have lost our place in the stream, so there is no
+			// recovering from this.
+			if ctx.Err() == nil {
+				c.disconnect(errors.Wrap(err, "unable to read from chromecast"))
+			}
+			return
 		}
 
+		// Anything from the chromecast means it is still alive.
+		c.mu.Lock()
+		c.lastReceived = time.Now()
+		c.mu.Unlock()
+
 		if i != int(length) {
 			c.log("invalid payload, wanted: %d but read: %d", length, i)
 			continue
@@ -166,8 +389,15 @@ func (c *Connection) receiveLoop(ctx context.Context) {
 			c.log("failed to unmarshal proto cast message '%s': %v", payload, err)
 			continue
 		}
+		if c.recorder != nil {
+			c.recorder.Record(DirectionReceived, message)
+		}
 		// Get the requestID from the message to use in the log. We don't really
 		// care if this fails.
+		if message.GetPayloadType() != pb.CastMessage_STRING {
+			c.log("ignoring binary message on %s", message.GetNamespace())
+			continue
+		}
 		requestID, _ := jsonparser.GetInt([]byte(*message.PayloadUtf8), "requestId")
 		if requestID == 0 {
 			requestID = -1
@@ -197,6 +427,8 @@ func (c *Connection) handleMessage(requestID int, message *pb.CastMessage, heade
 	}
 
 	switch messageType {
+	case "PONG":
+		// Nothing to do, receiving anything already counts as a heartbeat.
 	case "PING":
 		if err := c.Send(-1, &PongHeader, *message.SourceId, *message.DestinationId, *message.Namespace); err != nil {
 			c.log("unable to respond to 'PING': %v", err)
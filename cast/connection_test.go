@@ -0,0 +1,335 @@
+package cast
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-chromecast test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTestListener starts a TLS listener and hands the first accepted
+// connection to 'handle'.
+func startTestListener(t *testing.T, handle func(net.Conn)) (string, int) {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+	})
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		handle(conn)
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func readTestMessage(r io.Reader) (*pb.CastMessage, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	message := &pb.CastMessage{}
+	return message, proto.Unmarshal(data, message)
+}
+
+func TestConnectionConcurrentSend(t *testing.T) {
+	const senders, messagesPerSender = 20, 50
+
+	received := make(chan map[int]bool, 1)
+	addr, port := startTestListener(t, func(conn net.Conn) {
+		defer conn.Close()
+		seen := map[int]bool{}
+		for len(seen) < senders*messagesPerSender {
+			message, err := readTestMessage(conn)
+			if err != nil {
+				t.Errorf("unable to read message: %v", err)
+				break
+			}
+			if message.GetNamespace() == NamespaceHeartbeat {
+				continue
+			}
+			var header PayloadHeader
+			if err := json.Unmarshal([]byte(message.GetPayloadUtf8()), &header); err != nil {
+				t.Errorf("unable to unmarshal payload %q: %v", message.GetPayloadUtf8(), err)
+				break
+			}
+			seen[header.RequestId] = true
+		}
+		received <- seen
+	})
+
+	c := NewConnection(make(chan *pb.CastMessage, 1), make(chan error, 1))
+	if err := c.Start(context.Background(), addr, port); err != nil {
+		t.Fatalf("unable to start connection: %v", err)
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < messagesPerSender; j++ {
+				payload := &PayloadHeader{Type: "GET_STATUS", RequestId: i*messagesPerSender + j + 1}
+				if err := c.Send(payload.RequestId, payload, "sender-0", "receiver-0", "urn:x-cast:com.google.cast.receiver"); err != nil {
+					t.Errorf("unable to send: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	select {
+	case seen := <-received:
+		if len(seen) != senders*messagesPerSender {
+			t.Fatalf("received %d distinct messages, expected %d", len(seen), senders*messagesPerSender)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("timed out waiting for messages")
+	}
+}
+
+func TestConnectionHeartbeatTimeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	addr, port := startTestListener(t, func(conn net.Conn) {
+		// Read everything, but never answer the PINGs.
+		defer conn.Close()
+		go io.Copy(ioutil.Discard, conn)
+		<-done
+	})
+
+	errChan := make(chan error, 1)
+	c := NewConnection(make(chan *pb.CastMessage, 1), errChan)
+	c.SetHeartbeat(time.Millisecond*20, time.Millisecond*100)
+	if err := c.Start(context.Background(), addr, port); err != nil {
+		t.Fatalf("unable to start connection: %v", err)
+	}
+	defer c.Close()
+
+	select {
+	case err := <-errChan:
+		if err != ErrHeartbeatTimeout {
+			t.Fatalf("got error %v, expected %v", err, ErrHeartbeatTimeout)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("connection was never considered dead")
+	}
+	if c.IsConnected() {
+		t.Fatal("connection still reports being connected")
+	}
+}
+
+func TestConnectionPeerStopsReading(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	addr, port := startTestListener(t, func(conn net.Conn) {
+		// Complete the handshake, then never read anything.
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+		<-done
+	})
+
+	errChan := make(chan error, 1)
+	c := NewConnection(make(chan *pb.CastMessage, 1), errChan)
+	c.SetHeartbeat(time.Millisecond*20, time.Millisecond*100)
+	if err := c.Start(context.Background(), addr, port); err != nil {
+		t.Fatalf("unable to start connection: %v", err)
+	}
+	defer c.Close()
+
+	// Fill up what the network will buffer, so writes block.
+	payload := &PayloadHeader{Type: string(make([]byte, 1<<20))}
+	go func() {
+		for c.Send(1, payload, "sender-0", "receiver-0", "urn:x-cast:com.google.cast.receiver") == nil {
+		}
+	}()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connection was never considered dead")
+	}
+	if c.IsConnected() {
+		t.Fatal("connection still reports being connected")
+	}
+}
+
+// writeTestMessage writes a message to the sender as the chromecast would.
+func writeTestMessage(w io.Writer, namespace, payload string) error {
+	sourceID, destinationID := "receiver-0", "sender-0"
+	data, err := proto.Marshal(&pb.CastMessage{
+		ProtocolVersion: pb.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &sourceID,
+		DestinationId:   &destinationID,
+		Namespace:       &namespace,
+		PayloadType:     pb.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &payload,
+	})
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func TestConnectionHeartbeat(t *testing.T) {
+	const heartbeat = "urn:x-cast:com.google.cast.tp.heartbeat"
+	pings, pongs := make(chan struct{}, 100), make(chan struct{}, 1)
+	addr, port := startTestListener(t, func(conn net.Conn) {
+		defer conn.Close()
+		// The chromecast PINGs the sender too, and expects a PONG.
+		if err := writeTestMessage(conn, heartbeat, `{"type":"PING"}`); err != nil {
+			return
+		}
+		for {
+			message, err := readTestMessage(conn)
+			if err != nil {
+				return
+			}
+			var header PayloadHeader
+			if err := json.Unmarshal([]byte(message.GetPayloadUtf8()), &header); err != nil {
+				continue
+			}
+			switch header.Type {
+			case "PING":
+				select {
+				case pings <- struct{}{}:
+				default:
+				}
+				if err := writeTestMessage(conn, heartbeat, `{"type":"PONG"}`); err != nil {
+					return
+				}
+			case "PONG":
+				select {
+				case pongs <- struct{}{}:
+				default:
+				}
+			}
+		}
+	})
+
+	errChan := make(chan error, 1)
+	c := NewConnection(make(chan *pb.CastMessage, 1), errChan)
+	c.SetHeartbeat(time.Millisecond*20, time.Millisecond*100)
+	if err := c.Start(context.Background(), addr, port); err != nil {
+		t.Fatalf("unable to start connection: %v", err)
+	}
+	defer c.Close()
+
+	select {
+	case <-pongs:
+	case <-time.After(time.Second * 5):
+		t.Fatal("PING from the chromecast was never answered")
+	}
+	// Answered PINGs keep the connection up well past the timeout.
+	for i := 0; i < 10; i++ {
+		select {
+		case <-pings:
+		case err := <-errChan:
+			t.Fatalf("connection dropped while the chromecast was answering: %v", err)
+		case <-time.After(time.Second * 5):
+			t.Fatal("no PING was sent to the chromecast")
+		}
+	}
+	if !c.IsConnected() {
+		t.Fatal("connection reports not being connected")
+	}
+}
+
+func TestConnectionDropped(t *testing.T) {
+	addr, port := startTestListener(t, func(conn net.Conn) {
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	})
+
+	errChan := make(chan error, 1)
+	c := NewConnection(make(chan *pb.CastMessage, 1), errChan)
+	if err := c.Start(context.Background(), addr, port); err != nil {
+		t.Fatalf("unable to start connection: %v", err)
+	}
+	defer c.Close()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		t.Fatal("dropped connection was never reported")
+	}
+	if c.IsConnected() {
+		t.Fatal("connection still reports being connected")
+	}
+}
+
+func TestConnectionStartCanceled(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	addr, port := startTestListener(t, func(conn net.Conn) {
+		// Accept the connection, but never complete the handshake.
+		defer conn.Close()
+		<-done
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	c := NewConnection(make(chan *pb.CastMessage, 1), make(chan error, 1))
+	start := time.Now()
+	if err := c.Start(ctx, addr, port); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("start took %s to give up", elapsed)
+	}
+	if c.IsConnected() {
+		t.Fatal("connection reports being connected")
+	}
+}
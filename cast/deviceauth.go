@@ -0,0 +1,185 @@
+package cast
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+// How long the chromecast has to answer the authentication challenge.
+const deviceAuthTimeout = time.Second * 5
+
+// How many random bytes the chromecast is challenged to sign.
+const deviceAuthNonceSize = 16
+
+// LoadTrustStore reads the PEM encoded certificates in 'filename', to be
+// used with 'SetTrustStore'. For real devices this needs to hold the Cast
+// root certificates, the intermediates are sent by the device.
+func LoadTrustStore(filename string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read trust store")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("no certificates found in trust store %q", filename)
+	}
+	return roots, nil
+}
+
+// authenticate challenges the chromecast to sign the certificate of the
+// TLS connection with its device certificate, and checks the device
+// certificate was issued by the trust store. Anyone can present a TLS
+// certificate, but only a genuine cast device has a device certificate.
+func (c *Connection) authenticate(conn *tls.Conn) error {
+	nonce := make([]byte, deviceAuthNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "unable to create device auth nonce")
+	}
+	challenge, err := proto.Marshal(&pb.DeviceAuthMessage{Challenge: &pb.AuthChallenge{
+		SenderNonce:   nonce,
+		HashAlgorithm: pb.HashAlgorithm_SHA256.Enum(),
+	}})
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal device auth challenge")
+	}
+	sourceID, destinationID, namespace := heartbeatSender, heartbeatReceiver, NamespaceDeviceAuth
+	data, err := encodeMessage(&pb.CastMessage{
+		ProtocolVersion: pb.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &sourceID,
+		DestinationId:   &destinationID,
+		Namespace:       &namespace,
+		PayloadType:     pb.CastMessage_BINARY.Enum(),
+		PayloadBinary:   challenge,
+	})
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(deviceAuthTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	c.log("%s -> %s [%s]: device auth challenge", sourceID, destinationID, namespace)
+	if _, err := conn.Write(data); err != nil {
+		return errors.Wrap(err, "unable to send device auth challenge")
+	}
+
+	// The chromecast doesn't send anything else until it has been sent a
+	// CONNECT, so the next message is the answer.
+	var length uint32
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return errors.Wrap(err, "unable to read device auth response")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return errors.Wrap(err, "unable to read device auth response")
+	}
+	message := &pb.CastMessage{}
+	if err := proto.Unmarshal(payload, message); err != nil {
+		return errors.Wrap(err, "unable to unmarshal device auth response")
+	}
+	if message.GetNamespace() != NamespaceDeviceAuth {
+		return errors.WithMessagef(ErrDeviceNotAuthenticated, "unexpected message on %s", message.GetNamespace())
+	}
+	var response pb.DeviceAuthMessage
+	if err := proto.Unmarshal(message.GetPayloadBinary(), &response); err != nil {
+		return errors.Wrap(err, "unable to unmarshal device auth response")
+	}
+	if authErr := response.GetError(); authErr != nil {
+		return errors.WithMessagef(ErrDeviceNotAuthenticated, "chromecast returned %s", authErr.GetErrorType())
+	}
+	if response.GetResponse() == nil {
+		return errors.WithMessage(ErrDeviceNotAuthenticated, "chromecast didn't answer the challenge")
+	}
+
+	peerCertificates := conn.ConnectionState().PeerCertificates
+	if len(peerCertificates) == 0 {
+		return errors.WithMessage(ErrDeviceNotAuthenticated, "chromecast has no TLS certificate")
+	}
+	if err := verifyDeviceAuth(response.GetResponse(), peerCertificates[0], c.trustStore, nonce); err != nil {
+		return errors.WithMessage(ErrDeviceNotAuthenticated, err.Error())
+	}
+	c.log("%s <- %s [%s]: device authenticated", sourceID, destinationID, namespace)
+	return nil
+}
+
+// verifyDeviceAuth checks that 'response' is a signature of 'nonce' and the
+// TLS certificate 'peer', made with a device certificate issued by 'roots'.
+//
+// Devices that don't know about nonces only sign 'peer'. Their signature
+// can be replayed, but only by someone who also has the private key of
+// 'peer', as the TLS handshake already proved the chromecast holds it.
+func verifyDeviceAuth(response *pb.AuthResponse, peer *x509.Certificate, roots *x509.CertPool, nonce []byte) error {
+	deviceCertificate, err := x509.ParseCertificate(response.GetClientAuthCertificate())
+	if err != nil {
+		return errors.Wrap(err, "unable to parse device certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, der := range response.GetIntermediateCertificate() {
+		intermediate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return errors.Wrap(err, "unable to parse intermediate certificate")
+		}
+		intermediates.AddCert(intermediate)
+	}
+	// Device certificates aren't issued for TLS, so they don't have the
+	// server auth extended key usage that is checked by default. What they
+	// are used for is checked by their key usage below instead.
+	if _, err := deviceCertificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.Wrap(err, "device certificate isn't trusted")
+	}
+	if deviceCertificate.KeyUsage != 0 && deviceCertificate.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return errors.New("device certificate can't be used for signatures")
+	}
+	publicKey, ok := deviceCertificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("device certificate doesn't have an RSA key")
+	}
+
+	signed := peer.Raw
+	if senderNonce := response.GetSenderNonce(); len(senderNonce) > 0 {
+		if !bytes.Equal(senderNonce, nonce) {
+			return errors.New("device signed a different nonce")
+		}
+		signed = append(append([]byte{}, senderNonce...), peer.Raw...)
+	}
+
+	// Devices that don't say otherwise sign with SHA-1.
+	hash := crypto.SHA1
+	var hashed []byte
+	switch response.GetHashAlgorithm() {
+	case pb.HashAlgorithm_SHA256:
+		sum := sha256.Sum256(signed)
+		hash, hashed = crypto.SHA256, sum[:]
+	default:
+		sum := sha1.Sum(signed)
+		hashed = sum[:]
+	}
+	if response.GetSignatureAlgorithm() == pb.SignatureAlgorithm_RSASSA_PSS {
+		err = rsa.VerifyPSS(publicKey, hash, hashed, response.GetSignature(), nil)
+	} else {
+		err = rsa.VerifyPKCS1v15(publicKey, hash, hashed, response.GetSignature())
+	}
+	if err != nil {
+		return errors.New("device signature doesn't match the TLS certificate")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package cast_test
+
+import (
+	"context"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+
+	"github.com/vishen/go-chromecast/cast"
+	"github.com/vishen/go-chromecast/cast/casttest"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+func startAuthenticated(t *testing.T, receiver *casttest.Receiver, roots *x509.CertPool) error {
+	t.Helper()
+	conn := cast.NewConnection(make(chan *pb.CastMessage, 10), make(chan error, 1))
+	conn.SetTrustStore(roots)
+	t.Cleanup(func() { conn.Close() })
+	addr, port := receiver.Addr()
+	return conn.Start(context.Background(), addr, port)
+}
+
+func TestConnectionDeviceAuth(t *testing.T) {
+	receiver, err := casttest.NewReceiver()
+	if err != nil {
+		t.Fatalf("unable to start receiver: %v", err)
+	}
+	defer receiver.Close()
+
+	if err := startAuthenticated(t, receiver, casttest.TrustStore()); err != nil {
+		t.Fatalf("unable to authenticate a trusted device: %v", err)
+	}
+	if len(receiver.Messages()) != 1 || receiver.Messages()[0].GetNamespace() != cast.NamespaceDeviceAuth {
+		t.Fatalf("expected only the challenge to be sent, got %v", receiver.Messages())
+	}
+	var challenge pb.DeviceAuthMessage
+	if err := proto.Unmarshal(receiver.Messages()[0].GetPayloadBinary(), &challenge); err != nil {
+		t.Fatalf("unable to unmarshal challenge: %v", err)
+	}
+	if len(challenge.GetChallenge().GetSenderNonce()) == 0 || challenge.GetChallenge().GetHashAlgorithm() != pb.HashAlgorithm_SHA256 {
+		t.Fatalf("expected a nonce signed with SHA-256 to be asked for, got %v", challenge.GetChallenge())
+	}
+
+	// Issued by a CA that isn't trusted.
+	err = startAuthenticated(t, receiver, x509.NewCertPool())
+	if errors.Cause(err) != cast.ErrDeviceNotAuthenticated {
+		t.Fatalf("got %v, expected %v", err, cast.ErrDeviceNotAuthenticated)
+	}
+
+	// A genuine device certificate, but the signature wasn't made for this
+	// connection.
+	receiver.ImpersonateDevice()
+	err = startAuthenticated(t, receiver, casttest.TrustStore())
+	if errors.Cause(err) != cast.ErrDeviceNotAuthenticated {
+		t.Fatalf("got %v, expected %v", err, cast.ErrDeviceNotAuthenticated)
+	}
+}
+
+func TestLoadTrustStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-chromecast-test-")
+	if err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename := filepath.Join(dir, "roots.pem")
+	if err := ioutil.WriteFile(filename, casttest.TrustStorePEM(), 0600); err != nil {
+		t.Fatalf("unable to write trust store: %v", err)
+	}
+	if _, err := cast.LoadTrustStore(filename); err != nil {
+		t.Fatalf("unable to load trust store: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("unable to write trust store: %v", err)
+	}
+	if _, err := cast.LoadTrustStore(filename); err == nil {
+		t.Fatal("expected an error loading a trust store without certificates")
+	}
+}
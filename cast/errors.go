@@ -0,0 +1,9 @@
+package cast
+
+import "github.com/pkg/errors"
+
+var (
+	ErrConnectionClosed       = errors.New("connection to the chromecast is closed")
+	ErrDeviceNotAuthenticated = errors.New("chromecast could not be authenticated as a genuine cast device")
+	ErrHeartbeatTimeout       = errors.New("no heartbeat received from the chromecast")
+)
@@ -2,19 +2,27 @@ package cast
 
 var (
 	// Known Payload headers
-	ConnectHeader     = PayloadHeader{Type: "CONNECT"}
-	CloseHeader       = PayloadHeader{Type: "CLOSE"}
-	GetStatusHeader   = PayloadHeader{Type: "GET_STATUS"}
-	PongHeader        = PayloadHeader{Type: "PONG"}         // Response to PING payload
-	LaunchHeader      = PayloadHeader{Type: "LAUNCH"}       // Launches a new chromecast app
-	StopHeader        = PayloadHeader{Type: "STOP"}         // Stop playing current media
-	PlayHeader        = PayloadHeader{Type: "PLAY"}         // Plays / unpauses the running app
-	PauseHeader       = PayloadHeader{Type: "PAUSE"}        // Pauses the running app
-	SeekHeader        = PayloadHeader{Type: "SEEK"}         // Seek into the running app
-	VolumeHeader      = PayloadHeader{Type: "SET_VOLUME"}   // Sets the volume
-	LoadHeader        = PayloadHeader{Type: "LOAD"}         // Loads an application onto the chromecast
-	QueueLoadHeader   = PayloadHeader{Type: "QUEUE_LOAD"}   // Loads an application onto the chromecast
-	QueueUpdateHeader = PayloadHeader{Type: "QUEUE_UPDATE"} // Loads an application onto the chromecast
+	ConnectHeader         = PayloadHeader{Type: "CONNECT"}
+	CloseHeader           = PayloadHeader{Type: "CLOSE"}
+	GetStatusHeader       = PayloadHeader{Type: "GET_STATUS"}
+	PingHeader            = PayloadHeader{Type: "PING"}               // Heartbeat sent to the chromecast
+	PongHeader            = PayloadHeader{Type: "PONG"}               // Response to PING payload
+	LaunchHeader          = PayloadHeader{Type: "LAUNCH"}             // Launches a new chromecast app
+	StopHeader            = PayloadHeader{Type: "STOP"}               // Stop playing current media
+	PlayHeader            = PayloadHeader{Type: "PLAY"}               // Plays / unpauses the running app
+	PauseHeader           = PayloadHeader{Type: "PAUSE"}              // Pauses the running app
+	SeekHeader            = PayloadHeader{Type: "SEEK"}               // Seek into the running app
+	VolumeHeader          = PayloadHeader{Type: "SET_VOLUME"}         // Sets the volume
+	LoadHeader            = PayloadHeader{Type: "LOAD"}               // Loads an application onto the chromecast
+	QueueLoadHeader       = PayloadHeader{Type: "QUEUE_LOAD"}         // Loads an application onto the chromecast
+	QueueUpdateHeader     = PayloadHeader{Type: "QUEUE_UPDATE"}       // Loads an application onto the chromecast
+	EditTracksHeader      = PayloadHeader{Type: "EDIT_TRACKS_INFO"}   // Changes the active tracks and their style
+	QueueInsertHeader     = PayloadHeader{Type: "QUEUE_INSERT"}       // Adds items to the queue
+	QueueRemoveHeader     = PayloadHeader{Type: "QUEUE_REMOVE"}       // Removes items from the queue
+	QueueReorderHeader    = PayloadHeader{Type: "QUEUE_REORDER"}      // Moves items within the queue
+	QueueGetItemIdsHeader = PayloadHeader{Type: "QUEUE_GET_ITEM_IDS"} // Gets the ids of the items in the queue
+	QueueGetItemsHeader   = PayloadHeader{Type: "QUEUE_GET_ITEMS"}    // Gets the items in the queue
+	PlaybackRateHeader    = PayloadHeader{Type: "SET_PLAYBACK_RATE"}  // Changes how fast the media plays
 )
 
 type Payload interface {
@@ -30,10 +38,89 @@ func (p *PayloadHeader) SetRequestId(id int) {
 	p.RequestId = id
 }
 
+// CustomPayload is an arbitrary JSON object, used for the messages of
+// receiver apps that go-chromecast knows nothing about.
+type CustomPayload map[string]interface{}
+
+func (p CustomPayload) SetRequestId(id int) {
+	p["requestId"] = id
+}
+
+// Repeat modes of the queue.
+const (
+	RepeatOff           = "REPEAT_OFF"
+	RepeatAll           = "REPEAT_ALL"
+	RepeatSingle        = "REPEAT_SINGLE"
+	RepeatAllAndShuffle = "REPEAT_ALL_AND_SHUFFLE"
+)
+
+// QueueUpdate changes the playing item, either by 'Jump'ing forwards or
+// backwards from it or to the item 'CurrentItemId', the repeat mode, and
+// can shuffle the queue.
 type QueueUpdate struct {
 	PayloadHeader
-	MediaSessionId int `json:"mediaSessionId,omitempty"`
-	Jump           int `json:"jump,omitempty"`
+	MediaSessionId int    `json:"mediaSessionId,omitempty"`
+	Jump           int    `json:"jump,omitempty"`
+	CurrentItemId  int    `json:"currentItemId,omitempty"`
+	RepeatMode     string `json:"repeatMode,omitempty"`
+	Shuffle        bool   `json:"shuffle,omitempty"`
+}
+
+// QueueInsert adds 'Items' before the item 'InsertBefore', or at the end of
+// the queue when it is 0.
+type QueueInsert struct {
+	PayloadHeader
+	MediaSessionId int             `json:"mediaSessionId"`
+	InsertBefore   int             `json:"insertBefore,omitempty"`
+	Items          []QueueLoadItem `json:"items"`
+}
+
+type QueueRemove struct {
+	PayloadHeader
+	MediaSessionId int   `json:"mediaSessionId"`
+	ItemIds        []int `json:"itemIds"`
+}
+
+// QueueReorder moves 'ItemIds', in that order, before the item
+// 'InsertBefore', or to the end of the queue when it is 0.
+type QueueReorder struct {
+	PayloadHeader
+	MediaSessionId int   `json:"mediaSessionId"`
+	ItemIds        []int `json:"itemIds"`
+	InsertBefore   int   `json:"insertBefore,omitempty"`
+}
+
+type QueueGetItemIds struct {
+	PayloadHeader
+	MediaSessionId int `json:"mediaSessionId"`
+}
+
+type QueueGetItems struct {
+	PayloadHeader
+	MediaSessionId int   `json:"mediaSessionId"`
+	ItemIds        []int `json:"itemIds"`
+}
+
+// QueueItemIdsResponse is the answer to QUEUE_GET_ITEM_IDS, the ids of
+// every item in the queue, in order.
+type QueueItemIdsResponse struct {
+	PayloadHeader
+	ItemIds []int `json:"itemIds"`
+}
+
+// QueueItemsResponse is the answer to QUEUE_GET_ITEMS.
+type QueueItemsResponse struct {
+	PayloadHeader
+	Items []QueueLoadItem `json:"items"`
+}
+
+// QueueChangeResponse is sent when items are inserted into, removed from
+// or moved within the queue.
+type QueueChangeResponse struct {
+	PayloadHeader
+	ChangeType   string `json:"changeType"` // INSERT, REMOVE, ITEMS_CHANGE, UPDATE or NO_CHANGE
+	ItemIds      []int  `json:"itemIds"`
+	InsertBefore int    `json:"insertBefore,omitempty"`
 }
 
 type QueueLoad struct {
@@ -46,9 +133,12 @@ type QueueLoad struct {
 }
 
 type QueueLoadItem struct {
+	// ItemId is given to the item by the chromecast when it is queued.
+	ItemId           int       `json:"itemId,omitempty"`
 	Media            MediaItem `json:"media"`
 	Autoplay         bool      `json:"autoplay"`
 	PlaybackDuration int       `json:"playbackDuration"`
+	ActiveTrackIds   []int     `json:"activeTrackIds,omitempty"`
 }
 
 type MediaHeader struct {
@@ -95,11 +185,12 @@ type LaunchRequest struct {
 
 type LoadMediaCommand struct {
 	PayloadHeader
-	Media       MediaItem   `json:"media"`
-	CurrentTime int         `json:"currentTime"`
-	Autoplay    bool        `json:"autoplay"`
-	QueueData   QueueData   `json:"queueData"`
-	CustomData  interface{} `json:"customData"`
+	Media          MediaItem   `json:"media"`
+	CurrentTime    int         `json:"currentTime"`
+	Autoplay       bool        `json:"autoplay"`
+	QueueData      QueueData   `json:"queueData"`
+	CustomData     interface{} `json:"customData"`
+	ActiveTrackIds []int       `json:"activeTrackIds,omitempty"`
 }
 
 type QueueData struct {
@@ -107,20 +198,76 @@ type QueueData struct {
 }
 
 type MediaItem struct {
-	ContentId   string        `json:"contentId"`
-	ContentType string        `json:"contentType"`
-	StreamType  string        `json:"streamType"`
-	Duration    float32       `json:"duration"`
-	Metadata    MediaMetadata `json:"metadata"`
+	ContentId      string          `json:"contentId"`
+	ContentType    string          `json:"contentType"`
+	StreamType     string          `json:"streamType"`
+	Duration       float32         `json:"duration"`
+	Metadata       MediaMetadata   `json:"metadata"`
+	Tracks         []MediaTrack    `json:"tracks,omitempty"`
+	TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
+}
+
+// Stream types of the media. Live media has no end, so can't be seeked in
+// and has no duration.
+const (
+	StreamTypeBuffered = "BUFFERED"
+	StreamTypeLive     = "LIVE"
+)
+
+// MediaTrack is a text, audio or video track of the media, ie: subtitles.
+// The chromecast only understands WebVTT text tracks.
+type MediaTrack struct {
+	TrackId          int    `json:"trackId"`
+	Type             string `json:"type"` // TEXT, AUDIO or VIDEO
+	TrackContentId   string `json:"trackContentId,omitempty"`
+	TrackContentType string `json:"trackContentType,omitempty"`
+	Subtype          string `json:"subtype,omitempty"` // SUBTITLES, CAPTIONS, DESCRIPTIONS, CHAPTERS or METADATA
+	Name             string `json:"name,omitempty"`
+	Language         string `json:"language,omitempty"`
+}
+
+// TextTrackStyle is how text tracks are shown, colours are #RRGGBBAA.
+type TextTrackStyle struct {
+	BackgroundColor string  `json:"backgroundColor,omitempty"`
+	ForegroundColor string  `json:"foregroundColor,omitempty"`
+	EdgeType        string  `json:"edgeType,omitempty"` // NONE, OUTLINE, DROP_SHADOW, RAISED or DEPRESSED
+	EdgeColor       string  `json:"edgeColor,omitempty"`
+	FontScale       float32 `json:"fontScale,omitempty"`
+	FontFamily      string  `json:"fontFamily,omitempty"`
+	WindowType      string  `json:"windowType,omitempty"` // NONE, NORMAL or ROUNDED_CORNERS
+	WindowColor     string  `json:"windowColor,omitempty"`
 }
 
+// Metadata types, they decide which of the 'MediaMetadata' fields the
+// chromecast shows.
+const (
+	MetadataGeneric    = 0
+	MetadataMovie      = 1
+	MetadataTvShow     = 2
+	MetadataMusicTrack = 3
+	MetadataPhoto      = 4
+)
+
 type MediaMetadata struct {
-	MetadataType int     `json:"metadataType`
+	MetadataType int     `json:"metadataType"`
 	Artist       string  `json:"artist"`
 	Title        string  `json:"title"`
 	Subtitle     string  `json:"subtitle"`
 	Images       []Image `json:"images"`
 	ReleaseDate  string  `json:"releaseDate"`
+
+	// Music tracks.
+	AlbumName   string `json:"albumName,omitempty"`
+	AlbumArtist string `json:"albumArtist,omitempty"`
+	Composer    string `json:"composer,omitempty"`
+	TrackNumber int    `json:"trackNumber,omitempty"`
+	DiscNumber  int    `json:"discNumber,omitempty"`
+
+	// TV shows.
+	SeriesTitle     string `json:"seriesTitle,omitempty"`
+	Season          int    `json:"season,omitempty"`
+	Episode         int    `json:"episode,omitempty"`
+	OriginalAirdate string `json:"originalAirdate,omitempty"`
 }
 
 type Image struct {
@@ -137,6 +284,9 @@ type Media struct {
 	Volume         Volume  `json:"volume"`
 	CurrentItemId  int     `json:"currentItemId"`
 	LoadingItemId  int     `json:"loadingItemId"`
+	ActiveTrackIds []int   `json:"activeTrackIds"`
+	RepeatMode     string  `json:"repeatMode"`
+	PlaybackRate   float32 `json:"playbackRate"`
 
 	Media MediaItem `json:"media"`
 }
@@ -146,7 +296,73 @@ type MediaStatusResponse struct {
 	Status []Media `json:"status"`
 }
 
+// EditTracksInfo replaces the active tracks, every track not in
+// 'ActiveTrackIds' is turned off.
+type EditTracksInfo struct {
+	PayloadHeader
+	MediaSessionId int             `json:"mediaSessionId"`
+	ActiveTrackIds []int           `json:"activeTrackIds"`
+	TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
+}
+
+// SetPlaybackRate changes how fast the media plays, 1 is normal speed.
+type SetPlaybackRate struct {
+	PayloadHeader
+	MediaSessionId int     `json:"mediaSessionId"`
+	PlaybackRate   float32 `json:"playbackRate"`
+}
+
 type SetVolume struct {
 	PayloadHeader
 	Volume Volume `json:"volume"`
 }
+
+type LoadFailedResponse struct {
+	PayloadHeader
+	ItemId int `json:"itemId,omitempty"`
+}
+
+type LoadCancelledResponse struct {
+	PayloadHeader
+	ItemId int `json:"itemId,omitempty"`
+}
+
+// InvalidRequestResponse is sent by both the receiver and media namespaces
+// when a request is not understood.
+type InvalidRequestResponse struct {
+	PayloadHeader
+	Reason string `json:"reason"`
+}
+
+type LaunchErrorResponse struct {
+	PayloadHeader
+	Reason string `json:"reason"`
+}
+
+// MultizoneDevice is a speaker that is part of a speaker group.
+type MultizoneDevice struct {
+	DeviceId     string `json:"deviceId"`
+	Name         string `json:"name"`
+	Capabilities int    `json:"capabilities"`
+	Volume       Volume `json:"volume"`
+}
+
+type MultizoneStatusResponse struct {
+	PayloadHeader
+	Status struct {
+		Devices        []MultizoneDevice `json:"devices"`
+		IsMultichannel bool              `json:"isMultichannel"`
+	} `json:"status"`
+}
+
+// MultizoneDeviceResponse is sent when a device is added to, or updated
+// in, a speaker group.
+type MultizoneDeviceResponse struct {
+	PayloadHeader
+	Device MultizoneDevice `json:"device"`
+}
+
+type MultizoneDeviceRemovedResponse struct {
+	PayloadHeader
+	DeviceId string `json:"deviceId"`
+}
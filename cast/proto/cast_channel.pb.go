@@ -26,6 +26,75 @@ var _ = proto.Marshal
 var _ = &json.SyntaxError{}
 var _ = math.Inf
 
+type SignatureAlgorithm int32
+
+const (
+	SignatureAlgorithm_UNSPECIFIED     SignatureAlgorithm = 0
+	SignatureAlgorithm_RSASSA_PKCS1v15 SignatureAlgorithm = 1
+	SignatureAlgorithm_RSASSA_PSS      SignatureAlgorithm = 2
+)
+
+var SignatureAlgorithm_name = map[int32]string{
+	0: "UNSPECIFIED",
+	1: "RSASSA_PKCS1v15",
+	2: "RSASSA_PSS",
+}
+var SignatureAlgorithm_value = map[string]int32{
+	"UNSPECIFIED":     0,
+	"RSASSA_PKCS1v15": 1,
+	"RSASSA_PSS":      2,
+}
+
+func (x SignatureAlgorithm) Enum() *SignatureAlgorithm {
+	p := new(SignatureAlgorithm)
+	*p = x
+	return p
+}
+func (x SignatureAlgorithm) String() string {
+	return proto.EnumName(SignatureAlgorithm_name, int32(x))
+}
+func (x *SignatureAlgorithm) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(SignatureAlgorithm_value, data, "SignatureAlgorithm")
+	if err != nil {
+		return err
+	}
+	*x = SignatureAlgorithm(value)
+	return nil
+}
+
+type HashAlgorithm int32
+
+const (
+	HashAlgorithm_SHA1   HashAlgorithm = 0
+	HashAlgorithm_SHA256 HashAlgorithm = 1
+)
+
+var HashAlgorithm_name = map[int32]string{
+	0: "SHA1",
+	1: "SHA256",
+}
+var HashAlgorithm_value = map[string]int32{
+	"SHA1":   0,
+	"SHA256": 1,
+}
+
+func (x HashAlgorithm) Enum() *HashAlgorithm {
+	p := new(HashAlgorithm)
+	*p = x
+	return p
+}
+func (x HashAlgorithm) String() string {
+	return proto.EnumName(HashAlgorithm_name, int32(x))
+}
+func (x *HashAlgorithm) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(HashAlgorithm_value, data, "HashAlgorithm")
+	if err != nil {
+		return err
+	}
+	*x = HashAlgorithm(value)
+	return nil
+}
+
 // Always pass a version of the protocol for future compatibility
 // requirements.
 type CastMessage_ProtocolVersion int32
@@ -210,23 +279,58 @@ func (m *CastMessage) GetPayloadBinary() []byte {
 
 // Messages for authentication protocol between a sender and a receiver.
 type AuthChallenge struct {
-	XXX_unrecognized []byte `json:"-"`
+	SignatureAlgorithm *SignatureAlgorithm `protobuf:"varint,1,opt,name=signature_algorithm,enum=api.SignatureAlgorithm,def=1" json:"signature_algorithm,omitempty"`
+	SenderNonce        []byte              `protobuf:"bytes,2,opt,name=sender_nonce" json:"sender_nonce,omitempty"`
+	HashAlgorithm      *HashAlgorithm      `protobuf:"varint,3,opt,name=hash_algorithm,enum=api.HashAlgorithm,def=0" json:"hash_algorithm,omitempty"`
+	XXX_unrecognized   []byte              `json:"-"`
 }
 
 func (m *AuthChallenge) Reset()         { *m = AuthChallenge{} }
 func (m *AuthChallenge) String() string { return proto.CompactTextString(m) }
 func (*AuthChallenge) ProtoMessage()    {}
 
+const Default_AuthChallenge_SignatureAlgorithm SignatureAlgorithm = SignatureAlgorithm_RSASSA_PKCS1v15
+const Default_AuthChallenge_HashAlgorithm HashAlgorithm = HashAlgorithm_SHA1
+
+func (m *AuthChallenge) GetSignatureAlgorithm() SignatureAlgorithm {
+	if m != nil && m.SignatureAlgorithm != nil {
+		return *m.SignatureAlgorithm
+	}
+	return Default_AuthChallenge_SignatureAlgorithm
+}
+
+func (m *AuthChallenge) GetSenderNonce() []byte {
+	if m != nil {
+		return m.SenderNonce
+	}
+	return nil
+}
+
+func (m *AuthChallenge) GetHashAlgorithm() HashAlgorithm {
+	if m != nil && m.HashAlgorithm != nil {
+		return *m.HashAlgorithm
+	}
+	return Default_AuthChallenge_HashAlgorithm
+}
+
 type AuthResponse struct {
-	Signature             []byte `protobuf:"bytes,1,req,name=signature" json:"signature,omitempty"`
-	ClientAuthCertificate []byte `protobuf:"bytes,2,req,name=client_auth_certificate" json:"client_auth_certificate,omitempty"`
-	XXX_unrecognized      []byte `json:"-"`
+	Signature               []byte              `protobuf:"bytes,1,req,name=signature" json:"signature,omitempty"`
+	ClientAuthCertificate   []byte              `protobuf:"bytes,2,req,name=client_auth_certificate" json:"client_auth_certificate,omitempty"`
+	IntermediateCertificate [][]byte            `protobuf:"bytes,3,rep,name=intermediate_certificate" json:"intermediate_certificate,omitempty"`
+	SignatureAlgorithm      *SignatureAlgorithm `protobuf:"varint,4,opt,name=signature_algorithm,enum=api.SignatureAlgorithm,def=1" json:"signature_algorithm,omitempty"`
+	SenderNonce             []byte              `protobuf:"bytes,5,opt,name=sender_nonce" json:"sender_nonce,omitempty"`
+	HashAlgorithm           *HashAlgorithm      `protobuf:"varint,6,opt,name=hash_algorithm,enum=api.HashAlgorithm,def=0" json:"hash_algorithm,omitempty"`
+	Crl                     []byte              `protobuf:"bytes,7,opt,name=crl" json:"crl,omitempty"`
+	XXX_unrecognized        []byte              `json:"-"`
 }
 
 func (m *AuthResponse) Reset()         { *m = AuthResponse{} }
 func (m *AuthResponse) String() string { return proto.CompactTextString(m) }
 func (*AuthResponse) ProtoMessage()    {}
 
+const Default_AuthResponse_SignatureAlgorithm SignatureAlgorithm = SignatureAlgorithm_RSASSA_PKCS1v15
+const Default_AuthResponse_HashAlgorithm HashAlgorithm = HashAlgorithm_SHA1
+
 func (m *AuthResponse) GetSignature() []byte {
 	if m != nil {
 		return m.Signature
@@ -241,6 +345,41 @@ func (m *AuthResponse) GetClientAuthCertificate() []byte {
 	return nil
 }
 
+func (m *AuthResponse) GetIntermediateCertificate() [][]byte {
+	if m != nil {
+		return m.IntermediateCertificate
+	}
+	return nil
+}
+
+func (m *AuthResponse) GetSignatureAlgorithm() SignatureAlgorithm {
+	if m != nil && m.SignatureAlgorithm != nil {
+		return *m.SignatureAlgorithm
+	}
+	return Default_AuthResponse_SignatureAlgorithm
+}
+
+func (m *AuthResponse) GetSenderNonce() []byte {
+	if m != nil {
+		return m.SenderNonce
+	}
+	return nil
+}
+
+func (m *AuthResponse) GetHashAlgorithm() HashAlgorithm {
+	if m != nil && m.HashAlgorithm != nil {
+		return *m.HashAlgorithm
+	}
+	return Default_AuthResponse_HashAlgorithm
+}
+
+func (m *AuthResponse) GetCrl() []byte {
+	if m != nil {
+		return m.Crl
+	}
+	return nil
+}
+
 type AuthError struct {
 	ErrorType        *AuthError_ErrorType `protobuf:"varint,1,req,name=error_type,enum=api.AuthError_ErrorType" json:"error_type,omitempty"`
 	XXX_unrecognized []byte               `json:"-"`
@@ -292,6 +431,8 @@ func (m *DeviceAuthMessage) GetError() *AuthError {
 }
 
 func init() {
+	proto.RegisterEnum("api.SignatureAlgorithm", SignatureAlgorithm_name, SignatureAlgorithm_value)
+	proto.RegisterEnum("api.HashAlgorithm", HashAlgorithm_name, HashAlgorithm_value)
 	proto.RegisterEnum("api.CastMessage_ProtocolVersion", CastMessage_ProtocolVersion_name, CastMessage_ProtocolVersion_value)
 	proto.RegisterEnum("api.CastMessage_PayloadType", CastMessage_PayloadType_name, CastMessage_PayloadType_value)
 	proto.RegisterEnum("api.AuthError_ErrorType", AuthError_ErrorType_name, AuthError_ErrorType_value)
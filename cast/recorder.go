@@ -0,0 +1,122 @@
+package cast
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+// Direction is which way a recorded message was going.
+type Direction string
+
+const (
+	DirectionSent     Direction = "sent"
+	DirectionReceived Direction = "received"
+)
+
+// Recorder is handed every message sent to, and received from, the
+// chromecast. It is called from multiple goroutines.
+type Recorder interface {
+	Record(direction Direction, message *pb.CastMessage)
+}
+
+// CapturedMessage is a single recorded message, each line of a capture file
+// is one of these.
+type CapturedMessage struct {
+	Time          time.Time `json:"time"`
+	Direction     Direction `json:"direction"`
+	Namespace     string    `json:"namespace"`
+	SourceID      string    `json:"source_id"`
+	DestinationID string    `json:"destination_id"`
+	Payload       string    `json:"payload"`
+}
+
+// Message returns the cast message that was recorded.
+func (m CapturedMessage) Message() *pb.CastMessage {
+	return &pb.CastMessage{
+		ProtocolVersion: pb.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        &m.SourceID,
+		DestinationId:   &m.DestinationID,
+		Namespace:       &m.Namespace,
+		PayloadType:     pb.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &m.Payload,
+	}
+}
+
+// CaptureRecorder writes the messages as JSON lines, which can be read
+// back with 'CaptureReader'.
+type CaptureRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewCaptureRecorder(w io.Writer) *CaptureRecorder {
+	return &CaptureRecorder{enc: json.NewEncoder(w)}
+}
+
+func (r *CaptureRecorder) Record(direction Direction, message *pb.CastMessage) {
+	captured := CapturedMessage{
+		Time:          time.Now(),
+		Direction:     direction,
+		Namespace:     message.GetNamespace(),
+		SourceID:      message.GetSourceId(),
+		DestinationID: message.GetDestinationId(),
+		Payload:       message.GetPayloadUtf8(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(captured)
+}
+
+// Err returns the first error writing the capture, once there has been an
+// error nothing else is recorded.
+func (r *CaptureRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// CaptureReader reads the messages written by a 'CaptureRecorder'.
+type CaptureReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	scanner := bufio.NewScanner(r)
+	// Media statuses with a lot of metadata can easily be larger than the
+	// default line limit.
+	scanner.Buffer(nil, 1024*1024)
+	return &CaptureReader{scanner: scanner}
+}
+
+// Next returns the next captured message, or io.EOF once there are none
+// left.
+func (r *CaptureReader) Next() (CapturedMessage, error) {
+	var captured CapturedMessage
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(r.scanner.Bytes(), &captured); err != nil {
+			return captured, errors.Wrapf(err, "unable to read captured message on line %d", r.line)
+		}
+		return captured, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return captured, errors.Wrap(err, "unable to read capture")
+	}
+	return captured, io.EOF
+}
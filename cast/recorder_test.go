@@ -0,0 +1,63 @@
+package cast
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	sent := (CapturedMessage{
+		Namespace:     "urn:x-cast:com.google.cast.receiver",
+		SourceID:      "sender-0",
+		DestinationID: "receiver-0",
+		Payload:       `{"type":"GET_STATUS","requestId":1}`,
+	}).Message()
+	received := (CapturedMessage{
+		Namespace:     "urn:x-cast:com.google.cast.receiver",
+		SourceID:      "receiver-0",
+		DestinationID: "sender-0",
+		Payload:       `{"type":"RECEIVER_STATUS","requestId":1,"status":{}}`,
+	}).Message()
+
+	var buf bytes.Buffer
+	recorder := NewCaptureRecorder(&buf)
+	recorder.Record(DirectionSent, sent)
+	recorder.Record(DirectionReceived, received)
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("unable to record: %v", err)
+	}
+
+	reader := NewCaptureReader(&buf)
+	for _, expected := range []struct {
+		direction Direction
+		payload   string
+	}{
+		{DirectionSent, sent.GetPayloadUtf8()},
+		{DirectionReceived, received.GetPayloadUtf8()},
+	} {
+		captured, err := reader.Next()
+		if err != nil {
+			t.Fatalf("unable to read captured message: %v", err)
+		}
+		if captured.Direction != expected.direction || captured.Payload != expected.payload {
+			t.Fatalf("got %s %q, expected %s %q", captured.Direction, captured.Payload, expected.direction, expected.payload)
+		}
+		if captured.Time.IsZero() {
+			t.Fatal("captured message has no time")
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("got error %v, expected %v", err, io.EOF)
+	}
+}
+
+func TestCaptureReaderInvalidLine(t *testing.T) {
+	reader := NewCaptureReader(bytes.NewBufferString("\n{\"direction\":\"sent\"}\nnot json\n"))
+	if _, err := reader.Next(); err != nil {
+		t.Fatalf("unable to read captured message: %v", err)
+	}
+	if _, err := reader.Next(); err == nil || err == io.EOF {
+		t.Fatalf("got error %v, expected the line to be invalid", err)
+	}
+}
@@ -0,0 +1,122 @@
+package cast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/buger/jsonparser"
+	"github.com/pkg/errors"
+
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+const (
+	NamespaceConnection = "urn:x-cast:com.google.cast.tp.connection"
+	NamespaceDeviceAuth = "urn:x-cast:com.google.cast.tp.deviceauth"
+	NamespaceHeartbeat  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	NamespaceReceiver   = "urn:x-cast:com.google.cast.receiver"
+	NamespaceMedia      = "urn:x-cast:com.google.cast.media"
+	NamespaceMultizone  = "urn:x-cast:com.google.cast.multizone"
+)
+
+// DefaultRegistry knows about all the messages sent by a chromecast, and is
+// used by 'Register' and 'Decode'.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.Register(NamespaceConnection, "CLOSE", &PayloadHeader{})
+	DefaultRegistry.Register(NamespaceReceiver, "RECEIVER_STATUS", &ReceiverStatusResponse{})
+	DefaultRegistry.Register(NamespaceReceiver, "LAUNCH_ERROR", &LaunchErrorResponse{})
+	DefaultRegistry.Register(NamespaceReceiver, "INVALID_REQUEST", &InvalidRequestResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "MEDIA_STATUS", &MediaStatusResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "LOAD_FAILED", &LoadFailedResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "LOAD_CANCELLED", &LoadCancelledResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "INVALID_REQUEST", &InvalidRequestResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "QUEUE_ITEM_IDS", &QueueItemIdsResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "QUEUE_ITEMS", &QueueItemsResponse{})
+	DefaultRegistry.Register(NamespaceMedia, "QUEUE_CHANGE", &QueueChangeResponse{})
+	DefaultRegistry.Register(NamespaceMultizone, "MULTIZONE_STATUS", &MultizoneStatusResponse{})
+	DefaultRegistry.Register(NamespaceMultizone, "DEVICE_ADDED", &MultizoneDeviceResponse{})
+	DefaultRegistry.Register(NamespaceMultizone, "DEVICE_UPDATED", &MultizoneDeviceResponse{})
+	DefaultRegistry.Register(NamespaceMultizone, "DEVICE_REMOVED", &MultizoneDeviceRemovedResponse{})
+}
+
+// RawMessage is what a message decodes to when there is no type registered
+// for it.
+type RawMessage struct {
+	PayloadHeader
+	Namespace string
+	Payload   json.RawMessage
+}
+
+type registryKey struct {
+	namespace   string
+	messageType string
+}
+
+// Registry maps the namespace and type of a message to the Go type its
+// payload is decoded into. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[registryKey]reflect.Type
+}
+
+func NewRegistry() *Registry {
+	return &Registry{types: map[registryKey]reflect.Type{}}
+}
+
+// Register has messages of 'messageType' on 'namespace' decoded into the
+// type 'payload' points to, ie:
+//
+//	registry.Register("urn:x-cast:com.example", "STATUS", &ExampleStatus{})
+//
+// Registering a type again replaces it.
+func (r *Registry) Register(namespace, messageType string, payload interface{}) {
+	t := reflect.TypeOf(payload)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("cast: payload registered for %s %s must be a pointer, got %T", namespace, messageType, payload))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[registryKey{namespace, messageType}] = t.Elem()
+}
+
+// Decode returns the payload of 'message' decoded into a new value of the
+// type registered for it. The value is a pointer of the same type that was
+// registered, or a *RawMessage when nothing was registered.
+func (r *Registry) Decode(message *pb.CastMessage) (interface{}, error) {
+	payload := []byte(message.GetPayloadUtf8())
+	messageType, _ := jsonparser.GetString(payload, "type")
+
+	r.mu.RLock()
+	t, ok := r.types[registryKey{message.GetNamespace(), messageType}]
+	r.mu.RUnlock()
+
+	if !ok {
+		raw := &RawMessage{
+			Namespace: message.GetNamespace(),
+			Payload:   json.RawMessage(payload),
+		}
+		// Messages aren't required to be json, so this is best effort.
+		json.Unmarshal(payload, &raw.PayloadHeader)
+		return raw, nil
+	}
+
+	value := reflect.New(t).Interface()
+	if err := json.Unmarshal(payload, value); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode %s message on %s", messageType, message.GetNamespace())
+	}
+	return value, nil
+}
+
+// Register adds a type to the 'DefaultRegistry'.
+func Register(namespace, messageType string, payload interface{}) {
+	DefaultRegistry.Register(namespace, messageType, payload)
+}
+
+// Decode decodes 'message' with the 'DefaultRegistry'.
+func Decode(message *pb.CastMessage) (interface{}, error) {
+	return DefaultRegistry.Decode(message)
+}
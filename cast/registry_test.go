@@ -0,0 +1,126 @@
+package cast
+
+import (
+	"testing"
+)
+
+func testMessage(namespace, payload string) CapturedMessage {
+	return CapturedMessage{
+		Namespace:     namespace,
+		SourceID:      "receiver-0",
+		DestinationID: "sender-0",
+		Payload:       payload,
+	}
+}
+
+func TestDecodeKnownMessages(t *testing.T) {
+	for _, test := range []struct {
+		namespace string
+		payload   string
+		check     func(t *testing.T, decoded interface{})
+	}{
+		{
+			namespace: NamespaceReceiver,
+			payload:   `{"type":"RECEIVER_STATUS","requestId":3,"status":{"applications":[{"appId":"CC1AD845"}],"volume":{"level":0.5}}}`,
+			check: func(t *testing.T, decoded interface{}) {
+				resp := decoded.(*ReceiverStatusResponse)
+				if resp.RequestId != 3 || resp.Status.Applications[0].AppId != "CC1AD845" || resp.Status.Volume.Level != 0.5 {
+					t.Fatalf("unexpected receiver status: %#v", resp)
+				}
+			},
+		},
+		{
+			namespace: NamespaceMedia,
+			payload:   `{"type":"MEDIA_STATUS","status":[{"mediaSessionId":1,"playerState":"PLAYING"}]}`,
+			check: func(t *testing.T, decoded interface{}) {
+				if resp := decoded.(*MediaStatusResponse); resp.Status[0].PlayerState != "PLAYING" {
+					t.Fatalf("unexpected media status: %#v", resp)
+				}
+			},
+		},
+		{
+			namespace: NamespaceMedia,
+			payload:   `{"type":"LOAD_FAILED","requestId":7}`,
+			check: func(t *testing.T, decoded interface{}) {
+				if resp := decoded.(*LoadFailedResponse); resp.RequestId != 7 {
+					t.Fatalf("unexpected load failed: %#v", resp)
+				}
+			},
+		},
+		{
+			namespace: NamespaceReceiver,
+			payload:   `{"type":"LAUNCH_ERROR","requestId":2,"reason":"NOT_FOUND"}`,
+			check: func(t *testing.T, decoded interface{}) {
+				if resp := decoded.(*LaunchErrorResponse); resp.Reason != "NOT_FOUND" {
+					t.Fatalf("unexpected launch error: %#v", resp)
+				}
+			},
+		},
+		{
+			namespace: NamespaceMultizone,
+			payload:   `{"type":"DEVICE_ADDED","device":{"deviceId":"abc","name":"Kitchen"}}`,
+			check: func(t *testing.T, decoded interface{}) {
+				if resp := decoded.(*MultizoneDeviceResponse); resp.Device.Name != "Kitchen" {
+					t.Fatalf("unexpected device added: %#v", resp)
+				}
+			},
+		},
+	} {
+		decoded, err := Decode(testMessage(test.namespace, test.payload).Message())
+		if err != nil {
+			t.Fatalf("unable to decode %s: %v", test.payload, err)
+		}
+		test.check(t, decoded)
+	}
+}
+
+func TestDecodeUnknownMessage(t *testing.T) {
+	payload := `{"type":"SOMETHING_NEW","requestId":4,"value":1}`
+	decoded, err := Decode(testMessage("urn:x-cast:com.example", payload).Message())
+	if err != nil {
+		t.Fatalf("unable to decode: %v", err)
+	}
+	raw, ok := decoded.(*RawMessage)
+	if !ok {
+		t.Fatalf("got %T, expected *RawMessage", decoded)
+	}
+	if raw.Type != "SOMETHING_NEW" || raw.RequestId != 4 || raw.Namespace != "urn:x-cast:com.example" || string(raw.Payload) != payload {
+		t.Fatalf("unexpected raw message: %#v", raw)
+	}
+
+	// A known type on a different namespace isn't known.
+	decoded, err = Decode(testMessage("urn:x-cast:com.example", `{"type":"MEDIA_STATUS"}`).Message())
+	if _, ok := decoded.(*RawMessage); err != nil || !ok {
+		t.Fatalf("got %T (%v), expected *RawMessage", decoded, err)
+	}
+}
+
+func TestRegistryCustomType(t *testing.T) {
+	type exampleStatus struct {
+		PayloadHeader
+		Score int `json:"score"`
+	}
+	registry := NewRegistry()
+	registry.Register("urn:x-cast:com.example", "STATUS", &exampleStatus{})
+
+	decoded, err := registry.Decode(testMessage("urn:x-cast:com.example", `{"type":"STATUS","score":42}`).Message())
+	if err != nil {
+		t.Fatalf("unable to decode: %v", err)
+	}
+	if status, ok := decoded.(*exampleStatus); !ok || status.Score != 42 {
+		t.Fatalf("unexpected decoded value: %#v", decoded)
+	}
+
+	if _, err := registry.Decode(testMessage("urn:x-cast:com.example", `{"type":"STATUS","score":"lots"}`).Message()); err == nil {
+		t.Fatal("expected an error decoding an invalid payload")
+	}
+}
+
+func TestRegistryRegisterNonPointer(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a non-pointer to panic")
+		}
+	}()
+	NewRegistry().Register("urn:x-cast:com.example", "STATUS", PayloadHeader{})
+}
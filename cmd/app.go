@@ -0,0 +1,137 @@
+// Copyright © 2018 Jonathan Pentecost <pentecostjonathan@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// appCmd represents the app command
+var appCmd = &cobra.Command{
+	Use:   "app",
+	Short: "Launch and send messages to any receiver app",
+}
+
+// appLaunchCmd represents the app launch command
+var appLaunchCmd = &cobra.Command{
+	Use:   "launch <app_id>",
+	Short: "Launch a receiver app on the chromecast",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Printf("requires exactly one argument, the app id to launch\n")
+			return
+		}
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		if err := app.LaunchApp(context.Background(), args[0]); err != nil {
+			fmt.Printf("unable to launch app: %v\n", err)
+			return
+		}
+		castApplication, _, _ := app.Status()
+		fmt.Printf("launched %s (%s)\n", castApplication.DisplayName, castApplication.AppId)
+	},
+}
+
+// appSendCmd represents the app send command
+var appSendCmd = &cobra.Command{
+	Use:   "send <namespace> <json_payload>",
+	Short: "Send a JSON message to the running receiver app",
+	Long: `Send a JSON message to the running receiver app on the given namespace,
+ie: go-chromecast app send urn:x-cast:com.example.app '{"type":"PING"}'.
+
+Use --wait to print what the app sends back on the namespace.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			fmt.Printf("requires exactly two arguments, the namespace and the json payload\n")
+			return
+		}
+		var payload cast.CustomPayload
+		if err := json.Unmarshal([]byte(args[1]), &payload); err != nil {
+			fmt.Printf("invalid json payload, it must be an object: %v\n", err)
+			return
+		}
+
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		ctx := context.Background()
+		if appID, _ := cmd.Flags().GetString("app-id"); appID != "" {
+			if err := app.LaunchApp(ctx, appID); err != nil {
+				fmt.Printf("unable to launch app: %v\n", err)
+				return
+			}
+		}
+
+		// Subscribe before sending, so a quick reply isn't missed.
+		sub := app.Subscribe()
+		defer sub.Unsubscribe()
+		if err := app.SendMessage(ctx, args[0], payload); err != nil {
+			fmt.Printf("unable to send message: %v\n", err)
+			return
+		}
+
+		wait, _ := cmd.Flags().GetDuration("wait")
+		if wait > 0 {
+			printAppMessages(sub, args[0], wait)
+		}
+	},
+}
+
+// printAppMessages prints the payload of every message received on
+// 'namespace' until 'wait' has passed.
+func printAppMessages(sub *application.Subscription, namespace string, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			switch e := event.(type) {
+			case application.Disconnected:
+				fmt.Printf("lost connection to chromecast: %v\n", e.Err)
+				return
+			case application.MessageReceived:
+				if e.Message.GetNamespace() == namespace {
+					fmt.Println(e.Message.GetPayloadUtf8())
+				}
+			}
+		}
+	}
+}
+
+func init() {
+	appSendCmd.Flags().String("app-id", "", "launch this app, unless it is already running, before sending the message")
+	appSendCmd.Flags().Duration("wait", 0, "how long to print the messages sent back by the app on the namespace, ie: 5s")
+	appCmd.AddCommand(appLaunchCmd)
+	appCmd.AddCommand(appSendCmd)
+	rootCmd.AddCommand(appCmd)
+}
@@ -16,6 +16,8 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
 	"github.com/vishen/go-chromecast/http"
 )
 
@@ -39,7 +41,25 @@ var httpserverCmd = &cobra.Command{
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		debug, _ := cmd.Flags().GetBool("debug")
 
-		return http.NewHandler(verbose || debug, deviceUuid, deviceAddr, devicePort, googleServiceAccount, languageCode).Serve(httpAddr + ":" + httpPort)
+		h := http.NewHandler(verbose || debug, deviceUuid, deviceAddr, devicePort, googleServiceAccount, languageCode)
+		if trustStore, _ := cmd.Flags().GetString("trust-store"); trustStore != "" {
+			roots, err := cast.LoadTrustStore(trustStore)
+			if err != nil {
+				return err
+			}
+			h.SetTrustStore(roots)
+		}
+		if deviceOnly, _ := cmd.Flags().GetBool("serve-to-device-only"); deviceOnly {
+			h.SetServeToDeviceOnly(true)
+		}
+		if name, _ := cmd.Flags().GetString("device-profile"); name != "" {
+			profile, err := application.ParseDeviceProfile(name)
+			if err != nil {
+				return err
+			}
+			h.SetDeviceProfile(profile)
+		}
+		return h.Serve(httpAddr + ":" + httpPort)
 	},
 }
 
@@ -15,8 +15,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/vishen/go-chromecast/application"
 	"github.com/vishen/go-chromecast/ui"
 
 	"github.com/sirupsen/logrus"
@@ -33,7 +35,27 @@ chromecast if it is a local file, otherwise it will load the url.
 
 If the media file is an unplayable media type by the chromecast, this
 will attempt to transcode the media file to mp4 using ffmpeg. This requires
-that ffmpeg is installed.`,
+that ffmpeg is installed. With --hls it is transcoded to HLS segments
+instead, which can be seeked in and survives the chromecast reconnecting.
+
+Subtitles next to a local media file, ie: movie.srt or movie.en.vtt for
+movie.mp4, are shown with it.
+
+The title, artist and album shown are read from the tags in local media
+files, ffprobe is used for formats other than mp3, mp4 and flac when it is
+installed. Use --title and --artist to show something else, or to name
+urls.
+
+Local media starts from where it was last played, unless it was watched
+to the end or --from-start is given.
+
+Use --start to begin playback partway through, ie: --start 1h02m, and
+--app-id to play it with a styled or custom receiver app rather than the
+default media receiver.
+
+Urls are checked for whether they are live, ie: internet radio or a live
+HLS or DASH stream, which can't be seeked in. Use --live when it isn't
+noticed.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return fmt.Errorf("requires exactly one argument, should be the media file to load")
@@ -47,12 +69,43 @@ that ffmpeg is installed.`,
 		contentType, _ := cmd.Flags().GetString("content-type")
 		transcode, _ := cmd.Flags().GetBool("transcode")
 		detach, _ := cmd.Flags().GetBool("detach")
+		var loadOptions []application.LoadOption
+		if subtitles, _ := cmd.Flags().GetString("subtitles"); subtitles != "" {
+			loadOptions = append(loadOptions, application.WithSubtitles(subtitles))
+		}
+		if title, _ := cmd.Flags().GetString("title"); title != "" {
+			loadOptions = append(loadOptions, application.WithTitle(title))
+		}
+		if artist, _ := cmd.Flags().GetString("artist"); artist != "" {
+			loadOptions = append(loadOptions, application.WithArtist(artist))
+		}
+		if start, _ := cmd.Flags().GetString("start"); start != "" {
+			seconds, err := application.ParseOffset(start)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return nil
+			}
+			loadOptions = append(loadOptions, application.WithStart(seconds))
+		}
+		if appID, _ := cmd.Flags().GetString("app-id"); appID != "" {
+			loadOptions = append(loadOptions, application.WithAppID(appID))
+		}
+		if hls, _ := cmd.Flags().GetBool("hls"); hls {
+			loadOptions = append(loadOptions, application.WithHLS())
+		}
+		if live, _ := cmd.Flags().GetBool("live"); live {
+			loadOptions = append(loadOptions, application.WithLive())
+		}
+		if fromStart, _ := cmd.Flags().GetBool("from-start"); !fromStart {
+			watched, _ := cmd.Flags().GetFloat32("watched")
+			loadOptions = append(loadOptions, application.WithResume(watched/100))
+		}
 
 		// Optionally run a UI when playing this media:
 		runWithUI, _ := cmd.Flags().GetBool("with-ui")
 		if runWithUI {
 			go func() {
-				if err := app.Load(args[0], contentType, transcode, detach, false); err != nil {
+				if err := app.Load(context.Background(), args[0], contentType, transcode, detach, false, loadOptions...); err != nil {
 					logrus.WithError(err).Fatal("unable to load media")
 				}
 			}()
@@ -65,7 +118,7 @@ that ffmpeg is installed.`,
 		}
 
 		// Otherwise just run in CLI mode:
-		if err := app.Load(args[0], contentType, transcode, detach, false); err != nil {
+		if err := app.Load(context.Background(), args[0], contentType, transcode, detach, false, loadOptions...); err != nil {
 			fmt.Printf("unable to load media: %v\n", err)
 			return nil
 		}
@@ -76,6 +129,15 @@ that ffmpeg is installed.`,
 func init() {
 	rootCmd.AddCommand(loadCmd)
 	loadCmd.Flags().Bool("transcode", true, "transcode the media to mp4 if media type is unrecognised")
+	loadCmd.Flags().Bool("hls", false, "transcode to HLS segments in a temporary directory, rather than streaming mp4")
 	loadCmd.Flags().Bool("detach", false, "detach from waiting until media finished. Only works with url loaded external media")
 	loadCmd.Flags().StringP("content-type", "c", "", "content-type to serve the media file as")
+	loadCmd.Flags().String("subtitles", "", "subtitles file to show, .srt or .vtt, instead of any found next to the media")
+	loadCmd.Flags().String("title", "", "title to show for the media, instead of the one in its tags")
+	loadCmd.Flags().String("artist", "", "artist to show for the media, instead of the one in its tags")
+	loadCmd.Flags().String("start", "", "how far into the media to start playing, ie: 1h02m, 90s or 90")
+	loadCmd.Flags().String("app-id", "", "receiver app to play the media with, instead of the default media receiver")
+	loadCmd.Flags().Bool("live", false, "load the url as a live stream, without checking whether it is one")
+	loadCmd.Flags().Bool("from-start", false, "play the media from the start, rather than from where it was last played")
+	loadCmd.Flags().Float32("watched", application.DefaultWatchedThreshold*100, "percentage of the media played after which it is watched, and plays from the start")
 }
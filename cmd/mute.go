@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var muteCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.SetMuted(true); err != nil {
+		if err := app.SetMuted(context.Background(), true); err != nil {
 			fmt.Printf("unable to mute cast application: %v\n", err)
 			return
 		}
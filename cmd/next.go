@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var nextCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.Next(); err != nil {
+		if err := app.Next(context.Background()); err != nil {
 			fmt.Printf("unable to play next media: %v\n", err)
 			return
 		}
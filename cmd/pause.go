@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var pauseCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.Pause(); err != nil {
+		if err := app.Pause(context.Background()); err != nil {
 			fmt.Printf("unable to pause cast application: %v\n", err)
 			return
 		}
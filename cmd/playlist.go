@@ -16,8 +16,10 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"sort"
@@ -27,6 +29,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/vishen/go-chromecast/application"
 	"github.com/vishen/go-chromecast/ui"
 )
 
@@ -45,7 +48,19 @@ chromecast.
 
 If the media file is an unplayable media type by the chromecast, this
 will attempt to transcode the media file to mp4 using ffmpeg. This requires
-that ffmpeg is installed.`,
+that ffmpeg is installed. With --hls it is transcoded to HLS segments
+instead, which can be seeked in and survives the chromecast reconnecting.
+
+Subtitles next to each media file, ie: movie.srt or movie.en.vtt for
+movie.mp4, are shown with it.
+
+With --shuffle the media is played in a random order, which is kept
+for the next time the playlist is continued. Use --continue=false for
+a new order.
+
+When continuing, the playlist starts from the media that was last played,
+from where it got to, or from the next media if it was watched to the
+end. Use --from-start to play it from the start.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return fmt.Errorf("requires exactly one argument, should be the folder to play media from")
@@ -68,6 +83,24 @@ that ffmpeg is installed.`,
 		forcePlay, _ := cmd.Flags().GetBool("force-play")
 		continuePlaying, _ := cmd.Flags().GetBool("continue")
 		selection, _ := cmd.Flags().GetBool("select")
+		shuffle, _ := cmd.Flags().GetBool("shuffle")
+		disableCache, _ := cmd.Flags().GetBool("disable-cache")
+		fromStart, _ := cmd.Flags().GetBool("from-start")
+		watched, _ := cmd.Flags().GetFloat32("watched")
+		watched /= 100
+		repeat, _ := cmd.Flags().GetString("repeat")
+		repeatMode, err := application.ParseRepeatMode(repeat)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return nil
+		}
+		loadOptions := []application.LoadOption{application.WithRepeatMode(repeatMode)}
+		if subtitles, _ := cmd.Flags().GetString("subtitles"); subtitles != "" {
+			loadOptions = append(loadOptions, application.WithSubtitlesDir(subtitles))
+		}
+		if hls, _ := cmd.Flags().GetBool("hls"); hls {
+			loadOptions = append(loadOptions, application.WithHLS())
+		}
 		files, err := ioutil.ReadDir(args[0])
 		if err != nil {
 			fmt.Printf("unable to list files from %q: %v", args[0], err)
@@ -141,6 +174,9 @@ that ffmpeg is installed.`,
 			filename := filepath.Join(args[0], f.filename)
 			filenames[i] = filename
 		}
+		if shuffle {
+			shuffleFilenames(filenames, playlistShuffleSeed(args[0], continuePlaying, disableCache))
+		}
 
 		indexToPlayFrom := 0
 		if selection {
@@ -171,29 +207,14 @@ that ffmpeg is installed.`,
 				break
 			}
 		} else if continuePlaying {
-			var lastPlayedStartUnix int64 = 0
-			var lastPlayedEndUnix int64 = 0
-			lastPlayedIndex := 0
-			for i, f := range filenames {
-				p, ok := app.PlayedItems()[f]
-				if ok && p.Started > lastPlayedStartUnix {
-					lastPlayedStartUnix = p.Started
-					lastPlayedEndUnix = p.Finished
-					lastPlayedIndex = i
-				}
-			}
-
-			if lastPlayedIndex > 0 {
-				if lastPlayedStartUnix < lastPlayedEndUnix {
-					if len(filenames) > lastPlayedIndex {
-						// lastPlayedIndex += 1
-					} else {
-						lastPlayedIndex = 0
-					}
-				}
-			}
-			indexToPlayFrom = lastPlayedIndex
+			indexToPlayFrom = continueFrom(filenames, app.PlayedItems(), watched)
+		}
+		if continuePlaying && !fromStart {
+			loadOptions = append(loadOptions, application.WithResume(watched))
 		}
+		// The whole playlist is queued, so what was skipped is played when
+		// it repeats.
+		loadOptions = append(loadOptions, application.WithStartIndex(indexToPlayFrom))
 
 		fmt.Println("Attemping to play the following media:")
 		for _, f := range filenames[indexToPlayFrom:] {
@@ -204,7 +225,7 @@ that ffmpeg is installed.`,
 		runWithUI, _ := cmd.Flags().GetBool("with-ui")
 		if runWithUI {
 			go func() {
-				if err := app.QueueLoad(filenames[indexToPlayFrom:], contentType, transcode); err != nil {
+				if err := app.QueueLoad(context.Background(), filenames, contentType, transcode, loadOptions...); err != nil {
 					logrus.WithError(err).Fatal("unable to play playlist on cast application")
 				}
 			}()
@@ -216,7 +237,7 @@ that ffmpeg is installed.`,
 			return ccui.Run()
 		}
 
-		if err := app.QueueLoad(filenames[indexToPlayFrom:], contentType, transcode); err != nil {
+		if err := app.QueueLoad(context.Background(), filenames, contentType, transcode, loadOptions...); err != nil {
 			fmt.Printf("unable to play playlist on cast application: %v\n", err)
 			return nil
 		}
@@ -224,11 +245,66 @@ that ffmpeg is installed.`,
 	},
 }
 
+// continueFrom returns the index in 'filenames' to continue the playlist
+// from, the media that was last played, or the media after it if it was
+// watched.
+func continueFrom(filenames []string, playedItems map[string]application.PlayedItem, watchedThreshold float32) int {
+	var lastStarted int64
+	index := 0
+	for i, f := range filenames {
+		if p, ok := playedItems[f]; ok && p.Started > lastStarted {
+			lastStarted = p.Started
+			index = i
+		}
+	}
+	if lastStarted > 0 && playedItems[filenames[index]].Watched(watchedThreshold) {
+		// Start again after the last one.
+		index = (index + 1) % len(filenames)
+	}
+	return index
+}
+
+// playlistShuffleSeed returns the seed to shuffle the playlist in 'dir'
+// with. When continuing it is the seed the playlist was last shuffled with,
+// so it is played in the same order and picks up where it left off.
+func playlistShuffleSeed(dir string, continuePlaying, disableCache bool) int64 {
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	cacheKey := fmt.Sprintf("cmd/playlist/shuffle/%s", dir)
+	if continuePlaying && !disableCache {
+		if b, err := cache.Load(cacheKey); err == nil {
+			if seed, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+				return seed
+			}
+		}
+	}
+	seed := time.Now().UnixNano()
+	if !disableCache {
+		cache.Save(cacheKey, []byte(strconv.FormatInt(seed, 10)))
+	}
+	return seed
+}
+
+// shuffleFilenames shuffles 'filenames' in place, the same seed always
+// gives the same order.
+func shuffleFilenames(filenames []string, seed int64) {
+	rand.New(rand.NewSource(seed)).Shuffle(len(filenames), func(i, j int) {
+		filenames[i], filenames[j] = filenames[j], filenames[i]
+	})
+}
+
 func init() {
 	rootCmd.AddCommand(playlistCmd)
 	playlistCmd.Flags().Bool("continue", true, "continue playing from the last known media")
 	playlistCmd.Flags().Bool("select", false, "choose which media to start the playlist from")
 	playlistCmd.Flags().Bool("transcode", true, "transcode the media to mp4 if media type is unrecognised")
+	playlistCmd.Flags().Bool("hls", false, "transcode to HLS segments in a temporary directory, rather than streaming mp4")
 	playlistCmd.Flags().Bool("force-play", false, "attempt to play a media type even if it is unrecognised")
 	playlistCmd.Flags().StringP("content-type", "c", "", "content-type to serve the media file as")
+	playlistCmd.Flags().String("subtitles", "", "directory to also look for subtitles files in")
+	playlistCmd.Flags().String("repeat", "off", "repeat mode: off, all, single or all-and-shuffle")
+	playlistCmd.Flags().Bool("shuffle", false, "play the media in a random order, which is kept when continuing the playlist")
+	playlistCmd.Flags().Bool("from-start", false, "start the media the playlist continues from at its beginning, rather than where it was last played")
+	playlistCmd.Flags().Float32("watched", application.DefaultWatchedThreshold*100, "percentage of the media played after which it is watched, and the playlist continues from the next media")
 }
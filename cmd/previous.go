@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var previousCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.Previous(); err != nil {
+		if err := app.Previous(context.Background()); err != nil {
 			fmt.Printf("unable to play previous media: %v\n", err)
 			return
 		}
@@ -0,0 +1,255 @@
+// Copyright © 2018 Jonathan Pentecost <pentecostjonathan@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vishen/go-chromecast/application"
+)
+
+// queueCmd represents the queue command
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "List and change the queue of media playing",
+}
+
+// queueLsCmd represents the queue ls command
+var queueLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the items in the queue, the playing item is marked with a '*'",
+	Run: func(cmd *cobra.Command, args []string) {
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		items, err := app.QueueItems(context.Background())
+		if err != nil {
+			fmt.Printf("unable to get queue: %v\n", err)
+			return
+		}
+		var currentItemID int
+		if media := app.Media(); media != nil {
+			currentItemID = media.CurrentItemId
+		}
+		for _, item := range items {
+			marker := " "
+			if item.ItemId == currentItemID {
+				marker = "*"
+			}
+			name := item.Media.Metadata.Title
+			if name == "" {
+				name = item.Media.ContentId
+			}
+			fmt.Printf("%s %d %s\n", marker, item.ItemId, name)
+		}
+	},
+}
+
+// queueAddCmd represents the queue add command
+var queueAddCmd = &cobra.Command{
+	Use:   "add <filename_or_url>...",
+	Short: "Add media to the queue that is playing",
+	Long: `Add media files or urls to the queue that is playing, at the end
+of it or before the item given with --before.
+
+Local media files are served for as long as the command runs, so it
+keeps running until the queue has finished playing.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Printf("requires at least one argument, the media to add\n")
+			return
+		}
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		contentType, _ := cmd.Flags().GetString("content-type")
+		transcode, _ := cmd.Flags().GetBool("transcode")
+		before, _ := cmd.Flags().GetInt("before")
+		var loadOptions []application.LoadOption
+		if subtitles, _ := cmd.Flags().GetString("subtitles"); subtitles != "" {
+			loadOptions = append(loadOptions, application.WithSubtitles(subtitles))
+		}
+
+		ctx := context.Background()
+		if err := app.QueueInsert(ctx, args, contentType, transcode, before, loadOptions...); err != nil {
+			fmt.Printf("unable to add to queue: %v\n", err)
+			return
+		}
+		for _, filenameOrUrl := range args {
+			if !strings.HasPrefix(filenameOrUrl, "http://") && !strings.HasPrefix(filenameOrUrl, "https://") {
+				fmt.Println("serving local media until the queue has finished playing")
+				if err := app.WaitForMedia(ctx); err != nil {
+					fmt.Printf("error waiting for the queue to finish: %v\n", err)
+				}
+				return
+			}
+		}
+	},
+}
+
+// queueRemoveCmd represents the queue remove command
+var queueRemoveCmd = &cobra.Command{
+	Use:   "remove <item_id>...",
+	Short: "Remove items from the queue",
+	Run: func(cmd *cobra.Command, args []string) {
+		itemIDs, err := parseItemIDs(args)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		if err := app.QueueRemove(context.Background(), itemIDs...); err != nil {
+			fmt.Printf("unable to remove from queue: %v\n", err)
+			return
+		}
+	},
+}
+
+// queueMoveCmd represents the queue move command
+var queueMoveCmd = &cobra.Command{
+	Use:   "move <item_id>...",
+	Short: "Move items within the queue",
+	Long: `Move items, in the order given, to the end of the queue or before
+the item given with --before.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		itemIDs, err := parseItemIDs(args)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		before, _ := cmd.Flags().GetInt("before")
+		if err := app.QueueReorder(context.Background(), itemIDs, before); err != nil {
+			fmt.Printf("unable to move queue items: %v\n", err)
+			return
+		}
+	},
+}
+
+// queueJumpCmd represents the queue jump command
+var queueJumpCmd = &cobra.Command{
+	Use:   "jump <item_id>",
+	Short: "Play an item in the queue",
+	Run: func(cmd *cobra.Command, args []string) {
+		itemIDs, err := parseItemIDs(args)
+		if err != nil {
+			fmt.Println(err)
+			return
+		} else if len(itemIDs) != 1 {
+			fmt.Printf("requires exactly one argument, the item id to play\n")
+			return
+		}
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		if err := app.QueueJump(context.Background(), itemIDs[0]); err != nil {
+			fmt.Printf("unable to jump to queue item: %v\n", err)
+			return
+		}
+	},
+}
+
+// queueRepeatCmd represents the queue repeat command
+var queueRepeatCmd = &cobra.Command{
+	Use:   "repeat <off|all|single|all-and-shuffle>",
+	Short: "Change the repeat mode of the queue",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Printf("requires exactly one argument, the repeat mode\n")
+			return
+		}
+		repeatMode, err := application.ParseRepeatMode(args[0])
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		if err := app.SetRepeatMode(context.Background(), repeatMode); err != nil {
+			fmt.Printf("unable to change repeat mode: %v\n", err)
+			return
+		}
+	},
+}
+
+// queueShuffleCmd represents the queue shuffle command
+var queueShuffleCmd = &cobra.Command{
+	Use:   "shuffle",
+	Short: "Shuffle the queue, the playing item keeps playing",
+	Run: func(cmd *cobra.Command, args []string) {
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		if err := app.ShuffleQueue(context.Background()); err != nil {
+			fmt.Printf("unable to shuffle queue: %v\n", err)
+			return
+		}
+	},
+}
+
+func parseItemIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("requires at least one argument, the queue item ids")
+	}
+	itemIDs := make([]int, len(args))
+	for i, arg := range args {
+		itemID, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("queue item id %q is not a number", arg)
+		}
+		itemIDs[i] = itemID
+	}
+	return itemIDs, nil
+}
+
+func init() {
+	queueAddCmd.Flags().Int("before", 0, "id of the queue item to add the media before, by default it is added to the end")
+	queueAddCmd.Flags().StringP("content-type", "c", "", "content-type to serve the media file as")
+	queueAddCmd.Flags().Bool("transcode", true, "transcode the media to mp4 if media type is unrecognised")
+	queueAddCmd.Flags().String("subtitles", "", "subtitles file to show with the first media added, a .srt or .vtt file")
+	queueMoveCmd.Flags().Int("before", 0, "id of the queue item to move the items before, by default they are moved to the end")
+	queueCmd.AddCommand(queueLsCmd)
+	queueCmd.AddCommand(queueAddCmd)
+	queueCmd.AddCommand(queueRemoveCmd)
+	queueCmd.AddCommand(queueMoveCmd)
+	queueCmd.AddCommand(queueJumpCmd)
+	queueCmd.AddCommand(queueRepeatCmd)
+	queueCmd.AddCommand(queueShuffleCmd)
+	rootCmd.AddCommand(queueCmd)
+}
@@ -0,0 +1,63 @@
+// Copyright © 2018 Jonathan Pentecost <pentecostjonathan@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vishen/go-chromecast/application"
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <capture_file>",
+	Short: "Replay a capture recorded with 'watch --record'",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Printf("requires exactly one argument, the capture file to replay\n")
+			return
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("unable to open capture file: %v\n", err)
+			return
+		}
+		defer f.Close()
+
+		debug, _ := cmd.Flags().GetBool("debug")
+		app := application.NewApplication(
+			application.WithDebug(debug),
+			application.WithCacheDisabled(true),
+		)
+		sub := app.Subscribe()
+		defer sub.Unsubscribe()
+
+		go func() {
+			if err := app.Replay(context.Background(), f); err != nil {
+				fmt.Printf("unable to replay capture: %v\n", err)
+				app.Close(false)
+			}
+		}()
+		watchEvents(app, sub)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
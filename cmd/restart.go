@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -31,7 +32,7 @@ var restartCmd = &cobra.Command{
 			return
 		}
 		// TODO(): THIS DOES NOT WORK
-		if err := app.SeekFromStart(0); err != nil {
+		if err := app.SeekFromStart(context.Background(), 0); err != nil {
 			fmt.Printf("unable to restart media: %v\n", err)
 			return
 		}
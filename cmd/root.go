@@ -77,6 +77,9 @@ func init() {
 	rootCmd.PersistentFlags().StringP("addr", "a", "", "Address of the chromecast device")
 	rootCmd.PersistentFlags().StringP("port", "p", "8009", "Port of the chromecast device if 'addr' is specified")
 	rootCmd.PersistentFlags().StringP("iface", "i", "", "Network interface to use when looking for a local address to use for the http server or for use with multicast dns discovery")
+	rootCmd.PersistentFlags().Bool("serve-to-device-only", false, "only serve local media to the chromecast, rather than to anything on the network with its url")
+	rootCmd.PersistentFlags().String("device-profile", "chromecast", "what the chromecast plays without transcoding, so only the rest is transcoded: chromecast, ultra, google-tv or audio")
+	rootCmd.PersistentFlags().String("trust-store", "", "PEM file of the certificates trusted to issue chromecast device certificates, when set the chromecast has to authenticate as a genuine cast device before it is used")
 	rootCmd.PersistentFlags().Int("dns-timeout", 3, "Multicast DNS timeout in seconds when searching for chromecast DNS entries")
 	rootCmd.PersistentFlags().Bool("first", false, "Use first cast device found")
 }
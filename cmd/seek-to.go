@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -40,7 +41,7 @@ var seekToCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return nil
 		}
-		if err := app.SeekToTime(float32(value)); err != nil {
+		if err := app.SeekToTime(context.Background(), float32(value)); err != nil {
 			fmt.Printf("unable to seek to current media: %v\n", err)
 			return nil
 		}
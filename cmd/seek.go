@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -40,7 +41,7 @@ var seekCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return nil
 		}
-		if err := app.Seek(value); err != nil {
+		if err := app.Seek(context.Background(), value); err != nil {
 			fmt.Printf("unable to seek current media: %v\n", err)
 			return nil
 		}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -46,7 +47,7 @@ var slideshowCmd = &cobra.Command{
 
 		duration, _ := cmd.Flags().GetInt("duration")
 		repeat, _ := cmd.Flags().GetBool("repeat")
-		if err := app.Slideshow(args, duration, repeat); err != nil {
+		if err := app.Slideshow(context.Background(), args, duration, repeat); err != nil {
 			fmt.Printf("unable to play slideshow on cast application: %v\n", err)
 			return nil
 		}
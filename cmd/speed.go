@@ -0,0 +1,55 @@
+// Copyright © 2018 Jonathan Pentecost <pentecostjonathan@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// speedCmd represents the speed command
+var speedCmd = &cobra.Command{
+	Use:   "speed [<0.5 - 2.0>]",
+	Short: "Get or set the playback rate of the currently playing media",
+	Long:  "Get or set the playback rate of the currently playing media (float in range from 0.5 to 2, 1 is normal speed)",
+	Run: func(cmd *cobra.Command, args []string) {
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+
+		if len(args) == 1 && args[0] != "" {
+			rate, err := strconv.ParseFloat(args[0], 32)
+			if err != nil {
+				fmt.Printf("invalid playback rate: %v\n", err)
+				return
+			}
+			if err = app.SetPlaybackRate(context.Background(), float32(rate)); err != nil {
+				fmt.Printf("failed to set playback rate: %v\n", err)
+				return
+			}
+		}
+
+		fmt.Printf("%0.2f\n", app.PlaybackRate())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(speedCmd)
+}
@@ -48,7 +48,7 @@ var statusCmd = &cobra.Command{
 				md := castMedia.Media.Metadata
 				metadata = fmt.Sprintf("title=%q, artist=%q", md.Title, md.Artist)
 			}
-			fmt.Printf("%s%s (%s), %s, time remaining=%.0fs/%.0fs, volume=%0.2f, muted=%t\n", usefulID, castApplication.DisplayName, castMedia.PlayerState, metadata, castMedia.CurrentTime, castMedia.Media.Duration, castVolume.Level, castVolume.Muted)
+			fmt.Printf("%s%s (%s), %s, %s, volume=%0.2f, muted=%t\n", usefulID, castApplication.DisplayName, castMedia.PlayerState, metadata, mediaPosition(castMedia), castVolume.Level, castVolume.Muted)
 		}
 		return
 	},
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var stopCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.Stop(); err != nil {
+		if err := app.Stop(context.Background()); err != nil {
 			fmt.Printf("unable to stop casting: %v\n", err)
 			return
 		}
@@ -0,0 +1,112 @@
+// Copyright © 2018 Jonathan Pentecost <pentecostjonathan@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vishen/go-chromecast/cast"
+)
+
+// tracksCmd represents the tracks command
+var tracksCmd = &cobra.Command{
+	Use:   "tracks",
+	Short: "List or switch the audio and subtitle tracks",
+	Long: `List the audio, video and subtitle tracks of the currently playing media,
+or switch between them and change how subtitles are shown.
+
+  go-chromecast tracks --track 2
+  go-chromecast tracks --subtitles-off --audio fr
+  go-chromecast tracks --font-scale 1.5 --edge-type OUTLINE`,
+	Run: func(cmd *cobra.Command, args []string) {
+		app, err := castApplication(cmd, args)
+		if err != nil {
+			fmt.Printf("unable to get cast application: %v\n", err)
+			return
+		}
+		ctx := context.Background()
+
+		if trackID, _ := cmd.Flags().GetInt("track"); trackID != 0 {
+			if err := app.EnableTrack(ctx, trackID); err != nil {
+				fmt.Printf("unable to switch track: %v\n", err)
+				return
+			}
+		}
+		if subtitlesOff, _ := cmd.Flags().GetBool("subtitles-off"); subtitlesOff {
+			if err := app.DisableSubtitles(ctx); err != nil {
+				fmt.Printf("unable to turn off subtitles: %v\n", err)
+				return
+			}
+		}
+		if audio, _ := cmd.Flags().GetString("audio"); audio != "" {
+			if err := app.SetAudioLanguage(ctx, audio); err != nil {
+				fmt.Printf("unable to change audio track: %v\n", err)
+				return
+			}
+		}
+
+		var style cast.TextTrackStyle
+		style.FontScale, _ = cmd.Flags().GetFloat32("font-scale")
+		style.ForegroundColor, _ = cmd.Flags().GetString("foreground-color")
+		style.BackgroundColor, _ = cmd.Flags().GetString("background-color")
+		style.EdgeType, _ = cmd.Flags().GetString("edge-type")
+		style.EdgeColor, _ = cmd.Flags().GetString("edge-color")
+		if style != (cast.TextTrackStyle{}) {
+			if err := app.SetTextTrackStyle(ctx, style); err != nil {
+				fmt.Printf("unable to change subtitles style: %v\n", err)
+				return
+			}
+		}
+
+		printTracks(app.Tracks())
+	},
+}
+
+func printTracks(tracks []cast.MediaTrack, activeTrackIDs []int) {
+	if len(tracks) == 0 {
+		fmt.Println("no tracks")
+		return
+	}
+	active := map[int]bool{}
+	for _, id := range activeTrackIDs {
+		active[id] = true
+	}
+	for _, track := range tracks {
+		marker := " "
+		if active[track.TrackId] {
+			marker = "*"
+		}
+		kind := track.Type
+		if track.Subtype != "" {
+			kind += "/" + track.Subtype
+		}
+		fmt.Printf("%s %d %s language=%q name=%q\n", marker, track.TrackId, kind, track.Language, track.Name)
+	}
+}
+
+func init() {
+	tracksCmd.Flags().Int("track", 0, "id of the track to switch to, in place of the active track of the same type")
+	tracksCmd.Flags().Bool("subtitles-off", false, "turn off the subtitles")
+	tracksCmd.Flags().String("audio", "", "language of the audio track to switch to, ie: 'en' or 'pt-BR'")
+	tracksCmd.Flags().Float32("font-scale", 0, "subtitles font scale, 1.0 is the default size")
+	tracksCmd.Flags().String("foreground-color", "", "subtitles text colour, as #RRGGBBAA")
+	tracksCmd.Flags().String("background-color", "", "subtitles background colour, as #RRGGBBAA")
+	tracksCmd.Flags().String("edge-type", "", "subtitles edge: NONE, OUTLINE, DROP_SHADOW, RAISED or DEPRESSED")
+	tracksCmd.Flags().String("edge-color", "", "subtitles edge colour, as #RRGGBBAA")
+	rootCmd.AddCommand(tracksCmd)
+}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -43,7 +44,7 @@ The transcoded media content-type is required as well`,
 		runWithUI, _ := cmd.Flags().GetBool("with-ui")
 		if runWithUI {
 			go func() {
-				if err := app.Transcode(command, contentType); err != nil {
+				if err := app.Transcode(context.Background(), command, contentType); err != nil {
 					logrus.WithError(err).Fatal("unable to load media")
 				}
 			}()
@@ -55,7 +56,7 @@ The transcoded media content-type is required as well`,
 			return ccui.Run()
 		}
 
-		if err := app.Transcode(command, contentType); err != nil {
+		if err := app.Transcode(context.Background(), command, contentType); err != nil {
 			fmt.Printf("unable to transcode media: %v\n", err)
 			return nil
 		}
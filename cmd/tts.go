@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"crypto/sha1"
 	"fmt"
 	"io/ioutil"
@@ -86,7 +87,7 @@ var ttsCmd = &cobra.Command{
 			defer os.Remove(f.Name())
 		}
 
-		if err := app.Load(f.Name(), "audio/mp3", false, false, false); err != nil {
+		if err := app.Load(context.Background(), f.Name(), "audio/mp3", false, false, false); err != nil {
 			fmt.Printf("unable to load media to device: %v\n", err)
 			return
 		}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var unmuteCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.SetMuted(false); err != nil {
+		if err := app.SetMuted(context.Background(), false); err != nil {
 			fmt.Printf("unable to unmute cast application: %v\n", err)
 			return
 		}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -30,7 +31,7 @@ var unpauseCmd = &cobra.Command{
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		if err := app.Unpause(); err != nil {
+		if err := app.Unpause(context.Background()); err != nil {
 			fmt.Printf("unable to pause cast application: %v\n", err)
 			return
 		}
@@ -17,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
 	castdns "github.com/vishen/go-chromecast/dns"
 	"github.com/vishen/go-chromecast/storage"
 )
@@ -53,7 +54,7 @@ func (e CachedDNSEntry) GetPort() int {
 	return e.Port
 }
 
-func castApplication(cmd *cobra.Command, args []string) (*application.Application, error) {
+func castApplication(cmd *cobra.Command, args []string, opts ...application.ApplicationOption) (*application.Application, error) {
 	deviceName, _ := cmd.Flags().GetString("device-name")
 	deviceUuid, _ := cmd.Flags().GetString("uuid")
 	device, _ := cmd.Flags().GetString("device")
@@ -69,6 +70,16 @@ func castApplication(cmd *cobra.Command, args []string) (*application.Applicatio
 		application.WithDebug(debug),
 		application.WithCacheDisabled(disableCache),
 	}
+	if deviceOnly, _ := cmd.Flags().GetBool("serve-to-device-only"); deviceOnly {
+		applicationOptions = append(applicationOptions, application.WithServeToDeviceOnly(true))
+	}
+	if name, _ := cmd.Flags().GetString("device-profile"); name != "" {
+		profile, err := application.ParseDeviceProfile(name)
+		if err != nil {
+			return nil, err
+		}
+		applicationOptions = append(applicationOptions, application.WithDeviceProfile(profile))
+	}
 
 	// If we need to look on a specific network interface for mdns or
 	// for finding a network ip to host from, ensure that the network
@@ -82,6 +93,14 @@ func castApplication(cmd *cobra.Command, args []string) (*application.Applicatio
 		applicationOptions = append(applicationOptions, application.WithIface(iface))
 	}
 
+	if trustStore, _ := cmd.Flags().GetString("trust-store"); trustStore != "" {
+		roots, err := cast.LoadTrustStore(trustStore)
+		if err != nil {
+			return nil, err
+		}
+		applicationOptions = append(applicationOptions, application.WithTrustStore(roots))
+	}
+
 	var entry castdns.CastDNSEntry
 	// If no address was specified, attempt to determine the address of any
 	// local chromecast devices.
@@ -122,8 +141,8 @@ func castApplication(cmd *cobra.Command, args []string) (*application.Applicatio
 			Port: p,
 		}
 	}
-	app := application.NewApplication(applicationOptions...)
-	if err := app.Start(entry.GetAddr(), entry.GetPort()); err != nil {
+	app := application.NewApplication(append(applicationOptions, opts...)...)
+	if err := app.Start(context.Background(), entry.GetAddr(), entry.GetPort()); err != nil {
 		// NOTE: currently we delete the dns cache every time we get
 		// an error, this is to make sure that if the device gets a new
 		// ipaddress we will invalidate the cache.
@@ -195,3 +214,12 @@ func findCastDNS(iface *net.Interface, dnsTimeoutSeconds int, device, deviceName
 		return foundEntries[i-1], nil
 	}
 }
+
+// mediaPosition describes how far through 'media' the chromecast is, live
+// media has no end so only how long it has played for is known.
+func mediaPosition(media *cast.Media) string {
+	if media.Media.StreamType == cast.StreamTypeLive {
+		return fmt.Sprintf("live, played=%.0fs", media.CurrentTime)
+	}
+	return fmt.Sprintf("time remaining=%.0fs/%.0fs", media.CurrentTime, media.Media.Duration)
+}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -39,13 +40,13 @@ var volumeCmd = &cobra.Command{
 				fmt.Printf("invalid volume: %v\n", err)
 				return
 			}
-			if err = app.SetVolume(float32(newVolume)); err != nil {
+			if err = app.SetVolume(context.Background(), float32(newVolume)); err != nil {
 				fmt.Printf("failed to set volume: %v\n", err)
 				return
 			}
 		}
 
-		if err = app.Update(); err != nil {
+		if err = app.Update(context.Background()); err != nil {
 			fmt.Printf("unable to update cast info: %v\n", err)
 			return
 		}
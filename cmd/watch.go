@@ -15,13 +15,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/buger/jsonparser"
 	"github.com/spf13/cobra"
 
-	pb "github.com/vishen/go-chromecast/cast/proto"
+	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
 )
 
 // watchCmd represents the watch command
@@ -29,37 +32,89 @@ var watchCmd = &cobra.Command{
 	Use:   "watch",
 	Short: "Watch all events sent from a chromecast device",
 	Run: func(cmd *cobra.Command, args []string) {
-		app, err := castApplication(cmd, args)
+		var applicationOptions []application.ApplicationOption
+		if record, _ := cmd.Flags().GetString("record"); record != "" {
+			f, err := os.Create(record)
+			if err != nil {
+				fmt.Printf("unable to create capture file: %v\n", err)
+				return
+			}
+			defer f.Close()
+			applicationOptions = append(applicationOptions, application.WithRecorder(cast.NewCaptureRecorder(f)))
+		}
+
+		app, err := castApplication(cmd, args, applicationOptions...)
 		if err != nil {
 			fmt.Printf("unable to get cast application: %v\n", err)
 			return
 		}
-		go func() {
-			for {
-				if err := app.Update(); err != nil {
-					fmt.Printf("unable to update cast application: %v\n", err)
-					return
-				}
-				castApplication, castMedia, castVolume := app.Status()
-				if castApplication == nil {
-					fmt.Printf("Idle, volume=%0.2f muted=%t\n", castVolume.Level, castVolume.Muted)
-				} else if castApplication.IsIdleScreen {
-					fmt.Printf("Idle (%s), volume=%0.2f muted=%t\n", castApplication.DisplayName, castVolume.Level, castVolume.Muted)
-				} else if castMedia == nil {
-					fmt.Printf("Idle (%s), volume=%0.2f muted=%t\n", castApplication.DisplayName, castVolume.Level, castVolume.Muted)
-				} else {
-					metadata := "unknown"
-					if castMedia.Media.Metadata.Title != "" {
-						md := castMedia.Media.Metadata
-						metadata = fmt.Sprintf("title=%q, artist=%q", md.Title, md.Artist)
-					}
-					fmt.Printf(">> %s (%s), %s, time remaining=%.0fs/%.0fs, volume=%0.2f, muted=%t\n", castApplication.DisplayName, castMedia.PlayerState, metadata, castMedia.CurrentTime, castMedia.Media.Duration, castVolume.Level, castVolume.Muted)
-				}
-				time.Sleep(time.Second * 10)
+		// Subscribe before the first update, so no change is missed.
+		sub := app.Subscribe()
+		defer sub.Unsubscribe()
+
+		if err := app.Update(context.Background()); err != nil {
+			fmt.Printf("unable to update cast application: %v\n", err)
+			return
+		}
+		printWatchStatus(app.Status())
+		watchEvents(app, sub)
+	},
+}
+
+// watchStatusInterval is how often the status is printed, even when
+// nothing has been broadcast.
+const watchStatusInterval = time.Second * 10
+
+// watchEvents prints the status whenever it is broadcast and every
+// 'watchStatusInterval', and every broadcast message, until the connection
+// to the chromecast is lost.
+func watchEvents(app *application.Application, sub *application.Subscription) {
+	// The status is kept from the events rather than asking 'app', which
+	// might have moved on by the time an event is printed.
+	castApplication, castMedia, castVolume := app.Status()
+	ticker := time.NewTicker(watchStatusInterval)
+	defer ticker.Stop()
+	for {
+		var event application.Event
+		select {
+		case <-ticker.C:
+			if err := app.Update(context.Background()); err != nil {
+				fmt.Printf("unable to update cast application: %v\n", err)
+				return
+			}
+			castApplication, castMedia, castVolume = app.Status()
+			printWatchStatus(castApplication, castMedia, castVolume)
+			continue
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
 			}
-		}()
+			event = e
+		}
 
-		app.AddMessageFunc(func(msg *pb.CastMessage) {
+		switch e := event.(type) {
+		case application.ReceiverStatusChanged:
+			for _, a := range e.Applications {
+				a := a
+				castApplication = &a
+			}
+			castVolume = &e.Volume
+			if e.RequestID == 0 {
+				printWatchStatus(castApplication, castMedia, castVolume)
+			}
+		case application.MediaStatusChanged:
+			for _, m := range e.Status {
+				m := m
+				castMedia = &m
+			}
+			if e.RequestID == 0 {
+				printWatchStatus(castApplication, castMedia, castVolume)
+			}
+		case application.Disconnected:
+			fmt.Printf("lost connection to chromecast: %v\n", e.Err)
+			return
+		case application.MessageReceived:
+			msg := e.Message
 			protocolVersion := msg.GetProtocolVersion()
 			sourceID := msg.GetSourceId()
 			destID := msg.GetDestinationId()
@@ -71,18 +126,36 @@ var watchCmd = &cobra.Command{
 			messageType, _ := jsonparser.GetString(payloadBytes, "type")
 			// Only log requests that are broadcasted from the chromecast.
 			if requestID != 0 {
-				return
+				continue
 			}
 
 			fmt.Printf("CHROMECAST BROADCAST MESSAGE: type=%s proto=%s (namespace=%s) %s -> %s | %s\n", messageType, protocolVersion, namespace, sourceID, destID, payload)
-		})
-		// Wait forever
-		c := make(chan bool, 1)
-		<-c
-		return
-	},
+		}
+	}
+}
+
+func printWatchStatus(castApplication *cast.Application, castMedia *cast.Media, castVolume *cast.Volume) {
+	if castVolume == nil {
+		// Nothing has been heard from the receiver yet.
+		castVolume = &cast.Volume{}
+	}
+	if castApplication == nil {
+		fmt.Printf("Idle, volume=%0.2f muted=%t\n", castVolume.Level, castVolume.Muted)
+	} else if castApplication.IsIdleScreen {
+		fmt.Printf("Idle (%s), volume=%0.2f muted=%t\n", castApplication.DisplayName, castVolume.Level, castVolume.Muted)
+	} else if castMedia == nil {
+		fmt.Printf("Idle (%s), volume=%0.2f muted=%t\n", castApplication.DisplayName, castVolume.Level, castVolume.Muted)
+	} else {
+		metadata := "unknown"
+		if castMedia.Media.Metadata.Title != "" {
+			md := castMedia.Media.Metadata
+			metadata = fmt.Sprintf("title=%q, artist=%q", md.Title, md.Artist)
+		}
+		fmt.Printf(">> %s (%s), %s, %s, volume=%0.2f, muted=%t\n", castApplication.DisplayName, castMedia.PlayerState, metadata, mediaPosition(castMedia), castVolume.Level, castVolume.Muted)
+	}
 }
 
 func init() {
+	watchCmd.Flags().String("record", "", "write every message sent and received to this capture file, which can be replayed with 'replay'")
 	rootCmd.AddCommand(watchCmd)
 }
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -11,15 +12,27 @@ import (
 	"time"
 
 	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
 	"github.com/vishen/go-chromecast/dns"
 )
 
+// Number of times a dropped connection to a device is retried before
+// the device is forgotten about.
+const reconnectAttempts = 10
+
 type Handler struct {
 	mu   sync.Mutex
 	apps map[string]*application.Application
 
 	verbose                                                                bool
 	deviceUuid, deviceAddr, devicePort, googleServiceAccount, languageCode string
+
+	// When set, devices have to authenticate before they are used.
+	trustStore *x509.CertPool
+	// When set, local media is only served to the device playing it.
+	serveToDeviceOnly bool
+	// What devices play without transcoding, the default when not set.
+	deviceProfile *application.DeviceProfile
 }
 
 func NewHandler(verbose bool, deviceUuid string, deviceAddr string, devicePort string, googleServiceAccount string, languageCode string) *Handler {
@@ -35,13 +48,46 @@ func NewHandler(verbose bool, deviceUuid string, deviceAddr string, devicePort s
 	}
 }
 
+// SetTrustStore has every device authenticate itself as a genuine cast
+// device, with a device certificate issued by one of 'roots', before it is
+// used.
+func (h *Handler) SetTrustStore(roots *x509.CertPool) {
+	h.trustStore = roots
+}
+
+// SetServeToDeviceOnly only serves local media to the device that is
+// playing it, rather than to anything on the network that has its url.
+func (h *Handler) SetServeToDeviceOnly(deviceOnly bool) {
+	h.serveToDeviceOnly = deviceOnly
+}
+
+// SetDeviceProfile transcodes local media to what 'profile' plays, rather
+// than to what every device plays.
+func (h *Handler) SetDeviceProfile(profile *application.DeviceProfile) {
+	h.deviceProfile = profile
+}
+
+// applicationOptions are the options every application that connects to
+// a device is started with.
+func (h *Handler) applicationOptions() []application.ApplicationOption {
+	return []application.ApplicationOption{
+		application.WithDebug(h.verbose),
+		application.WithCacheDisabled(true),
+		application.WithReconnect(reconnectAttempts),
+		application.WithTrustStore(h.trustStore),
+		application.WithServeToDeviceOnly(h.serveToDeviceOnly),
+		application.WithDeviceProfile(h.deviceProfile),
+	}
+}
+
 func (h *Handler) Serve(addr string) error {
 	h.logAlways("starting http server on %s", addr)
-	h.registerHandlers()
-	return http.ListenAndServe(addr, nil)
+	mux := http.NewServeMux()
+	h.registerHandlers(mux)
+	return http.ListenAndServe(addr, mux)
 }
 
-func (h *Handler) registerHandlers() {
+func (h *Handler) registerHandlers(mux *http.ServeMux) {
 	/*
 		GET /devices
 		POST /connect?uuid=<device_uuid>&addr=<device_addr>&port=<device_port>
@@ -55,29 +101,48 @@ func (h *Handler) registerHandlers() {
 		POST /stop?uuid=<device_uuid>
 		GET /volume?uuid=<device_uuid>
 		POST /volume?uuid=<device_uuid>&volume=<float>
+		POST /speed?uuid=<device_uuid>&rate=<float>
 		POST /rewind?uuid=<device_uuid>&seconds=<int>
 		POST /seek?uuid=<device_uuid>&seconds=<int>
 		POST /seek-to?uuid=<device_uuid>&seconds=<float>
-		POST /load?uuid=<device_uuid>&path=<filepath_or_url>&content_type=<string>
+		POST /load?uuid=<device_uuid>&path=<filepath_or_url>&content_type=<string>&title=<string>&artist=<string>&live=<bool>&start=<duration_or_seconds>&app_id=<string>
+		GET /queue?uuid=<device_uuid>
+		POST /queue/insert?uuid=<device_uuid>&path=<filepath_or_url>[&path=...]&content_type=<string>&insert_before=<item_id>
+		POST /queue/remove?uuid=<device_uuid>&item_id=<item_id>[&item_id=...]
+		POST /queue/reorder?uuid=<device_uuid>&item_id=<item_id>[&item_id=...]&insert_before=<item_id>
+		POST /queue/jump?uuid=<device_uuid>&item_id=<item_id>
+		POST /queue/repeat?uuid=<device_uuid>&mode=<off|all|single|all-and-shuffle>
+		POST /queue/shuffle?uuid=<device_uuid>
+		GET /tracks?uuid=<device_uuid>
+		POST /tracks?uuid=<device_uuid>&track=<int>&subtitles=off&audio=<language>&font_scale=<float>&foreground_color=<#RRGGBBAA>&background_color=<#RRGGBBAA>&edge_type=<string>&edge_color=<#RRGGBBAA>
 		POST /tts {"text":<string>,"deviceUuid":[<string>],"googleServiceAccount":[<string>],"languageCode":[<string>]}
 	*/
 
-	http.HandleFunc("/devices", h.listDevices)
-	http.HandleFunc("/connect", h.connect)
-	http.HandleFunc("/disconnect", h.disconnect)
-	http.HandleFunc("/disconnect-all", h.disconnectAll)
-	http.HandleFunc("/status", h.status)
-	http.HandleFunc("/pause", h.pause)
-	http.HandleFunc("/unpause", h.unpause)
-	http.HandleFunc("/mute", h.mute)
-	http.HandleFunc("/unmute", h.unmute)
-	http.HandleFunc("/stop", h.stop)
-	http.HandleFunc("/volume", h.volume)
-	http.HandleFunc("/rewind", h.rewind)
-	http.HandleFunc("/seek", h.seek)
-	http.HandleFunc("/seek-to", h.seekTo)
-	http.HandleFunc("/load", h.load)
-	http.HandleFunc("/tts", h.tts)
+	mux.HandleFunc("/devices", h.listDevices)
+	mux.HandleFunc("/connect", h.connect)
+	mux.HandleFunc("/disconnect", h.disconnect)
+	mux.HandleFunc("/disconnect-all", h.disconnectAll)
+	mux.HandleFunc("/status", h.status)
+	mux.HandleFunc("/pause", h.pause)
+	mux.HandleFunc("/unpause", h.unpause)
+	mux.HandleFunc("/mute", h.mute)
+	mux.HandleFunc("/unmute", h.unmute)
+	mux.HandleFunc("/stop", h.stop)
+	mux.HandleFunc("/volume", h.volume)
+	mux.HandleFunc("/speed", h.speed)
+	mux.HandleFunc("/rewind", h.rewind)
+	mux.HandleFunc("/seek", h.seek)
+	mux.HandleFunc("/seek-to", h.seekTo)
+	mux.HandleFunc("/load", h.load)
+	mux.HandleFunc("/queue", h.queue)
+	mux.HandleFunc("/queue/insert", h.queueInsert)
+	mux.HandleFunc("/queue/remove", h.queueRemove)
+	mux.HandleFunc("/queue/reorder", h.queueReorder)
+	mux.HandleFunc("/queue/jump", h.queueJump)
+	mux.HandleFunc("/queue/repeat", h.queueRepeat)
+	mux.HandleFunc("/queue/shuffle", h.queueShuffle)
+	mux.HandleFunc("/tracks", h.tracks)
+	mux.HandleFunc("/tts", h.tts)
 }
 
 func (h *Handler) listDevices(w http.ResponseWriter, r *http.Request) {
@@ -123,6 +188,14 @@ func (h *Handler) app(uuid string) (*application.Application, bool) {
 	defer h.mu.Unlock()
 
 	app, ok := h.apps[uuid]
+	if ok && app.Disconnected() {
+		// The device has gone away (rebooted, left the network, ...), so
+		// forget about it and let it be connected to again.
+		h.log("device %q is no longer connected, removing it", uuid)
+		app.Close(false)
+		delete(h.apps, uuid)
+		return nil, false
+	}
 	return app, ok
 }
 
@@ -182,13 +255,8 @@ func (h *Handler) connect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	applicationOptions := []application.ApplicationOption{
-		application.WithDebug(h.verbose),
-		application.WithCacheDisabled(true),
-	}
-
-	app := application.NewApplication(applicationOptions...)
-	if err := app.Start(deviceAddr, devicePortI); err != nil {
+	app := application.NewApplication(h.applicationOptions()...)
+	if err := app.Start(r.Context(), deviceAddr, devicePortI); err != nil {
 		h.log("unable to start application: %v", err)
 		httpError(w, fmt.Errorf("unable to start application: %v", err))
 		return
@@ -276,7 +344,7 @@ func (h *Handler) pause(w http.ResponseWriter, r *http.Request) {
 	}
 	h.log("pausing device")
 
-	if err := app.Pause(); err != nil {
+	if err := app.Pause(r.Context()); err != nil {
 		h.log("unable to pause device: %v", err)
 		httpError(w, fmt.Errorf("unable to pause device: %w", err))
 		return
@@ -290,7 +358,7 @@ func (h *Handler) unpause(w http.ResponseWriter, r *http.Request) {
 	}
 	h.log("unpausing device")
 
-	if err := app.Unpause(); err != nil {
+	if err := app.Unpause(r.Context()); err != nil {
 		h.log("unable to unpause device: %v", err)
 		httpError(w, fmt.Errorf("unable to unpause device: %w", err))
 		return
@@ -304,7 +372,7 @@ func (h *Handler) mute(w http.ResponseWriter, r *http.Request) {
 	}
 	h.log("muting device")
 
-	if err := app.SetMuted(true); err != nil {
+	if err := app.SetMuted(r.Context(), true); err != nil {
 		h.log("unable to mute device: %v", err)
 		httpError(w, fmt.Errorf("unable to mute device: %w", err))
 		return
@@ -318,7 +386,7 @@ func (h *Handler) unmute(w http.ResponseWriter, r *http.Request) {
 	}
 	h.log("unmuting device")
 
-	if err := app.SetMuted(false); err != nil {
+	if err := app.SetMuted(r.Context(), false); err != nil {
 		h.log("unable to unmute device: %v", err)
 		httpError(w, fmt.Errorf("unable to unmute device: %w", err))
 		return
@@ -332,7 +400,7 @@ func (h *Handler) stop(w http.ResponseWriter, r *http.Request) {
 	}
 	h.log("stopping device")
 
-	if err := app.Stop(); err != nil {
+	if err := app.Stop(r.Context()); err != nil {
 		h.log("unable to stop device: %v", err)
 		httpError(w, fmt.Errorf("unable to stop device: %w", err))
 		return
@@ -371,13 +439,42 @@ func (h *Handler) volume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.SetVolume(float32(value)); err != nil {
+	if err := app.SetVolume(r.Context(), float32(value)); err != nil {
 		h.log("unable to set device volume: %v", err)
 		httpError(w, fmt.Errorf("unable to set device volume: %w", err))
 		return
 	}
 }
 
+func (h *Handler) speed(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("setting playback rate for device")
+
+	q := r.URL.Query()
+	rate := q.Get("rate")
+	if rate == "" {
+		httpValidationError(w, "missing 'rate' in query paramater")
+		return
+	}
+
+	value, err := strconv.ParseFloat(rate, 32)
+	if err != nil {
+		h.log("rate %q is not a number: %v", rate, err)
+		httpValidationError(w, "'rate' is not a number")
+		return
+	}
+
+	if err := app.SetPlaybackRate(r.Context(), float32(value)); err != nil {
+		h.log("unable to set device playback rate: %v", err)
+		httpError(w, fmt.Errorf("unable to set device playback rate: %w", err))
+		return
+	}
+}
+
 func (h *Handler) rewind(w http.ResponseWriter, r *http.Request) {
 	app, found := h.appForRequest(w, r)
 	if !found {
@@ -400,7 +497,7 @@ func (h *Handler) rewind(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.Seek(-value); err != nil {
+	if err := app.Seek(r.Context(), -value); err != nil {
 		h.log("unable to rewind device: %v", err)
 		httpError(w, fmt.Errorf("unable to rewind device: %w", err))
 		return
@@ -429,7 +526,7 @@ func (h *Handler) seek(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.Seek(value); err != nil {
+	if err := app.Seek(r.Context(), value); err != nil {
 		h.log("unable to seek device: %v", err)
 		httpError(w, fmt.Errorf("unable to seek device: %w", err))
 		return
@@ -458,7 +555,7 @@ func (h *Handler) seekTo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.SeekToTime(float32(value)); err != nil {
+	if err := app.SeekToTime(r.Context(), float32(value)); err != nil {
 		h.log("unable to seek-to device: %v", err)
 		httpError(w, fmt.Errorf("unable to seek-to device: %w", err))
 		return
@@ -481,14 +578,307 @@ func (h *Handler) load(w http.ResponseWriter, r *http.Request) {
 	}
 
 	contentType := q.Get("content_type")
+	var loadOptions []application.LoadOption
+	if title := q.Get("title"); title != "" {
+		loadOptions = append(loadOptions, application.WithTitle(title))
+	}
+	if artist := q.Get("artist"); artist != "" {
+		loadOptions = append(loadOptions, application.WithArtist(artist))
+	}
+	if start := q.Get("start"); start != "" {
+		seconds, err := application.ParseOffset(start)
+		if err != nil {
+			httpValidationError(w, err.Error())
+			return
+		}
+		loadOptions = append(loadOptions, application.WithStart(seconds))
+	}
+	if appID := q.Get("app_id"); appID != "" {
+		loadOptions = append(loadOptions, application.WithAppID(appID))
+	}
+	if live := q.Get("live"); live != "" {
+		value, err := strconv.ParseBool(live)
+		if err != nil {
+			httpValidationError(w, "'live' must be true or false")
+			return
+		}
+		if value {
+			loadOptions = append(loadOptions, application.WithLive())
+		}
+	}
 
-	if err := app.Load(path, contentType, true, true, true); err != nil {
+	if err := app.Load(r.Context(), path, contentType, true, true, true, loadOptions...); err != nil {
 		h.log("unable to load media for device: %v", err)
 		httpError(w, fmt.Errorf("unable to load media for device: %w", err))
 		return
 	}
 }
 
+func (h *Handler) queue(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("getting queue for device")
+
+	items, err := app.QueueItems(r.Context())
+	if err != nil {
+		h.log("unable to get queue: %v", err)
+		httpError(w, fmt.Errorf("unable to get queue: %w", err))
+		return
+	}
+	var currentItemID int
+	if media := app.Media(); media != nil {
+		currentItemID = media.CurrentItemId
+	}
+	if err := json.NewEncoder(w).Encode(fromQueueItems(items, currentItemID)); err != nil {
+		h.log("error encoding json: %v", err)
+		httpError(w, fmt.Errorf("unable to json encode queue: %v", err))
+	}
+}
+
+func (h *Handler) queueInsert(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("adding to queue for device")
+
+	q := r.URL.Query()
+	paths := q["path"]
+	if len(paths) == 0 {
+		httpValidationError(w, "missing 'path' in query paramater")
+		return
+	}
+	insertBefore, ok := queueItemID(w, q.Get("insert_before"), "insert_before")
+	if !ok {
+		return
+	}
+
+	if err := app.QueueInsert(r.Context(), paths, q.Get("content_type"), true, insertBefore); err != nil {
+		h.log("unable to add to queue: %v", err)
+		httpError(w, fmt.Errorf("unable to add to queue: %w", err))
+		return
+	}
+}
+
+func (h *Handler) queueRemove(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("removing from queue for device")
+
+	itemIDs, ok := queueItemIDs(w, r.URL.Query()["item_id"])
+	if !ok {
+		return
+	}
+
+	if err := app.QueueRemove(r.Context(), itemIDs...); err != nil {
+		h.log("unable to remove from queue: %v", err)
+		httpError(w, fmt.Errorf("unable to remove from queue: %w", err))
+		return
+	}
+}
+
+func (h *Handler) queueReorder(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("reordering queue for device")
+
+	q := r.URL.Query()
+	itemIDs, ok := queueItemIDs(w, q["item_id"])
+	if !ok {
+		return
+	}
+	insertBefore, ok := queueItemID(w, q.Get("insert_before"), "insert_before")
+	if !ok {
+		return
+	}
+
+	if err := app.QueueReorder(r.Context(), itemIDs, insertBefore); err != nil {
+		h.log("unable to reorder queue: %v", err)
+		httpError(w, fmt.Errorf("unable to reorder queue: %w", err))
+		return
+	}
+}
+
+func (h *Handler) queueJump(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("jumping to queue item for device")
+
+	itemIDs, ok := queueItemIDs(w, r.URL.Query()["item_id"])
+	if !ok {
+		return
+	} else if len(itemIDs) != 1 {
+		httpValidationError(w, "requires exactly one 'item_id'")
+		return
+	}
+
+	if err := app.QueueJump(r.Context(), itemIDs[0]); err != nil {
+		h.log("unable to jump to queue item: %v", err)
+		httpError(w, fmt.Errorf("unable to jump to queue item: %w", err))
+		return
+	}
+}
+
+func (h *Handler) queueRepeat(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("changing repeat mode for device")
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		httpValidationError(w, "missing 'mode' in query paramater")
+		return
+	}
+	repeatMode, err := application.ParseRepeatMode(mode)
+	if err != nil {
+		httpValidationError(w, err.Error())
+		return
+	}
+
+	if err := app.SetRepeatMode(r.Context(), repeatMode); err != nil {
+		h.log("unable to change repeat mode: %v", err)
+		httpError(w, fmt.Errorf("unable to change repeat mode: %w", err))
+		return
+	}
+}
+
+func (h *Handler) queueShuffle(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	h.log("shuffling queue for device")
+
+	if err := app.ShuffleQueue(r.Context()); err != nil {
+		h.log("unable to shuffle queue: %v", err)
+		httpError(w, fmt.Errorf("unable to shuffle queue: %w", err))
+		return
+	}
+}
+
+// queueItemIDs parses the 'item_id' query parameters, writing a validation
+// error when they aren't numbers.
+func queueItemIDs(w http.ResponseWriter, values []string) ([]int, bool) {
+	if len(values) == 0 {
+		httpValidationError(w, "missing 'item_id' in query paramater")
+		return nil, false
+	}
+	itemIDs := make([]int, len(values))
+	for i, value := range values {
+		itemID, err := strconv.Atoi(value)
+		if err != nil {
+			httpValidationError(w, "'item_id' is not a number")
+			return nil, false
+		}
+		itemIDs[i] = itemID
+	}
+	return itemIDs, true
+}
+
+// queueItemID parses an optional item id in the query parameter 'name'.
+func queueItemID(w http.ResponseWriter, value, name string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	itemID, err := strconv.Atoi(value)
+	if err != nil {
+		httpValidationError(w, fmt.Sprintf("'%s' is not a number", name))
+		return 0, false
+	}
+	return itemID, true
+}
+
+func (h *Handler) tracks(w http.ResponseWriter, r *http.Request) {
+	app, found := h.appForRequest(w, r)
+	if !found {
+		return
+	}
+
+	if r.Method == "GET" {
+		h.log("getting tracks for device")
+		if err := json.NewEncoder(w).Encode(fromTracks(app.Tracks())); err != nil {
+			h.log("error encoding json: %v", err)
+			httpError(w, fmt.Errorf("unable to json encode tracks: %v", err))
+		}
+		return
+	}
+
+	h.log("changing tracks for device")
+
+	q := r.URL.Query()
+	if track := q.Get("track"); track != "" {
+		trackID, err := strconv.Atoi(track)
+		if err != nil {
+			h.log("track %q is not a number: %v", track, err)
+			httpValidationError(w, "'track' is not a number")
+			return
+		}
+		if err := app.EnableTrack(r.Context(), trackID); err != nil {
+			h.log("unable to switch track: %v", err)
+			httpError(w, fmt.Errorf("unable to switch track: %w", err))
+			return
+		}
+	}
+	if subtitles := q.Get("subtitles"); subtitles != "" {
+		if subtitles != "off" {
+			httpValidationError(w, "'subtitles' can only be 'off', use 'track' to turn them on")
+			return
+		}
+		if err := app.DisableSubtitles(r.Context()); err != nil {
+			h.log("unable to turn off subtitles: %v", err)
+			httpError(w, fmt.Errorf("unable to turn off subtitles: %w", err))
+			return
+		}
+	}
+	if audio := q.Get("audio"); audio != "" {
+		if err := app.SetAudioLanguage(r.Context(), audio); err != nil {
+			h.log("unable to change audio track: %v", err)
+			httpError(w, fmt.Errorf("unable to change audio track: %w", err))
+			return
+		}
+	}
+
+	style := cast.TextTrackStyle{
+		ForegroundColor: q.Get("foreground_color"),
+		BackgroundColor: q.Get("background_color"),
+		EdgeType:        q.Get("edge_type"),
+		EdgeColor:       q.Get("edge_color"),
+	}
+	if fontScale := q.Get("font_scale"); fontScale != "" {
+		value, err := strconv.ParseFloat(fontScale, 32)
+		if err != nil {
+			h.log("font scale %q is not a number: %v", fontScale, err)
+			httpValidationError(w, "'font_scale' is not a number")
+			return
+		}
+		style.FontScale = float32(value)
+	}
+	if style != (cast.TextTrackStyle{}) {
+		if err := app.SetTextTrackStyle(r.Context(), style); err != nil {
+			h.log("unable to change subtitles style: %v", err)
+			httpError(w, fmt.Errorf("unable to change subtitles style: %w", err))
+			return
+		}
+	}
+}
+
 func (h *Handler) appForRequest(w http.ResponseWriter, r *http.Request) (*application.Application, bool) {
 	q := r.URL.Query()
 
@@ -504,7 +894,7 @@ func (h *Handler) appForRequest(w http.ResponseWriter, r *http.Request) (*applic
 		return nil, false
 	}
 
-	app.Update()
+	app.Update(r.Context())
 
 	return app, true
 }
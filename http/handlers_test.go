@@ -0,0 +1,324 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vishen/go-chromecast/cast/casttest"
+)
+
+const testDeviceUUID = "test-device"
+
+func startHandler(t *testing.T) (*Handler, *casttest.Receiver) {
+	t.Helper()
+	receiver, err := casttest.NewReceiver()
+	if err != nil {
+		t.Fatalf("unable to start receiver: %v", err)
+	}
+	t.Cleanup(func() { receiver.Close() })
+
+	h := NewHandler(false, "", "", "", "", "")
+	t.Cleanup(func() { h.disconnectAll(httptest.NewRecorder(), httptest.NewRequest("POST", "/disconnect-all", nil)) })
+
+	addr, port := receiver.Addr()
+	w := doRequest(t, h.connect, "POST", fmt.Sprintf("/connect?uuid=%s&addr=%s&port=%d", testDeviceUUID, addr, port))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unable to connect: %d %s", w.Code, w.Body.String())
+	}
+	return h, receiver
+}
+
+func doRequest(t *testing.T, handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestHandlerConnect(t *testing.T) {
+	h, _ := startHandler(t)
+
+	w := doRequest(t, h.connect, "POST", "/connect?uuid="+testDeviceUUID+"&addr=127.0.0.1&port=8009")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("connecting twice returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = doRequest(t, h.status, "POST", "/status?uuid=unknown")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status of an unknown device returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerLoadAndControl(t *testing.T) {
+	h, receiver := startHandler(t)
+
+	w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/media.mp4&title=Movie&artist=Studio")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unable to load: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+
+	w = doRequest(t, h.status, "POST", "/status?uuid="+testDeviceUUID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unable to get status: %d %s", w.Code, w.Body.String())
+	}
+	var status statusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("unable to decode status: %v", err)
+	}
+	if status.AppID != casttest.DefaultMediaReceiverAppID || status.PlayerState != "PLAYING" {
+		t.Fatalf("unexpected status: %#v", status)
+	}
+	if status.Title != "Movie" || status.Artist != "Studio" {
+		t.Fatalf("status has title %q and artist %q, expected \"Movie\" and \"Studio\"", status.Title, status.Artist)
+	}
+
+	if w := doRequest(t, h.pause, "POST", "/pause?uuid="+testDeviceUUID); w.Code != http.StatusOK {
+		t.Fatalf("unable to pause: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PAUSED")
+
+	if w := doRequest(t, h.unpause, "POST", "/unpause?uuid="+testDeviceUUID); w.Code != http.StatusOK {
+		t.Fatalf("unable to unpause: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+
+	if w := doRequest(t, h.speed, "POST", "/speed?uuid="+testDeviceUUID+"&rate=1.75"); w.Code != http.StatusOK {
+		t.Fatalf("unable to change playback rate: %d %s", w.Code, w.Body.String())
+	}
+	w = doRequest(t, h.status, "POST", "/status?uuid="+testDeviceUUID)
+	status = statusResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("unable to decode status: %v", err)
+	}
+	if status.PlaybackRate != 1.75 {
+		t.Fatalf("status playback rate is %0.2f, expected 1.75", status.PlaybackRate)
+	}
+	if w := doRequest(t, h.speed, "POST", "/speed?uuid="+testDeviceUUID+"&rate=fast"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid playback rate returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerVolume(t *testing.T) {
+	h, receiver := startHandler(t)
+
+	if w := doRequest(t, h.volume, "POST", "/volume?uuid="+testDeviceUUID+"&volume=0.25"); w.Code != http.StatusOK {
+		t.Fatalf("unable to set volume: %d %s", w.Code, w.Body.String())
+	}
+
+	// Fetching the volume updates the application, which is answered after
+	// the receiver has handled the SET_VOLUME.
+	w := doRequest(t, h.volume, "GET", "/volume?uuid="+testDeviceUUID)
+	var volume volumeResponse
+	if err := json.NewDecoder(w.Body).Decode(&volume); err != nil {
+		t.Fatalf("unable to decode volume: %v", err)
+	}
+	if volume.Level != 0.25 {
+		t.Fatalf("volume is %0.2f, expected 0.25", volume.Level)
+	}
+	if volume := receiver.Volume(); volume.Level != 0.25 {
+		t.Fatalf("receiver volume is %0.2f, expected 0.25", volume.Level)
+	}
+
+	if w := doRequest(t, h.volume, "POST", "/volume?uuid="+testDeviceUUID+"&volume=loud"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid volume returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerTracks(t *testing.T) {
+	h, receiver := startHandler(t)
+
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/media.mp4"); w.Code != http.StatusOK {
+		t.Fatalf("unable to load media: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+
+	w := doRequest(t, h.tracks, "GET", "/tracks?uuid="+testDeviceUUID)
+	var tracks tracksResponse
+	if err := json.NewDecoder(w.Body).Decode(&tracks); err != nil {
+		t.Fatalf("unable to decode tracks: %v", err)
+	}
+	if tracks.Tracks == nil || len(tracks.Tracks) != 0 {
+		t.Fatalf("unexpected tracks: %#v", tracks)
+	}
+
+	if w := doRequest(t, h.tracks, "POST", "/tracks?uuid="+testDeviceUUID+"&track=first"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid track returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+	if w := doRequest(t, h.tracks, "POST", "/tracks?uuid="+testDeviceUUID+"&track=1"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("unknown track returned %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+	if w := doRequest(t, h.tracks, "POST", "/tracks?uuid="+testDeviceUUID+"&subtitles=off"); w.Code != http.StatusOK {
+		t.Fatalf("unable to turn off subtitles: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func waitForState(t *testing.T, receiver *casttest.Receiver, state string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 10)
+	for {
+		if media := receiver.Media(); media != nil && media.PlayerState == state {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the media to be %s", state)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestHandlerQueue(t *testing.T) {
+	h, receiver := startHandler(t)
+
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/1.mp3"); w.Code != http.StatusOK {
+		t.Fatalf("unable to load media: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+
+	if w := doRequest(t, h.queueInsert, "POST", "/queue/insert?uuid="+testDeviceUUID+"&path=http://example.com/2.mp3&path=http://example.com/3.mp3"); w.Code != http.StatusOK {
+		t.Fatalf("unable to add to queue: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, h.queueReorder, "POST", "/queue/reorder?uuid="+testDeviceUUID+"&item_id=3&insert_before=1"); w.Code != http.StatusOK {
+		t.Fatalf("unable to reorder queue: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, h.queueRemove, "POST", "/queue/remove?uuid="+testDeviceUUID+"&item_id=2"); w.Code != http.StatusOK {
+		t.Fatalf("unable to remove from queue: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, h.queueJump, "POST", "/queue/jump?uuid="+testDeviceUUID+"&item_id=3"); w.Code != http.StatusOK {
+		t.Fatalf("unable to jump to queue item: %d %s", w.Code, w.Body.String())
+	}
+
+	w := doRequest(t, h.queue, "GET", "/queue?uuid="+testDeviceUUID)
+	var queue queueResponse
+	if err := json.NewDecoder(w.Body).Decode(&queue); err != nil {
+		t.Fatalf("unable to decode queue: %v", err)
+	}
+	expected := []queueItemResponse{
+		{ItemID: 3, ContentID: "http://example.com/3.mp3", ContentType: "audio/mp3", Current: true},
+		{ItemID: 1, ContentID: "http://example.com/1.mp3", ContentType: "audio/mp3"},
+	}
+	if !reflect.DeepEqual(queue.Items, expected) {
+		t.Fatalf("unexpected queue %#v", queue.Items)
+	}
+
+	if w := doRequest(t, h.queueRemove, "POST", "/queue/remove?uuid="+testDeviceUUID+"&item_id=first"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid item id returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := doRequest(t, h.queueRepeat, "POST", "/queue/repeat?uuid="+testDeviceUUID+"&mode=single"); w.Code != http.StatusOK {
+		t.Fatalf("unable to change repeat mode: %d %s", w.Code, w.Body.String())
+	}
+	if mode := receiver.Media().RepeatMode; mode != "REPEAT_SINGLE" {
+		t.Fatalf("receiver repeat mode is %q, expected REPEAT_SINGLE", mode)
+	}
+	if w := doRequest(t, h.queueRepeat, "POST", "/queue/repeat?uuid="+testDeviceUUID+"&mode=sometimes"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid repeat mode returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerLoadLive(t *testing.T) {
+	h, receiver := startHandler(t)
+
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/radio.mp3&live=maybe"); w.Code != http.StatusBadRequest {
+		t.Fatalf("loading with an invalid live returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/radio.mp3&live=true"); w.Code != http.StatusOK {
+		t.Fatalf("unable to load: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+
+	w := doRequest(t, h.status, "POST", "/status?uuid="+testDeviceUUID)
+	var status statusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("unable to decode status: %v", err)
+	}
+	if !status.Live || status.Duration != 0 {
+		t.Fatalf("status is live=%t with duration %0.2f, expected a live stream", status.Live, status.Duration)
+	}
+}
+
+func TestHandlerLoadWithStart(t *testing.T) {
+	h, receiver := startHandler(t)
+
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/media.mp4&start=soon"); w.Code != http.StatusBadRequest {
+		t.Fatalf("loading with an invalid start returned %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path=http://example.com/media.mp4&start=1m30s&app_id=ABCD1234"); w.Code != http.StatusOK {
+		t.Fatalf("unable to load: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+
+	w := doRequest(t, h.status, "POST", "/status?uuid="+testDeviceUUID)
+	var status statusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("unable to decode status: %v", err)
+	}
+	if status.AppID != "ABCD1234" || status.CurrentTime != 90 {
+		t.Fatalf("status has app %s at %.0fs, expected ABCD1234 at 90s", status.AppID, status.CurrentTime)
+	}
+}
+
+func TestHandlerTTSServeToDeviceOnly(t *testing.T) {
+	receiver, err := casttest.NewReceiver()
+	if err != nil {
+		t.Fatalf("unable to start receiver: %v", err)
+	}
+	t.Cleanup(func() { receiver.Close() })
+	h := NewHandler(false, "", "", "", "", "")
+	h.SetServeToDeviceOnly(true)
+	t.Cleanup(func() { h.disconnectAll(httptest.NewRecorder(), httptest.NewRequest("POST", "/disconnect-all", nil)) })
+
+	addr, port := receiver.Addr()
+	if _, ok := getOrConnectApp(context.Background(), testDeviceUUID, addr, strconv.Itoa(port), h); !ok {
+		t.Fatal("unable to connect for text to speech")
+	}
+
+	dir, err := ioutil.TempDir("", "go-chromecast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename := filepath.Join(dir, "movie.mp4")
+	if err := ioutil.WriteFile(filename, []byte("movie"), 0644); err != nil {
+		t.Fatalf("unable to write media: %v", err)
+	}
+	if w := doRequest(t, h.load, "POST", "/load?uuid="+testDeviceUUID+"&path="+url.QueryEscape(filename)); w.Code != http.StatusOK {
+		t.Fatalf("unable to load: %d %s", w.Code, w.Body.String())
+	}
+	waitForState(t, receiver, "PLAYING")
+	contentURL := receiver.Media().Media.ContentId
+
+	// The receiver is on 127.0.0.1, anything else on the loopback network
+	// isn't the device.
+	other := &http.Client{Transport: &http.Transport{
+		DialContext: (&net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}}).DialContext,
+	}}
+	resp, err := other.Get(contentURL)
+	if err != nil {
+		t.Fatalf("unable to fetch media: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("serving another client returned %d, expected %d", resp.StatusCode, http.StatusForbidden)
+	}
+	resp, err = http.Get(contentURL)
+	if err != nil {
+		t.Fatalf("unable to fetch media: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("serving the device returned %d, expected %d", resp.StatusCode, http.StatusOK)
+	}
+}
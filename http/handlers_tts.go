@@ -62,9 +62,9 @@ func (h *Handler) tts(w http.ResponseWriter, r *http.Request) {
 		payload.LanguageCode = h.languageCode
 	}
 
-	app, ok := getOrConnectApp(payload.DeviceUuid, payload.DeviceAddr, payload.DevicePort, h)
+	app, ok := getOrConnectApp(r.Context(), payload.DeviceUuid, payload.DeviceAddr, payload.DevicePort, h)
 	if ok {
-		play(app, &payload, w)
+		play(r.Context(), app, &payload, w)
 	} else {
 		httpValidationError(w, "device uuid is not found")
 		return
@@ -72,15 +72,15 @@ func (h *Handler) tts(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func getOrConnectApp(deviceUUID string, deviceAddr string, devicePort string, h *Handler) (*application.Application, bool) {
+func getOrConnectApp(ctx context.Context, deviceUUID string, deviceAddr string, devicePort string, h *Handler) (*application.Application, bool) {
 	app, ok := h.app(deviceUUID)
 	if ok {
 		return app, ok
 	} else {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		discoverCtx, cancel := context.WithTimeout(ctx, time.Second*3)
 		defer cancel()
 
-		devicesChan, err := dns.DiscoverCastDNSEntriesWithIpType(ctx, nil, zeroconf.IPv4)
+		devicesChan, err := dns.DiscoverCastDNSEntriesWithIpType(discoverCtx, nil, zeroconf.IPv4)
 		if err != nil {
 			h.log("error discovering entries: %v", err)
 		} else {
@@ -110,13 +110,8 @@ func getOrConnectApp(deviceUUID string, deviceAddr string, devicePort string, h
 			return nil, false
 		}
 
-		applicationOptions := []application.ApplicationOption{
-			application.WithDebug(h.verbose),
-			application.WithCacheDisabled(true),
-		}
-
-		app := application.NewApplication(applicationOptions...)
-		if err := app.Start(deviceAddr, devicePortI); err != nil {
+		app := application.NewApplication(h.applicationOptions()...)
+		if err := app.Start(ctx, deviceAddr, devicePortI); err != nil {
 			h.logAlways("unable to start application: %v", err)
 			return nil, false
 		}
@@ -127,7 +122,7 @@ func getOrConnectApp(deviceUUID string, deviceAddr string, devicePort string, h
 	}
 }
 
-func play(app *application.Application, payload *TTSPayload, w http.ResponseWriter) {
+func play(ctx context.Context, app *application.Application, payload *TTSPayload, w http.ResponseWriter) {
 
 	fmt.Printf("play cache=%v text=%s\n", payload.Cache, payload.Text)
 
@@ -168,7 +163,7 @@ func play(app *application.Application, payload *TTSPayload, w http.ResponseWrit
 		}()
 	}
 
-	if err := app.Load(f.Name(), "audio/mp3", false, false, false); err != nil {
+	if err := app.Load(ctx, f.Name(), "audio/mp3", false, false, false); err != nil {
 		httpValidationError(w, fmt.Sprintf("unable to load media to device: %v\n", err))
 		return
 	}
@@ -15,6 +15,64 @@ type volumeResponse struct {
 	Muted bool    `json:"muted"`
 }
 
+type queueResponse struct {
+	Items []queueItemResponse `json:"items"`
+}
+
+type queueItemResponse struct {
+	ItemID      int    `json:"item_id"`
+	ContentID   string `json:"content_id"`
+	ContentType string `json:"content_type"`
+	Title       string `json:"title"`
+	Current     bool   `json:"current"`
+}
+
+func fromQueueItems(items []cast.QueueLoadItem, currentItemID int) queueResponse {
+	response := queueResponse{Items: []queueItemResponse{}}
+	for _, item := range items {
+		response.Items = append(response.Items, queueItemResponse{
+			ItemID:      item.ItemId,
+			ContentID:   item.Media.ContentId,
+			ContentType: item.Media.ContentType,
+			Title:       item.Media.Metadata.Title,
+			Current:     item.ItemId == currentItemID,
+		})
+	}
+	return response
+}
+
+type tracksResponse struct {
+	Tracks []trackResponse `json:"tracks"`
+}
+
+type trackResponse struct {
+	TrackID  int    `json:"track_id"`
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Active   bool   `json:"active"`
+}
+
+func fromTracks(tracks []cast.MediaTrack, activeTrackIDs []int) tracksResponse {
+	response := tracksResponse{Tracks: []trackResponse{}}
+	for _, track := range tracks {
+		active := false
+		for _, id := range activeTrackIDs {
+			active = active || id == track.TrackId
+		}
+		response.Tracks = append(response.Tracks, trackResponse{
+			TrackID:  track.TrackId,
+			Type:     track.Type,
+			Subtype:  track.Subtype,
+			Name:     track.Name,
+			Language: track.Language,
+			Active:   active,
+		})
+	}
+	return response
+}
+
 type statusResponse struct {
 	AppID        string `json:"app_id"`
 	DisplayName  string `json:"display_name"`
@@ -26,11 +84,14 @@ type statusResponse struct {
 	IdleReason    string  `json:"idle_reason"`
 	CurrentItemID int     `json:"current_item_id"`
 	LoadingItemID int     `json:"loading_item_id"`
+	RepeatMode    string  `json:"repeat_mode"`
+	PlaybackRate  float32 `json:"playback_rate"`
 
 	ContentID   string  `json: "content_id"`
 	ContentType string  `json: "content_type"`
 	StreamType  string  `json: "stream_type"`
 	Duration    float32 `json: "duration"`
+	Live        bool    `json:"live"`
 
 	Artist   string `json:"artist"`
 	Title    string `json:"title"`
@@ -60,6 +121,12 @@ func fromApplicationStatus(app *cast.Application, media *cast.Media, volume *cas
 		status.PlayerState = media.PlayerState
 		status.CurrentTime = media.CurrentTime
 		status.IdleReason = media.IdleReason
+		status.RepeatMode = media.RepeatMode
+		status.PlaybackRate = media.PlaybackRate
+		if status.PlaybackRate == 0 {
+			// Receivers that can't change the rate don't report it.
+			status.PlaybackRate = 1
+		}
 		status.CurrentItemID = media.CurrentItemId
 		status.LoadingItemID = media.LoadingItemId
 		status.MediaSessionID = media.MediaSessionId
@@ -71,6 +138,12 @@ func fromApplicationStatus(app *cast.Application, media *cast.Media, volume *cas
 		status.ContentType = media.Media.ContentType
 		status.StreamType = media.Media.StreamType
 		status.Duration = media.Media.Duration
+		if media.Media.StreamType == cast.StreamTypeLive {
+			// Receivers report a duration for some live streams, but
+			// there is no end to it.
+			status.Live = true
+			status.Duration = 0
+		}
 
 		status.Artist = media.Media.Metadata.Artist
 		status.Title = media.Media.Metadata.Title
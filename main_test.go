@@ -1,10 +1,15 @@
 package main
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/rogpeppe/go-internal/testscript"
+
+	"github.com/vishen/go-chromecast/cast/casttest"
 )
 
 func TestMain(m *testing.M) {
@@ -16,5 +21,25 @@ func TestMain(m *testing.M) {
 func TestCommands(t *testing.T) {
 	testscript.Run(t, testscript.Params{
 		Dir: "testdata",
+		Setup: func(env *testscript.Env) error {
+			// Every script gets its own fake chromecast, reachable
+			// with '-a $CAST_ADDR -p $CAST_PORT'.
+			receiver, err := casttest.NewReceiver()
+			if err != nil {
+				return err
+			}
+			env.Defer(func() { receiver.Close() })
+			addr, port := receiver.Addr()
+			env.Vars = append(env.Vars, "CAST_ADDR="+addr, "CAST_PORT="+strconv.Itoa(port))
+
+			// The CA that issued the fake chromecast's device
+			// certificate, for '--trust-store $CAST_TRUST_STORE'.
+			trustStore := filepath.Join(env.WorkDir, "cast-trust-store.pem")
+			if err := ioutil.WriteFile(trustStore, casttest.TrustStorePEM(), 0600); err != nil {
+				return err
+			}
+			env.Vars = append(env.Vars, "CAST_TRUST_STORE="+trustStore)
+			return nil
+		},
 	})
 }
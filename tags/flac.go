@@ -0,0 +1,122 @@
+package tags
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+// readFLAC reads the Vorbis comments and pictures in the flac metadata
+// blocks, 'r' is just after the "fLaC" marker.
+// https://xiph.org/flac/format.html#metadata_block
+func readFLAC(r io.Reader) (*Tags, error) {
+	t := &Tags{}
+	header := make([]byte, 4)
+	for last := false; !last; {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, errors.Wrap(err, "unable to read flac metadata block")
+		}
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if blockType != flacVorbisComment && blockType != flacPicture {
+			if _, err := io.CopyN(ioutil.Discard, r, size); err != nil {
+				return nil, errors.Wrap(err, "unable to read flac metadata block")
+			}
+			continue
+		}
+
+		block := make([]byte, size)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, errors.Wrap(err, "unable to read flac metadata block")
+		}
+		if blockType == flacVorbisComment {
+			comments := vorbisComments(block)
+			comments.Picture = t.Picture
+			t = comments
+		} else {
+			t.setPicture(flacPictureBlock(block))
+		}
+	}
+	if t.empty() {
+		return nil, ErrNoTags
+	}
+	return t, nil
+}
+
+// flacPictureBlock decodes a picture block, every number in it is a big
+// endian 32 bit integer.
+func flacPictureBlock(b []byte) *Picture {
+	p := &Picture{}
+	next := func() []byte {
+		if len(b) < 4 {
+			return nil
+		}
+		size := binary.BigEndian.Uint32(b)
+		if uint64(size) > uint64(len(b)-4) {
+			b = nil
+			return nil
+		}
+		s := b[4 : 4+size]
+		b = b[4+size:]
+		return s
+	}
+	if len(b) < 4 {
+		return p
+	}
+	p.pictureType = int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	p.MIMEType = string(next())
+	// Skip the description, then the width, height, colour depth and
+	// number of colours.
+	next()
+	if len(b) < 16 {
+		return p
+	}
+	b = b[16:]
+	p.Data = next()
+	return p
+}
+
+// vorbisComments reads the "KEY=value" comments in a Vorbis comment
+// block, every length in it is little endian.
+// https://xiph.org/vorbis/doc/v-comment.html
+func vorbisComments(b []byte) *Tags {
+	t := &Tags{}
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		size := binary.LittleEndian.Uint32(b)
+		if uint64(size) > uint64(len(b)-4) {
+			return "", false
+		}
+		s := string(b[4 : 4+size])
+		b = b[4+size:]
+		return s, true
+	}
+	// Skip the vendor.
+	if _, ok := next(); !ok || len(b) < 4 {
+		return t
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+		if eq := strings.IndexByte(comment, '='); eq > 0 {
+			t.set(comment[:eq], comment[eq+1:])
+		}
+	}
+	return t
+}
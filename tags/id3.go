@@ -0,0 +1,256 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// id3v2Frames are the text frames we read, by the name they have in
+// ID3v2.2 and in ID3v2.3 and later.
+var id3v2Frames = map[string]string{
+	"TT2": "title", "TIT2": "title",
+	"TP1": "artist", "TPE1": "artist",
+	"TAL": "album", "TALB": "album",
+	"TP2": "albumartist", "TPE2": "albumartist",
+	"TCM": "composer", "TCOM": "composer",
+	"TRK": "track", "TRCK": "track",
+	"TPA": "disc", "TPOS": "disc",
+	// ID3v2.4 replaced the year with the recording time.
+	"TDRC": "date", "TDRL": "date",
+	"TYE": "year", "TYER": "year",
+}
+
+// readID3v2 reads the ID3v2 tag at the start of 'r', leaving 'r' at the
+// end of the tag.
+// https://id3.org/id3v2.4.0-structure
+func readID3v2(r io.Reader) (*Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Wrap(err, "unable to read ID3 header")
+	}
+	version, flags := header[3], header[5]
+	data := make([]byte, syncsafe(header[6:10]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.Wrap(err, "unable to read ID3 tag")
+	}
+	if version < 2 || version > 4 {
+		return &Tags{}, nil
+	}
+	// Before ID3v2.4 the whole tag is unsynchronised, after it each frame
+	// is.
+	if flags&0x80 != 0 && version < 4 {
+		data = unsynchronise(data)
+	}
+	if flags&0x40 != 0 && version > 2 && len(data) >= 4 {
+		// Skip the extended header.
+		size := int(binary.BigEndian.Uint32(data))
+		if version == 3 {
+			size += 4
+		} else {
+			size = syncsafe(data[:4])
+		}
+		if size > len(data) {
+			return &Tags{}, nil
+		}
+		data = data[size:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+	t := &Tags{}
+	for len(data) >= headerSize && data[0] != 0 {
+		id := string(data[:idSize])
+		var size int
+		var frameFlags byte
+		switch version {
+		case 2:
+			size = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+			frameFlags = data[9]
+		case 4:
+			size = syncsafe(data[4:8])
+			frameFlags = data[9]
+		}
+		if size > len(data)-headerSize {
+			break
+		}
+		frame := data[headerSize : headerSize+size]
+		data = data[headerSize+size:]
+
+		key, ok := id3v2Frames[id]
+		picture := id == "APIC" || id == "PIC"
+		if !ok && !picture {
+			continue
+		}
+		if version == 3 {
+			// Skip compressed and encrypted frames, and the group byte.
+			if frameFlags&0xc0 != 0 {
+				continue
+			}
+			if frameFlags&0x20 != 0 && len(frame) > 0 {
+				frame = frame[1:]
+			}
+		} else if version == 4 {
+			if frameFlags&0x0c != 0 {
+				continue
+			}
+			if frameFlags&0x40 != 0 && len(frame) > 0 {
+				frame = frame[1:]
+			}
+			if frameFlags&0x02 != 0 {
+				frame = unsynchronise(frame)
+			}
+			if frameFlags&0x01 != 0 && len(frame) >= 4 {
+				frame = frame[4:]
+			}
+		}
+		if picture {
+			t.setPicture(id3Picture(version, frame))
+		} else {
+			t.set(key, id3Text(frame))
+		}
+	}
+	return t, nil
+}
+
+// readID3v1 reads the ID3v1 tag in the last 128 bytes of 'r'.
+func readID3v1(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		// The file is too small to have one.
+		return nil, ErrNoTags
+	}
+	data := make([]byte, 128)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.Wrap(err, "unable to read ID3v1 tag")
+	}
+	if !bytes.HasPrefix(data, []byte("TAG")) {
+		return nil, ErrNoTags
+	}
+	field := func(b []byte) string {
+		return strings.TrimRight(latin1(b), "\x00 ")
+	}
+	t := &Tags{
+		Title:  field(data[3:33]),
+		Artist: field(data[33:63]),
+		Album:  field(data[63:93]),
+		Date:   field(data[93:97]),
+	}
+	// ID3v1.1 has the track number at the end of the comment.
+	if data[125] == 0 && data[126] != 0 {
+		t.TrackNumber = int(data[126])
+	}
+	if t.empty() {
+		return nil, ErrNoTags
+	}
+	return t, nil
+}
+
+// id3Text decodes the first string in a text frame.
+func id3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+	encoding, text := frame[0], frame[1:]
+	var s string
+	switch encoding {
+	case 0:
+		s = latin1(text)
+	case 1:
+		// UTF-16 with a byte order mark.
+		if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+			s = utf16String(text[2:], binary.BigEndian)
+		} else if len(text) >= 2 && text[0] == 0xff && text[1] == 0xfe {
+			s = utf16String(text[2:], binary.LittleEndian)
+		} else {
+			s = utf16String(text, binary.LittleEndian)
+		}
+	case 2:
+		s = utf16String(text, binary.BigEndian)
+	default:
+		s = string(text)
+	}
+	// ID3v2.4 separates multiple values with a null.
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// id3Picture decodes an attached picture frame, ID3v2.2 gives the image
+// format rather than its MIME type.
+func id3Picture(version byte, frame []byte) *Picture {
+	p := &Picture{}
+	if len(frame) == 0 {
+		return p
+	}
+	encoding, b := frame[0], frame[1:]
+	if version == 2 {
+		if len(b) < 3 {
+			return p
+		}
+		switch strings.ToUpper(string(b[:3])) {
+		case "JPG":
+			p.MIMEType = "image/jpeg"
+		case "PNG":
+			p.MIMEType = "image/png"
+		}
+		b = b[3:]
+	} else {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return p
+		}
+		p.MIMEType, b = latin1(b[:i]), b[i+1:]
+	}
+	if len(b) == 0 {
+		return p
+	}
+	p.pictureType, b = int(b[0]), b[1:]
+
+	// Skip the description, UTF-16 strings end with two zero bytes.
+	terminator := 1
+	if encoding == 1 || encoding == 2 {
+		terminator = 2
+	}
+	for i := 0; i+terminator <= len(b); i += terminator {
+		if b[i] == 0 && b[i+terminator-1] == 0 {
+			p.Data = b[i+terminator:]
+			break
+		}
+	}
+	return p
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func utf16String(b []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// syncsafe decodes a 28 bit integer stored in the low 7 bits of 4 bytes.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// unsynchronise removes the zero bytes inserted after each 0xff.
+func unsynchronise(b []byte) []byte {
+	return bytes.Replace(b, []byte{0xff, 0x00}, []byte{0xff}, -1)
+}
@@ -0,0 +1,185 @@
+package tags
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// mp4Atoms are the iTunes metadata atoms we read.
+var mp4Atoms = map[string]string{
+	"\xa9nam": "title",
+	"\xa9ART": "artist",
+	"\xa9alb": "album",
+	"aART":    "albumartist",
+	"\xa9wrt": "composer",
+	"\xa9day": "date",
+	"tvsh":    "show",
+}
+
+// readMP4 reads the iTunes metadata in moov/udta/meta/ilst.
+// https://developer.apple.com/library/archive/documentation/QuickTime/QTFF/Metadata/Metadata.html
+func readMP4(r io.ReadSeeker) (*Tags, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	moovStart, moovEnd, err := findBox(r, 0, end, "moov")
+	if err != nil {
+		return nil, err
+	}
+	udtaStart, udtaEnd, err := findBox(r, moovStart, moovEnd, "udta")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(udtaStart, io.SeekStart); err != nil {
+		return nil, err
+	}
+	// udta is small, other than any cover art, so read it all.
+	udta, err := ioutil.ReadAll(io.LimitReader(r, udtaEnd-udtaStart))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read udta box")
+	}
+
+	meta, ok := childBox(udta, "meta")
+	if !ok {
+		return nil, ErrNoTags
+	}
+	// meta is a full box with a version and flags, except in QuickTime
+	// files where it is followed straight away by the hdlr box.
+	if len(meta) >= 8 && string(meta[4:8]) != "hdlr" {
+		meta = meta[4:]
+	}
+	ilst, ok := childBox(meta, "ilst")
+	if !ok {
+		return nil, ErrNoTags
+	}
+
+	t := &Tags{}
+	for _, item := range boxes(ilst) {
+		data, ok := childBox(item.data, "data")
+		// The data box starts with its type and locale.
+		if !ok || len(data) < 8 {
+			continue
+		}
+		dataType, value := binary.BigEndian.Uint32(data[:4])&0xffffff, data[8:]
+		switch item.name {
+		case "trkn", "disk":
+			// Reserved, number and total, as 16 bit integers.
+			if len(value) < 4 {
+				continue
+			}
+			n := int(binary.BigEndian.Uint16(value[2:4]))
+			if item.name == "trkn" {
+				t.TrackNumber = n
+			} else {
+				t.DiscNumber = n
+			}
+		case "covr":
+			var mimeType string
+			switch dataType {
+			case 13:
+				mimeType = "image/jpeg"
+			case 14:
+				mimeType = "image/png"
+			case 27:
+				mimeType = "image/bmp"
+			}
+			// There are no types of picture, the first is the cover.
+			t.setPicture(&Picture{MIMEType: mimeType, Data: value, pictureType: pictureFrontCover})
+		case "tvsn", "tves":
+			n := mp4Int(value)
+			if item.name == "tvsn" {
+				t.Season = n
+			} else {
+				t.Episode = n
+			}
+		default:
+			// Only UTF-8 text.
+			if key, ok := mp4Atoms[item.name]; ok && dataType == 1 {
+				t.set(key, string(value))
+			}
+		}
+	}
+	return t, nil
+}
+
+// findBox returns where the contents of the box 'name', between 'start'
+// and 'end' of 'r', start and end.
+func findBox(r io.ReadSeeker, start, end int64, name string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return 0, 0, errors.Wrap(err, "unable to read box header")
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch size {
+		case 0:
+			// The box runs to the end of the file.
+			size = end - offset
+		case 1:
+			// The size is a 64 bit integer after the name.
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return 0, 0, errors.Wrap(err, "unable to read box size")
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if size < headerSize || offset+size > end {
+			break
+		}
+		if string(header[4:8]) == name {
+			return offset + headerSize, offset + size, nil
+		}
+		offset += size
+	}
+	return 0, 0, ErrNoTags
+}
+
+type box struct {
+	name string
+	data []byte
+}
+
+// boxes splits 'b' into the boxes in it.
+func boxes(b []byte) []box {
+	var found []box
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b))
+		if size < 8 || size > len(b) {
+			break
+		}
+		found = append(found, box{name: string(b[4:8]), data: b[8:size]})
+		b = b[size:]
+	}
+	return found
+}
+
+// childBox returns the contents of the first box 'name' in 'b'.
+func childBox(b []byte, name string) ([]byte, bool) {
+	for _, child := range boxes(b) {
+		if child.name == name {
+			return child.data, true
+		}
+	}
+	return nil, false
+}
+
+// mp4Int decodes a big endian integer of 1, 2, 4 or 8 bytes.
+func mp4Int(b []byte) int {
+	switch len(b) {
+	case 1:
+		return int(b[0])
+	case 2:
+		return int(binary.BigEndian.Uint16(b))
+	case 4:
+		return int(binary.BigEndian.Uint32(b))
+	case 8:
+		return int(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+// Package tags reads the title, artist, album and other tags stored in
+// media files. ID3 (mp3), MP4 atoms (mp4, m4a, m4v) and Vorbis comments
+// (flac) are read directly, anything else is read with ffprobe when it is
+// installed.
+package tags
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoTags is returned when a file has no tags that can be read.
+var ErrNoTags = errors.New("no tags found")
+
+// Tags describe what is in a media file.
+type Tags struct {
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Composer    string
+	TrackNumber int
+	DiscNumber  int
+	// Date is the release date as it is in the file, ie: "2006" or
+	// "2006-05-17".
+	Date string
+
+	// Show, Season and Episode are set for episodes of a TV show.
+	Show    string
+	Season  int
+	Episode int
+
+	// Picture is the cover art in the file, the front cover when there
+	// are several pictures.
+	Picture *Picture
+}
+
+// Picture is an image embedded in a media file.
+type Picture struct {
+	MIMEType string
+	Data     []byte
+
+	// What the picture is of, ID3 and flac number them the same way.
+	pictureType int
+}
+
+const pictureFrontCover = 3
+
+// setPicture keeps 'p' as the picture unless there already is one, the
+// front cover is kept over any other picture.
+func (t *Tags) setPicture(p *Picture) {
+	if len(p.Data) == 0 {
+		return
+	}
+	if t.Picture == nil || p.pictureType == pictureFrontCover && t.Picture.pictureType != pictureFrontCover {
+		t.Picture = p
+	}
+}
+
+func (t *Tags) empty() bool {
+	return *t == Tags{}
+}
+
+// Read returns the tags in 'filename', or ErrNoTags if it has none.
+func Read(filename string) (*Tags, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := read(f)
+	if err != nil && err != ErrNoTags {
+		return nil, err
+	}
+	if t == nil || t.empty() {
+		// Fall back to ffprobe for the formats we don't know, or don't
+		// find anything in.
+		return readFFProbe(filename)
+	}
+	return t, nil
+}
+
+func read(r io.ReadSeeker) (*Tags, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNoTags
+		}
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		t, err := readID3v2(r)
+		if err != nil {
+			return nil, err
+		}
+		if !t.empty() {
+			return t, nil
+		}
+		// Some flac files start with an ID3 tag, the flac metadata follows
+		// it.
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err == nil && string(marker[:]) == "fLaC" {
+			return readFLAC(r)
+		}
+		return readID3v1(r)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		if _, err := r.Seek(4, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return readFLAC(r)
+	case string(header[4:8]) == "ftyp":
+		return readMP4(r)
+	}
+	return readID3v1(r)
+}
+
+// set sets the field known by 'key' to 'value', the keys are the ones
+// used by Vorbis comments and ffprobe.
+func (t *Tags) set(key, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	switch strings.ToLower(key) {
+	case "title":
+		t.Title = value
+	case "artist":
+		t.Artist = value
+	case "album":
+		t.Album = value
+	case "albumartist", "album_artist", "album artist":
+		t.AlbumArtist = value
+	case "composer":
+		t.Composer = value
+	case "tracknumber", "track":
+		t.TrackNumber = number(value)
+	case "discnumber", "disc":
+		t.DiscNumber = number(value)
+	case "date", "year":
+		if t.Date == "" {
+			t.Date = value
+		}
+	case "show":
+		t.Show = value
+	case "season_number":
+		t.Season = number(value)
+	case "episode_sort":
+		t.Episode = number(value)
+	}
+}
+
+// number returns the leading number in 'value', tracks and discs are
+// often given as "3/12".
+func number(value string) int {
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		value = value[:i]
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
+
+func readFFProbe(filename string) (*Tags, error) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return nil, ErrNoTags
+	}
+	out, err := exec.Command(ffprobe, "-v", "quiet", "-print_format", "json", "-show_format", filename).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to run ffprobe")
+	}
+	var probe struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, errors.Wrap(err, "unable to decode ffprobe output")
+	}
+	t := &Tags{}
+	for key, value := range probe.Format.Tags {
+		t.set(key, value)
+	}
+	if t.empty() {
+		return nil, ErrNoTags
+	}
+	return t, nil
+}
@@ -0,0 +1,257 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func id3v2(version byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	// Padding.
+	body = append(body, make([]byte, 16)...)
+	size := len(body)
+	header := []byte{'I', 'D', '3', version, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, body...)
+}
+
+func id3Frame(version byte, id string, encoding byte, text string) []byte {
+	data := []byte{encoding}
+	switch encoding {
+	case 1:
+		data = append(data, 0xff, 0xfe)
+		for _, u := range utf16.Encode([]rune(text)) {
+			data = append(data, byte(u), byte(u>>8))
+		}
+	default:
+		data = append(data, text...)
+	}
+	return id3RawFrame(version, id, data)
+}
+
+func id3RawFrame(version byte, id string, data []byte) []byte {
+	size := len(data)
+	var header []byte
+	switch version {
+	case 2:
+		header = []byte{id[0], id[1], id[2], byte(size >> 16), byte(size >> 8), byte(size)}
+	case 3:
+		header = append([]byte(id), byte(size>>24), byte(size>>16), byte(size>>8), byte(size), 0, 0)
+	case 4:
+		header = append([]byte(id), byte(size>>21&0x7f), byte(size>>14&0x7f), byte(size>>7&0x7f), byte(size&0x7f), 0, 0)
+	}
+	return append(header, data...)
+}
+
+func id3v1(title, artist, album, year string, track byte) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	copy(tag[33:63], artist)
+	copy(tag[63:93], album)
+	copy(tag[93:97], year)
+	tag[126] = track
+	return tag
+}
+
+func mp4Box(name string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], name)
+	return append(b, body...)
+}
+
+func mp4Data(dataType uint32, value []byte) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, dataType)
+	return mp4Box("data", append(header, value...))
+}
+
+func flacBlock(blockType byte, last bool, data []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	size := len(data)
+	return append([]byte{blockType, byte(size >> 16), byte(size >> 8), byte(size)}, data...)
+}
+
+func id3PictureFrame(pictureType byte, data string) []byte {
+	frame := append([]byte{1}, "image/png\x00"...)
+	frame = append(frame, pictureType)
+	// A UTF-16 description.
+	frame = append(frame, 0xff, 0xfe, 'x', 0, 0, 0)
+	return id3RawFrame(3, "APIC", append(frame, data...))
+}
+
+func flacPictureData(data string) []byte {
+	var b []byte
+	add := func(n int) {
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(n))
+		b = append(b, size...)
+	}
+	add(pictureFrontCover)
+	add(len("image/jpeg"))
+	b = append(b, "image/jpeg"...)
+	add(len("cover"))
+	b = append(b, "cover"...)
+	add(500)
+	add(500)
+	add(24)
+	add(0)
+	add(len(data))
+	b = append(b, data...)
+	return flacBlock(6, false, b)
+}
+
+func vorbisComment(comments ...string) []byte {
+	var b []byte
+	add := func(s string) {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(s)))
+		b = append(append(b, size...), s...)
+	}
+	add("test vendor")
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, uint32(len(comments)))
+	b = append(b, count...)
+	for _, c := range comments {
+		add(c)
+	}
+	return b
+}
+
+func TestRead(t *testing.T) {
+	audio := make([]byte, 256)
+	streamInfo := flacBlock(0, false, make([]byte, 34))
+	flac := append([]byte("fLaC"), streamInfo...)
+	flac = append(flac, flacPictureData("flac cover")...)
+	flac = append(flac, flacBlock(4, true, vorbisComment("TITLE=Song", "ARTIST=Band", "ALBUM=Record", "TRACKNUMBER=3/12", "DATE=2006-05-17"))...)
+
+	ilst := mp4Box("ilst",
+		mp4Box("\xa9nam", mp4Data(1, []byte("Pilot"))),
+		mp4Box("tvsh", mp4Data(1, []byte("The Show"))),
+		mp4Box("tvsn", mp4Data(21, []byte{0, 0, 0, 1})),
+		mp4Box("tves", mp4Data(21, []byte{0, 0, 0, 2})),
+		mp4Box("\xa9day", mp4Data(1, []byte("2010"))),
+		mp4Box("trkn", mp4Data(0, []byte{0, 0, 0, 4, 0, 10})),
+		mp4Box("covr", mp4Data(13, []byte("mp4 cover"))),
+	)
+	hdlr := mp4Box("hdlr", make([]byte, 25))
+	mp4 := append(mp4Box("ftyp", []byte("M4V \x00\x00\x00\x00")), mp4Box("mdat", make([]byte, 64))...)
+	mp4 = append(mp4, mp4Box("moov",
+		mp4Box("mvhd", make([]byte, 100)),
+		mp4Box("udta", mp4Box("meta", make([]byte, 4), hdlr, ilst)),
+	)...)
+
+	tests := []struct {
+		name     string
+		contents []byte
+		expected Tags
+	}{
+		{
+			name: "id3v2.2",
+			contents: append(id3v2(2,
+				id3Frame(2, "TT2", 0, "Caf\xe9"),
+				id3Frame(2, "TP1", 0, "Band"),
+				id3Frame(2, "TRK", 0, "7"),
+			), audio...),
+			expected: Tags{Title: "Café", Artist: "Band", TrackNumber: 7},
+		},
+		{
+			name: "id3v2.3",
+			contents: append(id3v2(3,
+				id3Frame(3, "TIT2", 1, "Ünïcode"),
+				id3Frame(3, "TPE1", 1, "Band"),
+				id3Frame(3, "TALB", 0, "Record"),
+				id3Frame(3, "COMM", 0, "ignored"),
+				id3Frame(3, "TPOS", 0, "2/2"),
+				id3Frame(3, "TYER", 0, "1999"),
+				id3PictureFrame(4, "back cover"),
+				id3PictureFrame(3, "front cover"),
+				id3PictureFrame(0, "other"),
+			), audio...),
+			expected: Tags{Title: "Ünïcode", Artist: "Band", Album: "Record", DiscNumber: 2, Date: "1999",
+				Picture: &Picture{MIMEType: "image/png", Data: []byte("front cover"), pictureType: 3}},
+		},
+		{
+			name: "id3v2.4",
+			contents: append(id3v2(4,
+				id3Frame(4, "TIT2", 3, "Song\x00Other"),
+				id3Frame(4, "TPE2", 3, "Various"),
+				id3Frame(4, "TCOM", 3, "Composer"),
+				id3Frame(4, "TDRC", 3, "2020-01-02"),
+			), audio...),
+			expected: Tags{Title: "Song", AlbumArtist: "Various", Composer: "Composer", Date: "2020-01-02"},
+		},
+		{
+			name:     "id3v1",
+			contents: append(audio, id3v1("Old Song", "Old Band", "Old Record", "1980", 5)...),
+			expected: Tags{Title: "Old Song", Artist: "Old Band", Album: "Old Record", Date: "1980", TrackNumber: 5},
+		},
+		{
+			name:     "empty id3v2 with id3v1",
+			contents: append(append(id3v2(3), audio...), id3v1("Old Song", "", "", "", 0)...),
+			expected: Tags{Title: "Old Song"},
+		},
+		{
+			name:     "flac",
+			contents: flac,
+			expected: Tags{Title: "Song", Artist: "Band", Album: "Record", TrackNumber: 3, Date: "2006-05-17",
+				Picture: &Picture{MIMEType: "image/jpeg", Data: []byte("flac cover"), pictureType: 3}},
+		},
+		{
+			name:     "flac after id3",
+			contents: append(id3v2(3), flac...),
+			expected: Tags{Title: "Song", Artist: "Band", Album: "Record", TrackNumber: 3, Date: "2006-05-17",
+				Picture: &Picture{MIMEType: "image/jpeg", Data: []byte("flac cover"), pictureType: 3}},
+		},
+		{
+			name:     "mp4",
+			contents: mp4,
+			expected: Tags{Title: "Pilot", Show: "The Show", Season: 1, Episode: 2, Date: "2010", TrackNumber: 4,
+				Picture: &Picture{MIMEType: "image/jpeg", Data: []byte("mp4 cover"), pictureType: 3}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "go-chromecast-tags-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.RemoveAll(dir) })
+			filename := filepath.Join(dir, "media")
+			if err := ioutil.WriteFile(filename, test.contents, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Read(filename)
+			if err != nil {
+				t.Fatalf("unable to read tags: %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.expected) {
+				t.Fatalf("read %+v, expected %+v", *got, test.expected)
+			}
+		})
+	}
+}
+
+func TestReadNoTags(t *testing.T) {
+	for name, contents := range map[string][]byte{
+		"empty":   nil,
+		"unknown": bytes.Repeat([]byte{1}, 512),
+		"flac":    append([]byte("fLaC"), flacBlock(0, true, make([]byte, 34))...),
+		"mp4":     append(mp4Box("ftyp", []byte("isom")), mp4Box("moov", mp4Box("mvhd", make([]byte, 100)))...),
+	} {
+		// Not 'Read', which would ask ffprobe when it is installed.
+		if got, err := read(bytes.NewReader(contents)); err != ErrNoTags {
+			t.Errorf("%s: read %+v, %v, expected %v", name, got, err, ErrNoTags)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package ui
 
 import (
+	"context"
+
 	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
 
 	"github.com/jroimartin/gocui"
 	"github.com/sirupsen/logrus"
@@ -20,17 +23,23 @@ func (ui *UserInterface) setupKeyBindings() {
 	ui.gui.SetKeybinding("", 'm', gocui.ModNone, ui.volumeMute)
 	ui.gui.SetKeybinding("", gocui.KeyPgup, gocui.ModNone, ui.previousMedia)
 	ui.gui.SetKeybinding("", gocui.KeyPgdn, gocui.ModNone, ui.nextMedia)
+	ui.gui.SetKeybinding("", 't', gocui.ModNone, ui.nextSubtitles)
+	ui.gui.SetKeybinding("", 'a', gocui.ModNone, ui.nextAudioTrack)
+	ui.gui.SetKeybinding("", 'r', gocui.ModNone, ui.nextRepeatMode)
+	ui.gui.SetKeybinding("", 'h', gocui.ModNone, ui.shuffleQueue)
+	ui.gui.SetKeybinding("", '[', gocui.ModNone, ui.slowerPlayback)
+	ui.gui.SetKeybinding("", ']', gocui.ModNone, ui.fasterPlayback)
 }
 
 // playPause tells the app to play / pause:
 func (ui *UserInterface) playPause(g *gocui.Gui, v *gocui.View) error {
 	if ui.paused {
 		logrus.Info("Play")
-		ui.app.Unpause()
+		ui.app.Unpause(context.Background())
 		ui.paused = false
 	} else {
 		logrus.Info("Pause")
-		ui.app.Pause()
+		ui.app.Pause(context.Background())
 		ui.paused = true
 	}
 
@@ -39,12 +48,15 @@ func (ui *UserInterface) playPause(g *gocui.Gui, v *gocui.View) error {
 
 // seekBackwards tells the app to rewind:
 func (ui *UserInterface) seekBackwards(g *gocui.Gui, v *gocui.View) error {
-	err := ui.app.Seek(ui.seekRewind)
+	err := ui.app.Seek(context.Background(), ui.seekRewind)
 	if err != nil {
 		switch err {
 		case application.ErrMediaNotYetInitialised:
 			logrus.Warn("Rewind (nothing playing)")
 			return nil
+		case application.ErrSeekLiveMedia:
+			logrus.Warn("Rewind (live media)")
+			return nil
 		default:
 			logrus.WithError(err).Error("Rewind")
 			return nil
@@ -57,12 +69,15 @@ func (ui *UserInterface) seekBackwards(g *gocui.Gui, v *gocui.View) error {
 
 // seekForwards tells the app to fastforward:
 func (ui *UserInterface) seekForwards(g *gocui.Gui, v *gocui.View) error {
-	err := ui.app.Seek(ui.seekFastforward)
+	err := ui.app.Seek(context.Background(), ui.seekFastforward)
 	if err != nil {
 		switch err {
 		case application.ErrMediaNotYetInitialised:
 			logrus.Warn("Fastforward (nothing playing)")
 			return nil
+		case application.ErrSeekLiveMedia:
+			logrus.Warn("Fastforward (live media)")
+			return nil
 		default:
 			logrus.WithError(err).Error("Fastforward")
 			return nil
@@ -87,7 +102,7 @@ func (ui *UserInterface) volumeUp(g *gocui.Gui, v *gocui.View) error {
 
 	floatVolume := float32(ui.volume) / 100
 
-	err := ui.app.SetVolume(floatVolume)
+	err := ui.app.SetVolume(context.Background(), floatVolume)
 	if err != nil {
 		switch err {
 		case application.ErrVolumeOutOfRange:
@@ -117,7 +132,7 @@ func (ui *UserInterface) volumeDown(g *gocui.Gui, v *gocui.View) error {
 
 	floatVolume := float32(ui.volume) / 100
 
-	err := ui.app.SetVolume(floatVolume)
+	err := ui.app.SetVolume(context.Background(), floatVolume)
 	if err != nil {
 		switch err {
 		case application.ErrVolumeOutOfRange:
@@ -141,7 +156,7 @@ func (ui *UserInterface) volumeMute(g *gocui.Gui, v *gocui.View) error {
 		ui.muted = true
 	}
 
-	err := ui.app.SetMuted(ui.muted)
+	err := ui.app.SetMuted(context.Background(), ui.muted)
 	if err != nil {
 		logrus.WithError(err).WithField("muted", ui.muted).Error("Volume mute")
 		return nil
@@ -157,7 +172,7 @@ func (ui *UserInterface) volumeMute(g *gocui.Gui, v *gocui.View) error {
 
 // stopMedia halts playback:
 func (ui *UserInterface) stopMedia(g *gocui.Gui, v *gocui.View) error {
-	err := ui.app.StopMedia()
+	err := ui.app.StopMedia(context.Background())
 	if err != nil {
 		switch err {
 		case application.ErrNoMediaStop:
@@ -175,7 +190,7 @@ func (ui *UserInterface) stopMedia(g *gocui.Gui, v *gocui.View) error {
 
 // nextMedia starts playing the next item in the playlist:
 func (ui *UserInterface) nextMedia(g *gocui.Gui, v *gocui.View) error {
-	err := ui.app.Next()
+	err := ui.app.Next(context.Background())
 	if err != nil {
 		switch err {
 		case application.ErrNoMediaNext:
@@ -193,7 +208,7 @@ func (ui *UserInterface) nextMedia(g *gocui.Gui, v *gocui.View) error {
 
 // previousMedia starts playing the previous item in the playlist:
 func (ui *UserInterface) previousMedia(g *gocui.Gui, v *gocui.View) error {
-	err := ui.app.Previous()
+	err := ui.app.Previous(context.Background())
 	if err != nil {
 		switch err {
 		case application.ErrNoMediaPrevious:
@@ -208,3 +223,158 @@ func (ui *UserInterface) previousMedia(g *gocui.Gui, v *gocui.View) error {
 	logrus.Info("Previous")
 	return nil
 }
+
+// playbackRates are the rates 'slowerPlayback' and 'fasterPlayback' step
+// through.
+var playbackRates = []float32{0.5, 0.75, 1, 1.25, 1.5, 1.75, 2}
+
+// slowerPlayback steps the playback rate down:
+func (ui *UserInterface) slowerPlayback(g *gocui.Gui, v *gocui.View) error {
+	current := ui.app.PlaybackRate()
+	for i := len(playbackRates) - 1; i >= 0; i-- {
+		if playbackRates[i] < current {
+			return ui.setPlaybackRate(playbackRates[i])
+		}
+	}
+	logrus.Warn("Playback rate already at minimum")
+	return nil
+}
+
+// fasterPlayback steps the playback rate up:
+func (ui *UserInterface) fasterPlayback(g *gocui.Gui, v *gocui.View) error {
+	current := ui.app.PlaybackRate()
+	for _, rate := range playbackRates {
+		if rate > current {
+			return ui.setPlaybackRate(rate)
+		}
+	}
+	logrus.Warn("Playback rate already at maximum")
+	return nil
+}
+
+func (ui *UserInterface) setPlaybackRate(rate float32) error {
+	if err := ui.app.SetPlaybackRate(context.Background(), rate); err != nil {
+		switch err {
+		case application.ErrMediaNotYetInitialised:
+			logrus.Warn("Playback rate (nothing playing)")
+			return nil
+		default:
+			logrus.WithError(err).WithField("rate", rate).Error("Playback rate")
+			return nil
+		}
+	}
+
+	logrus.WithField("rate", rate).Info("Playback rate")
+	return nil
+}
+
+// repeatModeCycle is the order 'nextRepeatMode' goes through the repeat
+// modes in.
+var repeatModeCycle = []string{cast.RepeatOff, cast.RepeatAll, cast.RepeatSingle, cast.RepeatAllAndShuffle}
+
+// nextRepeatMode switches to the next repeat mode of the queue:
+func (ui *UserInterface) nextRepeatMode(g *gocui.Gui, v *gocui.View) error {
+	media := ui.app.Media()
+	if media == nil {
+		logrus.Warn("Repeat (nothing playing)")
+		return nil
+	}
+	next := repeatModeCycle[0]
+	for i, mode := range repeatModeCycle {
+		if mode == media.RepeatMode {
+			next = repeatModeCycle[(i+1)%len(repeatModeCycle)]
+		}
+	}
+
+	if err := ui.app.SetRepeatMode(context.Background(), next); err != nil {
+		logrus.WithError(err).Error("Repeat")
+		return nil
+	}
+	logrus.WithField("mode", next).Info("Repeat")
+	return nil
+}
+
+// shuffleQueue shuffles the queue:
+func (ui *UserInterface) shuffleQueue(g *gocui.Gui, v *gocui.View) error {
+	if err := ui.app.ShuffleQueue(context.Background()); err != nil {
+		switch err {
+		case application.ErrMediaNotYetInitialised:
+			logrus.Warn("Shuffle (nothing playing)")
+			return nil
+		default:
+			logrus.WithError(err).Error("Shuffle")
+			return nil
+		}
+	}
+
+	logrus.Info("Shuffle")
+	return nil
+}
+
+// nextSubtitles switches to the next subtitles track, or turns them off
+// after the last one:
+func (ui *UserInterface) nextSubtitles(g *gocui.Gui, v *gocui.View) error {
+	tracks, active := ui.app.Tracks()
+	next := nextTrack(tracks, active, "TEXT", true)
+	if next == nil {
+		if err := ui.app.DisableSubtitles(context.Background()); err != nil {
+			logrus.WithError(err).Error("Subtitles off")
+			return nil
+		}
+		logrus.Info("Subtitles off")
+		return nil
+	}
+
+	if err := ui.app.EnableTrack(context.Background(), next.TrackId); err != nil {
+		logrus.WithError(err).Error("Subtitles")
+		return nil
+	}
+	logrus.WithField("language", next.Language).WithField("name", next.Name).Info("Subtitles")
+	return nil
+}
+
+// nextAudioTrack switches to the next audio track:
+func (ui *UserInterface) nextAudioTrack(g *gocui.Gui, v *gocui.View) error {
+	tracks, active := ui.app.Tracks()
+	next := nextTrack(tracks, active, "AUDIO", false)
+	if next == nil {
+		logrus.Warn("Audio track (no audio tracks)")
+		return nil
+	}
+
+	if err := ui.app.EnableTrack(context.Background(), next.TrackId); err != nil {
+		logrus.WithError(err).Error("Audio track")
+		return nil
+	}
+	logrus.WithField("language", next.Language).WithField("name", next.Name).Info("Audio track")
+	return nil
+}
+
+// nextTrack returns the track of 'trackType' after the active one. After
+// the last track it is nil when 'off' can be switched to, otherwise it is
+// the first track again.
+func nextTrack(tracks []cast.MediaTrack, active []int, trackType string, off bool) *cast.MediaTrack {
+	var ofType []cast.MediaTrack
+	current := -1
+	for _, track := range tracks {
+		if track.Type != trackType {
+			continue
+		}
+		for _, id := range active {
+			if id == track.TrackId {
+				current = len(ofType)
+			}
+		}
+		ofType = append(ofType, track)
+	}
+	if len(ofType) == 0 {
+		return nil
+	}
+	if current+1 < len(ofType) {
+		return &ofType[current+1]
+	}
+	if off {
+		return nil
+	}
+	return &ofType[0]
+}
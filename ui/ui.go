@@ -16,6 +16,7 @@ type UserInterface struct {
 	app             *application.Application
 	displayName     string
 	gui             *gocui.Gui
+	live            bool
 	media           string
 	muted           bool
 	paused          bool
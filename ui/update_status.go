@@ -1,94 +1,122 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/jroimartin/gocui"
 	"github.com/sirupsen/logrus"
+
+	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
 )
 
-// updateStatus polls the chromecast application for status info, and updates the UI:
-func (ui *UserInterface) updateStatus(sleepTime time.Duration) {
+// updateStatus redraws the UI whenever the chromecast application reports a change,
+// and polls it for status info every pollInterval to keep the playback position moving:
+func (ui *UserInterface) updateStatus(pollInterval time.Duration) {
+	sub := ui.app.Subscribe()
+	defer sub.Unsubscribe()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
 
 	for {
-		// Sleep before updating:
-		time.Sleep(sleepTime)
-
-		// Get the "status" view:
-		viewStatus, err := ui.gui.View(viewNameStatus)
-		if err != nil {
-			logrus.WithError(err).Errorf("Unable to get gocui view (%s)", viewNameStatus)
-			continue
+		select {
+		case <-ticker.C:
+			// The reply is delivered as an event:
+			ui.app.Update(context.Background())
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// Raw messages are also delivered as one of the other events:
+			if _, raw := event.(application.MessageReceived); !raw {
+				ui.redrawStatus()
+			}
 		}
+	}
+}
 
-		// Get the "progress" view:
-		viewProgress, err := ui.gui.View(viewNameProgress)
-		if err != nil {
-			logrus.WithError(err).Errorf("Unable to get gocui view (%s)", viewNameProgress)
-			continue
-		}
+// redrawStatus updates the UI from the current status of the chromecast application:
+func (ui *UserInterface) redrawStatus() {
+	// Get the "status" view:
+	viewStatus, err := ui.gui.View(viewNameStatus)
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to get gocui view (%s)", viewNameStatus)
+		return
+	}
 
-		// Get the "volume" view:
-		viewVolume, err := ui.gui.View(viewNameVolume)
-		if err != nil {
-			logrus.WithError(err).Errorf("Unable to get gocui view (%s)", viewNameVolume)
-			continue
-		}
+	// Get the "progress" view:
+	viewProgress, err := ui.gui.View(viewNameProgress)
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to get gocui view (%s)", viewNameProgress)
+		return
+	}
 
-		// Update the "status" view:
-		ui.gui.Update(func(*gocui.Gui) error { return nil })
-		ui.app.Update()
-		viewStatus.Clear()
-		castApplication, castMedia, castVolume := ui.app.Status()
+	// Get the "volume" view:
+	viewVolume, err := ui.gui.View(viewNameVolume)
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to get gocui view (%s)", viewNameVolume)
+		return
+	}
 
-		// Update the displayName:
-		if castApplication == nil {
-			ui.displayName = "Idle"
-		} else {
-			ui.displayName = castApplication.DisplayName
-		}
+	// Update the "status" view:
+	ui.gui.Update(func(*gocui.Gui) error { return nil })
+	viewStatus.Clear()
+	castApplication, castMedia, castVolume := ui.app.Status()
 
-		// Update the media info:
-		if castMedia != nil {
-			var media string
-			if castMedia.Media.Metadata.Artist != "" {
-				media = fmt.Sprintf("%s - ", castMedia.Media.Metadata.Artist)
-			}
-			if castMedia.Media.Metadata.Title != "" {
-				media += fmt.Sprintf("%s ", castMedia.Media.Metadata.Title)
-			}
-			if castMedia.Media.ContentId != "" {
-				media += fmt.Sprintf("[%s] ", castMedia.Media.ContentId)
-			}
-			ui.media = media
-		} else {
-			ui.media = "unknown"
-		}
-		fmt.Fprintf(viewStatus, "%sMedia:  %s%s%s\n", normalTextColour, boldTextColour, ui.media, resetTextColour)
+	// Update the displayName:
+	if castApplication == nil {
+		ui.displayName = "Idle"
+	} else {
+		ui.displayName = castApplication.DisplayName
+	}
 
-		if castApplication != nil {
-			fmt.Fprintf(viewStatus, "%sDetail: %s%s%s\n", normalTextColour, boldTextColour, castApplication.StatusText, resetTextColour)
+	// Update the media info:
+	if castMedia != nil {
+		var media string
+		if castMedia.Media.Metadata.Artist != "" {
+			media = fmt.Sprintf("%s - ", castMedia.Media.Metadata.Artist)
 		}
-
-		// Update the player status:
-		if castMedia != nil {
-			ui.paused = castMedia.PlayerState == "PAUSED"
+		if castMedia.Media.Metadata.Title != "" {
+			media += fmt.Sprintf("%s ", castMedia.Media.Metadata.Title)
 		}
-
-		// Update the playback position:
-		if castMedia != nil {
-			ui.positionCurrent = castMedia.CurrentTime
-			ui.positionTotal = castMedia.Media.Duration
-		} else {
-			ui.positionCurrent = 0
-			ui.positionTotal = 0
+		if castMedia.Media.ContentId != "" {
+			media += fmt.Sprintf("[%s] ", castMedia.Media.ContentId)
 		}
+		ui.media = media
+	} else {
+		ui.media = "unknown"
+	}
+	fmt.Fprintf(viewStatus, "%sMedia:  %s%s%s\n", normalTextColour, boldTextColour, ui.media, resetTextColour)
+
+	if castApplication != nil {
+		fmt.Fprintf(viewStatus, "%sDetail: %s%s%s\n", normalTextColour, boldTextColour, castApplication.StatusText, resetTextColour)
+	}
+
+	// Update the player status:
+	if castMedia != nil {
+		ui.paused = castMedia.PlayerState == "PAUSED"
+	}
+
+	// Update the playback position:
+	if castMedia != nil {
+		ui.positionCurrent = castMedia.CurrentTime
+		ui.positionTotal = castMedia.Media.Duration
+		ui.live = castMedia.Media.StreamType == cast.StreamTypeLive
+	} else {
+		ui.positionCurrent = 0
+		ui.positionTotal = 0
+		ui.live = false
+	}
 
-		// Update the "progress" view:
-		if castMedia != nil {
-			viewProgress.Clear()
-			viewWidth, _ := viewProgress.Size()
+	// Update the "progress" view:
+	if castMedia != nil {
+		viewProgress.Clear()
+		viewWidth, _ := viewProgress.Size()
+		// Live media has no end to show progress towards.
+		if !ui.live && castMedia.Media.Duration > 0 {
 			progress := (castMedia.CurrentTime / castMedia.Media.Duration) * float32(viewWidth)
 
 			// Draw a bar of "#" to represent progress:
@@ -96,19 +124,19 @@ func (ui *UserInterface) updateStatus(sleepTime time.Duration) {
 				fmt.Fprintf(viewProgress, "%s#", progressColour)
 			}
 		}
+	}
 
-		// Update the "volume" view:
-		if castVolume != nil {
-			ui.volume = int(castVolume.Level * 100)
-			ui.muted = castVolume.Muted
-
-			viewVolume.Clear()
-			if ui.muted {
-				fmt.Fprintf(viewVolume, "%s(muted)", volumeMutedColour)
-			} else {
-				for i := 0; i < ui.volume/5; i++ {
-					fmt.Fprintf(viewVolume, "%s#", volumeColour)
-				}
+	// Update the "volume" view:
+	if castVolume != nil {
+		ui.volume = int(castVolume.Level * 100)
+		ui.muted = castVolume.Muted
+
+		viewVolume.Clear()
+		if ui.muted {
+			fmt.Fprintf(viewVolume, "%s(muted)", volumeMutedColour)
+		} else {
+			for i := 0; i < ui.volume/5; i++ {
+				fmt.Fprintf(viewVolume, "%s#", volumeColour)
 			}
 		}
 	}
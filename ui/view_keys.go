@@ -26,6 +26,11 @@ func (ui *UserInterface) viewKeys(g *gocui.Gui) error {
 		fmt.Fprintf(v, "%s, Seek: %s←%s / %s→", normalTextColour, boldTextColour, normalTextColour, boldTextColour)
 		fmt.Fprintf(v, "%s, Previous/Next: %sPgUp%s / %sPgDn", normalTextColour, boldTextColour, normalTextColour, boldTextColour)
 		fmt.Fprintf(v, "%s, Stop: %ss", normalTextColour, boldTextColour)
+		fmt.Fprintf(v, "%s, Subtitles: %st", normalTextColour, boldTextColour)
+		fmt.Fprintf(v, "%s, Audio: %sa", normalTextColour, boldTextColour)
+		fmt.Fprintf(v, "%s, Repeat: %sr", normalTextColour, boldTextColour)
+		fmt.Fprintf(v, "%s, Shuffle: %sh", normalTextColour, boldTextColour)
+		fmt.Fprintf(v, "%s, Speed: %s[%s / %s]", normalTextColour, boldTextColour, normalTextColour, boldTextColour)
 		fmt.Fprint(v, resetTextColour)
 	}
 	return nil
@@ -17,7 +17,11 @@ func (ui *UserInterface) viewProgress(g *gocui.Gui) error {
 		return err
 	}
 
-	v.Title = fmt.Sprintf("%s (%0.2fs / %0.2fs)", viewNameProgress, ui.positionCurrent, ui.positionTotal)
+	if ui.live {
+		v.Title = fmt.Sprintf("%s (LIVE, %0.2fs)", viewNameProgress, ui.positionCurrent)
+	} else {
+		v.Title = fmt.Sprintf("%s (%0.2fs / %0.2fs)", viewNameProgress, ui.positionCurrent, ui.positionTotal)
+	}
 
 	return nil
 }